@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+	stderrors "errors"
 	"log/slog"
+	"strconv"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
@@ -12,6 +15,7 @@ import (
 	"github.com/liftedinit/mfx-migrator/internal/config"
 
 	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
 )
 
 // claimCmd represents the claim command
@@ -25,11 +29,14 @@ Claimed work items should be marked as 'claimed'' in the database.
 
 Trying to claim a work item that is already claimed should return an error.
 Trying to claim a work item that is already completed should return an error.
-Trying to claim a work item that is already failed should return an error, unless the '-f' flag is set.`,
+Trying to claim a work item that is already failed should return an error, unless the '-f' flag is set.
+
+Work items are claimed from the queue of every configured neighborhood, see '--neighborhoods'.
+A work item claimed by UUID is claimed from the neighborhood given by '--neighborhood'.`,
 	RunE: ClaimCmdRunE,
 }
 
-func ClaimCmdRunE(cmd *cobra.Command, args []string) error {
+func ClaimCmdRunE(cmd *cobra.Command, args []string) (err error) {
 	c := LoadConfigFromCLI("claim-uuid")
 	slog.Debug("args", "c", c)
 	if err := c.Validate(); err != nil {
@@ -40,26 +47,57 @@ func ClaimCmdRunE(cmd *cobra.Command, args []string) error {
 	slog.Debug("args", "claim-c", claimConfig)
 
 	authConfig := LoadAuthConfigFromCLI()
+	if err := resolveAuthSecrets(cmd.Context(), &authConfig); err != nil {
+		return err
+	}
 	slog.Debug("args", "auth-c", authConfig)
 	if err := authConfig.Validate(); err != nil {
 		return err
 	}
 
-	r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
-	if err := AuthenticateRestClient(r, authConfig.Username, authConfig.Password); err != nil {
+	neighborhoods, err := LoadNeighborhoodsFromCLI()
+	if err != nil {
 		return err
 	}
+	if c.UUID != "" {
+		neighborhoods = []uint64{c.Neighborhood}
+	}
 
-	items, err := claimWorkItem(r, c.UUID, claimConfig)
-	if err != nil {
+	ctx, shutdown := startGracefulShutdown(cmd.Context())
+	defer shutdown.Close()
+
+	ctx, span := tracing.Start(ctx, "claim")
+	defer func() { tracing.End(span, err) }()
+
+	r := CreateRestClient(ctx, c.Url, neighborhoods[0])
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
 		return err
 	}
 
-	if len(items) == 0 {
+	// A neighborhood failing to deliver work items does not prevent claiming from the others
+	var claimErrs []error
+	claimed := 0
+	for _, neighborhood := range neighborhoods {
+		if err := checkShutdown(ctx); err != nil {
+			slog.Warn("Shutting down, no more work items are claimed")
+			break
+		}
+
+		r.SetPathParam("neighborhood", strconv.FormatUint(neighborhood, 10))
+		items, err := claimWorkItem(ctx, r, neighborhood, c.UUID, claimConfig)
+		if err != nil {
+			slog.Error("Unable to claim work items", "neighborhood", neighborhood, "error", err)
+			claimErrs = append(claimErrs, errors.WithMessagef(err, "neighborhood %d", neighborhood))
+			continue
+		}
+		claimed += len(items)
+	}
+
+	if claimed == 0 && len(claimErrs) == 0 {
 		slog.Info("No work items available")
 	}
 
-	return nil
+	return stderrors.Join(claimErrs...)
 }
 
 func init() {
@@ -79,27 +117,27 @@ func SetupClaimCmdFlags(command *cobra.Command) {
 	}
 }
 
-// claimWorkItem claims a work item from the database
-func claimWorkItem(r *resty.Client, uuidStr string, config config.ClaimConfig) ([]*store.WorkItem, error) {
-	slog.Info("Claiming work item...")
+// claimWorkItem claims a work item of the neighborhood from the database
+func claimWorkItem(ctx context.Context, r *resty.Client, neighborhood uint64, uuidStr string, config config.ClaimConfig) ([]*store.WorkItem, error) {
+	slog.Info("Claiming work item...", "neighborhood", neighborhood)
 	var err error
 	var items []*store.WorkItem
 	if uuidStr != "" {
 		var item *store.WorkItem
-		item, err = store.ClaimWorkItemFromUUID(r, uuid.MustParse(uuidStr), config.Force)
+		item, err = store.ClaimWorkItemFromUUID(ctx, r, neighborhood, uuid.MustParse(uuidStr), config.Force)
 		if err != nil {
 			return nil, errors.WithMessage(err, "could not claim work item")
 		}
 		items = append(items, item)
 	} else {
-		items, err = store.ClaimWorkItemFromQueue(r)
+		items, err = store.ClaimWorkItemFromQueue(ctx, r, neighborhood)
 		if err != nil {
 			return nil, errors.WithMessage(err, "could not claim work item")
 		}
 	}
 
 	for _, item := range items {
-		slog.Info("Work item claimed", "uuid", item.UUID)
+		slog.Info("Work item claimed", "neighborhood", neighborhood, "uuid", item.UUID)
 	}
 
 	return items, nil
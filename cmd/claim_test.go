@@ -8,6 +8,7 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/jarcoal/httpmock"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 
 	"github.com/liftedinit/mfx-migrator/internal/store"
@@ -93,11 +94,43 @@ func TestClaimCmd(t *testing.T) {
 			httpmock.Reset()
 		})
 
-		// Remove the work item file if it exists
-		_, err := os.Stat(workItemPath)
-		if !os.IsNotExist(err) {
-			err = os.Remove(workItemPath)
-			require.NoError(t, err)
+		// Remove the work item files if they exist
+		for _, path := range []string{workItemPath, store.StatePath(1, testutils.Uuid)} {
+			_, err := os.Stat(path)
+			if !os.IsNotExist(err) {
+				err = os.Remove(path)
+				require.NoError(t, err)
+			}
 		}
 	}
 }
+
+func TestClaimCmd_Neighborhoods(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+
+	command := &cobra.Command{Use: "claim", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ClaimCmdRunE}
+	client := resty.New()
+	command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.Reset)
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupClaimCmdFlags(command)
+	t.Cleanup(func() { viper.Set("neighborhoods", nil) })
+
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+	httpmock.RegisterResponder("PUT", testutils.DefaultClaimUrl, testutils.MigrationClaimResponder(1, store.CLAIMED))
+	httpmock.RegisterResponder("PUT", testutils.ClaimUrl, testutils.MigrationClaimResponder(1, store.CLAIMED))
+	httpmock.RegisterResponder("PUT", testutils.RootUrl+"neighborhoods/2/migrations/claim/", testutils.NotFoundResponder)
+
+	// The work item sharing a UUID in neighborhoods 0 and 1 is claimed twice, neighborhood 2 fails
+	_, err := testutils.Execute(t, command, "--url", testutils.RootUrl, "--username", "user", "--password", "pass", "--neighborhoods", "0,1,2")
+	require.ErrorContains(t, err, "neighborhood 2")
+	require.ErrorContains(t, err, "response status code: 404")
+
+	for _, neighborhood := range []uint64{0, 1} {
+		item, err := store.LoadState(neighborhood, testutils.Uuid)
+		require.NoError(t, err)
+		require.Equal(t, store.CLAIMED, item.Status)
+	}
+	require.NoFileExists(t, store.StatePath(2, testutils.Uuid))
+}
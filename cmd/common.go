@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
 	"github.com/liftedinit/mfx-migrator/internal/utils"
 
 	"github.com/liftedinit/mfx-migrator/internal/store"
@@ -22,7 +25,13 @@ const RestyClientKey store.ContextKey = "restyClient"
 // CreateRestClient creates a new resty client with the parsed URL and the claim config
 func CreateRestClient(ctx context.Context, url string, neighborhood uint64) *resty.Client {
 	slog.Info("Creating REST client...")
+	return newRestClient(ctx).
+		SetBaseURL(url).
+		SetPathParam("neighborhood", strconv.FormatUint(neighborhood, 10))
+}
 
+// newRestClient returns the resty client in the context, or a new one, with the retry and timeout settings
+func newRestClient(ctx context.Context) *resty.Client {
 	// If a resty client is already in the context, use it. Otherwise, create a new one.
 	// This allows the resty client to be injected for testing purposes.
 	var client *resty.Client
@@ -32,18 +41,29 @@ func CreateRestClient(ctx context.Context, url string, neighborhood uint64) *res
 		client = resty.New()
 	}
 	return client.
-		SetBaseURL(url).
-		SetPathParam("neighborhood", strconv.FormatUint(neighborhood, 10)).
 		SetRetryCount(10). // Retry the request process 3 times. Retry uses an exponential backoff algorithm.
 		SetRetryWaitTime(5 * time.Second). // With a 5 seconds wait time between retries
 		SetRetryMaxWaitTime(10 * time.Minute). // And a maximum wait time of 60 seconds for the whole process
 		SetTimeout(20 * time.Second) // Set a timeout of 10 seconds for the request
 }
 
-// AuthenticateRestClient logs in to the remote database
-func AuthenticateRestClient(r *resty.Client, username, password string) error {
+// AuthenticateRestClient logs in to the remote database.
+// The operator is notified of repeated authentication failures.
+func AuthenticateRestClient(ctx context.Context, r *resty.Client, username, password string) error {
+	ctx, span := tracing.Start(ctx, "authenticate")
+	err := authenticateRestClient(ctx, r, username, password)
+	tracing.End(span, err)
+	// Only the rejected credentials are authentication failures, not the network errors
+	if err == nil || errors.Is(err, store.ErrUnauthorized) {
+		notifier.RecordAuthResult(ctx, err)
+	}
+	return err
+}
+
+func authenticateRestClient(ctx context.Context, r *resty.Client, username, password string) error {
 	slog.Info("Authenticating...")
 	response, err := r.R().
+		SetContext(ctx).
 		SetBody(map[string]interface{}{"username": username, "password": password}).
 		SetResult(&store.Token{}).
 		Post("/auth/login")
@@ -56,6 +76,9 @@ func AuthenticateRestClient(r *resty.Client, username, password string) error {
 	}
 
 	statusCode := response.StatusCode()
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), store.ErrUnauthorized)
+	}
 	if statusCode != 200 {
 		return fmt.Errorf("response status code: %d", statusCode)
 	}
@@ -69,7 +92,7 @@ func AuthenticateRestClient(r *resty.Client, username, password string) error {
 		return fmt.Errorf("empty token returned")
 	}
 
-	slog.Debug("setting auth token", "token", token.AccessToken)
+	slog.Debug("setting auth token")
 	r.SetAuthToken(token.AccessToken)
 
 	return nil
@@ -100,25 +123,82 @@ func LoadClaimConfigFromCLI() config.ClaimConfig {
 	}
 }
 
-func LoadMigrationConfigFromCLI() config.MigrateConfig {
+// LoadMigrationConfigFromCLI loads the MigrateConfig from the CLI flags and the configuration file.
+// It fails if the token map or the destinations cannot be decoded.
+func LoadMigrationConfigFromCLI() (config.MigrateConfig, error) {
 	var tokenMap map[string]utils.TokenInfo
-	if err := viper.UnmarshalKey("token-map", &tokenMap); err != nil {
-		panic(err)
+	if err := unmarshalStructuredKey("token-map", &tokenMap); err != nil {
+		return config.MigrateConfig{}, utils.Mark(fmt.Errorf("could not parse token map: %w", err), config.ErrInvalidConfig)
+	}
+	var destinations map[string]config.Destination
+	if err := unmarshalStructuredKey("destinations", &destinations); err != nil {
+		return config.MigrateConfig{}, utils.Mark(fmt.Errorf("could not parse destinations: %w", err), config.ErrInvalidConfig)
 	}
 	return config.MigrateConfig{
-		ChainID:          viper.GetString("chain-id"),
-		AddressPrefix:    viper.GetString("address-prefix"),
-		NodeAddress:      viper.GetString("node-address"),
-		KeyringBackend:   viper.GetString("keyring-backend"),
-		BankAddress:      viper.GetString("bank-address"),
-		ChainHome:        viper.GetString("chain-home"),
-		TokenMap:         tokenMap,
-		WaitTxTimeout:    viper.GetUint("wait-for-tx-timeout"),
-		WaitBlockTimeout: viper.GetUint("wait-for-block-timeout"),
-		Binary:           viper.GetString("binary"),
-		GasAdjustment:    viper.GetFloat64("gas-adjustment"),
-		GasPrice:         viper.GetFloat64("gas-price"),
-		GasDenom:         viper.GetString("gas-denom"),
-		FeeGranter:       viper.GetString("fee-granter"),
+		ChainID:            viper.GetString("chain-id"),
+		AddressPrefix:      viper.GetString("address-prefix"),
+		NodeAddress:        viper.GetString("node-address"),
+		KeyringBackend:     viper.GetString("keyring-backend"),
+		BankAddress:        viper.GetString("bank-address"),
+		ChainHome:          viper.GetString("chain-home"),
+		TokenMap:           tokenMap,
+		WaitTxTimeout:      viper.GetUint("wait-for-tx-timeout"),
+		WaitBlockTimeout:   viper.GetUint("wait-for-block-timeout"),
+		Binary:             viper.GetString("binary"),
+		GasAdjustment:      viper.GetFloat64("gas-adjustment"),
+		GasPrice:           viper.GetFloat64("gas-price"),
+		GasDenom:           viper.GetString("gas-denom"),
+		FeeGranter:         viper.GetString("fee-granter"),
+		ManyNodeAddress:    viper.GetString("many-node-address"),
+		MaxFee:             viper.GetUint("max-fee"),
+		DailyFeeBudget:     viper.GetUint("daily-fee-budget"),
+		Destinations:       destinations,
+		BroadcastRetries:   viper.GetUint("broadcast-retries"),
+		BroadcastRetryWait: viper.GetUint("broadcast-retry-wait"),
+	}, nil
+}
+
+// unmarshalStructuredKey decodes a structured setting.
+// A setting read from the environment is a string holding the JSON encoding of the setting.
+func unmarshalStructuredKey(key string, dest any) error {
+	encoded, ok := viper.Get(key).(string)
+	if !ok {
+		return viper.UnmarshalKey(key, dest)
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return err
+	}
+
+	// Decode the setting the same way as the settings of the configuration file
+	v := viper.New()
+	v.Set(key, value)
+	return v.UnmarshalKey(key, dest)
+}
+
+func LoadWhitelistConfigFromCLI() config.WhitelistConfig {
+	return config.WhitelistConfig{
+		Mode:      viper.GetString("whitelist-mode"),
+		CacheTTL:  viper.GetUint("whitelist-cache-ttl"),
+		CacheFile: viper.GetString("whitelist-cache-file"),
+		File:      viper.GetString("whitelist-file"),
+		PublicKey: viper.GetString("whitelist-public-key"),
+	}
+}
+
+func LoadDenylistConfigFromCLI() config.DenylistConfig {
+	return config.DenylistConfig{
+		Files:           viper.GetStringSlice("denylist-file"),
+		Urls:            viper.GetStringSlice("denylist-url"),
+		RefreshInterval: viper.GetUint("denylist-refresh-interval"),
+	}
+}
+
+func LoadTracingConfigFromCLI() config.TracingConfig {
+	return config.TracingConfig{
+		Exporter: viper.GetString("trace-exporter"),
+		Endpoint: viper.GetString("trace-otlp-endpoint"),
+		Insecure: viper.GetBool("trace-otlp-insecure"),
 	}
 }
@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// DefaultConfigFile is the file scaffolded by `config init`
+const DefaultConfigFile = "migrator-config.yaml"
+
+// EnvPrefix prefixes the environment variables of the settings
+const EnvPrefix = "MFX_MIGRATOR"
+
+// envKeyReplacer maps the setting keys to their environment variable
+var envKeyReplacer = strings.NewReplacer("-", "_")
+
+// structuredSettings are the settings whose environment variable holds a JSON document
+var structuredSettings = []string{"token-map", "neighborhood-overrides", "destinations"}
+
+// maskedValue replaces the secrets printed by `config show`
+const maskedValue = "****"
+
+// perRunFlags are the flags and keys selecting what a single run does. They are not settings.
+var perRunFlags = []string{"help", "uuid", "force", "output", "claim-uuid", "migrate-uuid", "verify-uuid", "older-than", "all", "tx-hash", "destination"}
+
+// requiredSettings are the settings without usable default
+var requiredSettings = []string{"url", "username", "password", "chain-home", "fee-granter"}
+
+// configCmd represents the config command group
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Validate, show and scaffold the configuration",
+	Long: `The configuration is read from the flags, the MFX_MIGRATOR_* environment variables and the migrator-config
+file in the current directory or in /config, in that order of precedence.`,
+	PersistentPreRunE: ConfigCmdPersistentPreRunE,
+}
+
+// ConfigCmdPersistentPreRunE applies the selected profile.
+// The config commands have no side effects: the logs, notifier and tracer are not set up.
+func ConfigCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
+	return applyProfile(cmd)
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the effective configuration without side effects",
+	RunE:  ConfigValidateCmdRunE,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and the source of each setting, secrets masked",
+	RunE:  ConfigShowCmdRunE,
+}
+
+var configEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "List the environment variable of each setting",
+	RunE:  ConfigEnvCmdRunE,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Scaffold a commented configuration file",
+	RunE:  ConfigInitCmdRunE,
+}
+
+// ConfigValidateCmdRunE validates every section of the effective configuration and reports every invalid section
+func ConfigValidateCmdRunE(cmd *cobra.Command, args []string) error {
+	sections := []struct {
+		name     string
+		validate func() error
+	}{
+		{"config file", readConfigFile},
+		{"profile", checkProfileEnvironment},
+		{"remote database", LoadConfigFromCLI("").Validate},
+		{"auth", func() error {
+			authConfig := LoadAuthConfigFromCLI()
+			if err := resolveAuthSecrets(cmd.Context(), &authConfig); err != nil {
+				return err
+			}
+			return authConfig.Validate()
+		}},
+		{"migrate", func() error {
+			migrateConfig, err := LoadMigrationConfigFromCLI()
+			if err != nil {
+				return err
+			}
+			if err := resolveMigrateSecrets(cmd.Context(), &migrateConfig); err != nil {
+				return err
+			}
+			return migrateConfig.Validate()
+		}},
+		{"whitelist", LoadWhitelistConfigFromCLI().Validate},
+		{"neighborhoods", validateNeighborhoods},
+		{"denylist", LoadDenylistConfigFromCLI().Validate},
+		{"notify", LoadNotifyConfigFromCLI().Validate},
+		{"tracing", LoadTracingConfigFromCLI().Validate},
+		{"log", LoadLogConfigFromCLI().Validate},
+		{"state key", func() error {
+			_, err := store.LoadStateKeys(viper.GetString("state-key-file"))
+			return err
+		}},
+	}
+
+	w := cmd.OutOrStdout()
+	invalid := 0
+	for _, section := range sections {
+		status := "ok"
+		if err := section.validate(); err != nil {
+			status = err.Error()
+			invalid++
+		}
+		fmt.Fprintf(w, "%-16s %s\n", section.name+":", status)
+	}
+
+	if invalid > 0 {
+		return utils.Mark(fmt.Errorf("%d invalid configuration section(s)", invalid), config.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// ConfigShowCmdRunE prints the effective value and the source of every setting. Secrets are masked.
+func ConfigShowCmdRunE(cmd *cobra.Command, args []string) error {
+	w := cmd.OutOrStdout()
+	if file := viper.ConfigFileUsed(); file != "" {
+		fmt.Fprintf(w, "Config file: %s\n", file)
+	} else {
+		fmt.Fprintln(w, "Config file: none")
+	}
+
+	flags, keys := settingKeys(cmd.Root())
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s = %s (%s)\n", key, formatSetting(key, viper.Get(key), flags[key]), settingSource(key, flags[key]))
+	}
+
+	return nil
+}
+
+// ConfigEnvCmdRunE lists the environment variable of every setting and whether it is set
+func ConfigEnvCmdRunE(cmd *cobra.Command, args []string) error {
+	w := cmd.OutOrStdout()
+	_, keys := settingKeys(cmd.Root())
+	for _, key := range keys {
+		var notes []string
+		if slices.Contains(structuredSettings, key) {
+			notes = append(notes, "JSON")
+		}
+		if isEnvSet(settingEnv(key)) {
+			notes = append(notes, "set")
+		}
+
+		line := fmt.Sprintf("%-48s %s", settingEnv(key), key)
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	return nil
+}
+
+// ConfigInitCmdRunE writes a commented configuration file holding every setting and its default value
+func ConfigInitCmdRunE(cmd *cobra.Command, args []string) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("config file %s already exists, use --force to overwrite it", output)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return errors.WithMessage(err, "unable to create config file")
+	}
+	defer f.Close()
+
+	if err := writeConfigTemplate(f, settingFlags(cmd.Root())); err != nil {
+		return errors.WithMessage(err, "unable to write config file")
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Config file written to %s\n", output)
+	return nil
+}
+
+// readConfigFile reads the configuration file again, if any, to report the parsing errors
+func readConfigFile() error {
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return utils.Mark(fmt.Errorf("could not read config file: %w", err), config.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// settingKeys returns the setting flags and the sorted keys of every setting, i.e., the flags, the structured settings
+// and the settings of the configuration file
+func settingKeys(root *cobra.Command) (map[string]*pflag.Flag, []string) {
+	flags := settingFlags(root)
+	keys := utils.GetKeys(flags)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[strings.ToLower(key)] = true
+	}
+	for _, key := range append(structuredSettings, viper.AllKeys()...) {
+		// Nested settings, e.g., the token map entries, are shown as a whole
+		key, _, _ = strings.Cut(key, ".")
+		if !seen[key] && !slices.Contains(perRunFlags, key) {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return flags, keys
+}
+
+// settingFlags returns the flags of every command, by name, except the per-run flags and the config command flags
+func settingFlags(root *cobra.Command) map[string]*pflag.Flag {
+	flags := map[string]*pflag.Flag{}
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		if c == configCmd || c.Name() == "completion" {
+			return
+		}
+		add := func(f *pflag.Flag) {
+			if !slices.Contains(perRunFlags, f.Name) {
+				flags[f.Name] = f
+			}
+		}
+		c.PersistentFlags().VisitAll(add)
+		c.Flags().VisitAll(add)
+		for _, child := range c.Commands() {
+			visit(child)
+		}
+	}
+	visit(root)
+	return flags
+}
+
+// settingEnv returns the environment variable of a setting, e.g., MFX_MIGRATOR_CHAIN_ID for chain-id
+func settingEnv(key string) string {
+	return strings.ToUpper(EnvPrefix + "_" + envKeyReplacer.Replace(key))
+}
+
+// settingSource returns where the effective value of a setting comes from, by order of precedence
+func settingSource(key string, flag *pflag.Flag) string {
+	switch {
+	case flag != nil && flag.Changed:
+		return "flag"
+	case isEnvSet(settingEnv(key)):
+		return "env " + settingEnv(key)
+	case profileSettings[key] != "":
+		return "profile " + profileSettings[key]
+	case viper.InConfig(key):
+		return "config file"
+	default:
+		return "default"
+	}
+}
+
+func isEnvSet(name string) bool {
+	_, ok := os.LookupEnv(name)
+	return ok
+}
+
+// isSecretSetting returns true if the setting holds a secret, e.g., a password or a webhook URL embedding a token
+func isSecretSetting(key string) bool {
+	return logging.IsSensitiveKey(key) || (strings.HasPrefix(key, "notify-") && strings.HasSuffix(key, "-url"))
+}
+
+// formatSetting formats a setting value, masking the secrets and the URL passwords
+func formatSetting(key string, value any, flag *pflag.Flag) string {
+	if isSecretSetting(key) && !isEmptySetting(value) {
+		return maskedValue
+	}
+
+	switch value := value.(type) {
+	case string:
+		// The values of the flags which are not set are strings, whatever the flag type
+		if flag != nil && flag.Value.Type() != "string" {
+			return value
+		}
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = u.Redacted()
+		}
+		return strconv.Quote(value)
+	case nil, bool, int, uint, int64, uint64, float64:
+		return fmt.Sprint(value)
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+}
+
+func isEmptySetting(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []string:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
+
+// writeConfigTemplate writes every setting, commented out with its default value, except the required ones
+func writeConfigTemplate(w io.Writer, flags map[string]*pflag.Flag) error {
+	names := utils.GetKeys(flags)
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# mfx-migrator configuration\n")
+	b.WriteString("#\n")
+	b.WriteString("# Flags and environment variables take precedence over this file.\n")
+	b.WriteString("# Set the required settings and uncomment the settings to change.\n")
+	b.WriteString("# Run `mfx-migrator config validate` to check the configuration.\n\n")
+
+	for _, name := range names {
+		f := flags[name]
+		fmt.Fprintf(&b, "# %s\n", f.Usage)
+		if slices.Contains(requiredSettings, name) {
+			fmt.Fprintf(&b, "# Required\n%s: \"\"\n\n", name)
+			continue
+		}
+		fmt.Fprintf(&b, "# %s: %s\n\n", name, yamlDefault(f))
+	}
+
+	b.WriteString("# Named profiles, selected with --profile, bundling the settings of an environment: mainnet, testnet or local\n")
+	b.WriteString("# A profile can set: " + strings.Join(config.ProfileKeys, ", ") + "\n")
+	b.WriteString("# profiles:\n")
+	b.WriteString("#   testnet:\n")
+	b.WriteString("#     environment: testnet # Defaults to the profile name\n")
+	b.WriteString("#     url: \"https://testnet.talib.example\"\n")
+	b.WriteString("#     chain-id: \"manifest-ledger-testnet\"\n")
+	b.WriteString("#     node-address: \"https://nodes.testnet.example:443\"\n\n")
+	b.WriteString("# Map of source token address to destination token info\n")
+	b.WriteString("# token-map:\n")
+	b.WriteString("#   mqbh742x4s356ddaryrxaowt4wxtlocekzpufodvowrirfrqaaaaa3l:\n")
+	b.WriteString("#     denom: umfx\n")
+	b.WriteString("#     gas-limit: 200000\n")
+	b.WriteString("#     low-balance: 1000000\n")
+	b.WriteString("#     destination: other # Name of the destination the token is sent from, top-level settings if unset\n\n")
+	b.WriteString("# Named destinations the tokens are routed to, overriding the top-level chain-id, address-prefix, node-address,\n")
+	b.WriteString("# binary, chain-home, keyring-backend, bank-address, fee-granter, gas-price, gas-adjustment and gas-denom\n")
+	b.WriteString("# destinations:\n")
+	b.WriteString("#   other:\n")
+	b.WriteString("#     chain-id: \"other-1\"\n")
+	b.WriteString("#     node-address: \"https://nodes.other.example:443\"\n")
+	b.WriteString("#     bank-address: \"other-bank\"\n")
+	b.WriteString("#     gas-denom: \"uother\"\n\n")
+	b.WriteString("# Settings of a neighborhood overriding the top-level settings: token-map, whitelist-mode, whitelist-file,\n")
+	b.WriteString("# whitelist-public-key and whitelist-cache-file\n")
+	b.WriteString("# neighborhood-overrides:\n")
+	b.WriteString("#   2:\n")
+	b.WriteString("#     whitelist-mode: file\n")
+	b.WriteString("#     whitelist-file: \"allowlist-2.json\"\n")
+	b.WriteString("#     whitelist-public-key: \"\"\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlDefault returns the YAML representation of the default value of a flag
+func yamlDefault(f *pflag.Flag) string {
+	switch f.Value.Type() {
+	case "string":
+		return strconv.Quote(f.DefValue)
+	case "stringSlice":
+		var quoted []string
+		if values := strings.Trim(f.DefValue, "[]"); values != "" {
+			for _, v := range strings.Split(values, ",") {
+				quoted = append(quoted, strconv.Quote(v))
+			}
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return f.DefValue
+	}
+}
+
+func SetupConfigInitCmdFlags(command *cobra.Command) {
+	command.Flags().StringP("output", "o", DefaultConfigFile, "Path of the configuration file to write")
+	command.Flags().BoolP("force", "f", false, "Overwrite the configuration file if it exists")
+}
+
+func init() {
+	SetupConfigInitCmdFlags(configInitCmd)
+	configCmd.AddCommand(configValidateCmd, configShowCmd, configEnvCmd, configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
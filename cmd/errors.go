@@ -1,6 +1,106 @@
 package cmd
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/many"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+	"github.com/liftedinit/mfx-migrator/internal/whitelist"
+)
+
 const (
 	ErrorBindingFlag         = "could not bind flags"
 	ErrorMarkingFlagRequired = "could not mark flag required"
 )
+
+// errorClass tells how a migration error is handled
+type errorClass int
+
+const (
+	// retryableError means no tokens were sent, e.g., a temporary outage. The work item is left as is.
+	// An error is retryable unless it is known to be terminal or escalated.
+	retryableError errorClass = iota + 1
+	// terminalError means the migration can never succeed, e.g., an invalid MANY transaction. The work item fails.
+	// Only the errors known to be terminal, e.g., marked with errTerminal, fail the work item.
+	terminalError
+	// escalatedError means an operator must review the work item, e.g., the transaction outcome is unknown.
+	// The work item is neither retried nor failed.
+	escalatedError
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case retryableError:
+		return "retryable"
+	case terminalError:
+		return "terminal"
+	case escalatedError:
+		return "escalated"
+	default:
+		return "unknown"
+	}
+}
+
+// errTerminal marks the errors meaning the migration of the work item can never succeed
+var errTerminal = errors.New("migration can never succeed")
+
+// terminalf returns a formatted error matching errTerminal
+func terminalf(format string, a ...interface{}) error {
+	return utils.Mark(fmt.Errorf(format, a...), errTerminal)
+}
+
+// notRecordedError is returned when the tokens were sent but the work item could not be marked as completed
+type notRecordedError struct {
+	TxHash string
+	Err    error
+}
+
+func (e *notRecordedError) Error() string {
+	return fmt.Sprintf("tokens sent in transaction %s but the work item was not updated: %s", e.TxHash, e.Err)
+}
+
+func (e *notRecordedError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError returns the class of a migration error.
+// Unknown errors, e.g., a local I/O error, are retryable, so a work item is never failed by mistake.
+func classifyError(err error) errorClass {
+	var matchErr *denylist.MatchError
+	var notRecordedErr *notRecordedError
+	var notAllowedErr *whitelist.NotAllowedError
+	var txErr *manifest.TxError
+	switch {
+	case errors.Is(err, manifest.ErrUnknownOutcome),
+		errors.As(err, &notRecordedErr),
+		errors.As(err, &matchErr):
+		return escalatedError
+	case manifest.IsRetryable(err),
+		errors.Is(err, config.ErrInvalidConfig),
+		errors.Is(err, store.ErrUnavailable),
+		errors.Is(err, store.ErrUnauthorized),
+		errors.Is(err, many.ErrUnavailable),
+		errors.Is(err, whitelist.ErrUnavailable),
+		errors.Is(err, denylist.ErrUnavailable),
+		errors.Is(err, ErrShuttingDown),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return retryableError
+	case errors.Is(err, errTerminal),
+		errors.Is(err, many.ErrInvalidTx),
+		errors.Is(err, many.ErrUnsupportedTx),
+		errors.Is(err, many.ErrTxNotFound),
+		errors.As(err, &notAllowedErr),
+		errors.As(err, &txErr):
+		return terminalError
+	default:
+		return retryableError
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+)
+
+// logClose closes the log files. It is set up by RootCmdPersistentPreRunE.
+var logClose func() error
+
+func LoadLogConfigFromCLI() config.LogConfig {
+	workerID := viper.GetString("worker-id")
+	if workerID == "" {
+		workerID = logging.DefaultWorkerID()
+	}
+
+	return config.LogConfig{
+		Level:      viper.GetString("logLevel"),
+		Format:     viper.GetString("log-format"),
+		Outputs:    viper.GetStringSlice("log-output"),
+		MaxSize:    viper.GetUint("log-max-size"),
+		MaxBackups: viper.GetUint("log-max-backups"),
+		MaxAge:     viper.GetUint("log-max-age"),
+		Compress:   viper.GetBool("log-compress"),
+		WorkerID:   workerID,
+	}
+}
+
+// setupLogging installs the default logger from the CLI flags
+func setupLogging() error {
+	logConfig := LoadLogConfigFromCLI()
+	if err := logConfig.Validate(); err != nil {
+		return err
+	}
+
+	closeLogs()
+	closeFn, err := logging.Setup(logConfig)
+	if err != nil {
+		return err
+	}
+	logClose = closeFn
+
+	slog.Debug("args", "log-c", logConfig)
+	return nil
+}
+
+// closeLogs closes the log files, if any
+func closeLogs() {
+	if logClose == nil {
+		return
+	}
+
+	if err := logClose(); err != nil {
+		fmt.Printf("Unable to close log files: %v\n", err)
+	}
+	logClose = nil
+}
+
+func setupLogFlags(command *cobra.Command) {
+	command.PersistentFlags().String("log-format", "json", "Log format (json|text)")
+	if err := viper.BindPFlag("log-format", command.PersistentFlags().Lookup("log-format")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().StringSlice("log-output", []string{"stdout"}, "Log output: stdout, stderr or a file path, rotated when it reaches --log-max-size (can be repeated)")
+	if err := viper.BindPFlag("log-output", command.PersistentFlags().Lookup("log-output")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	uintArgs := []struct {
+		name  string
+		value uint
+		usage string
+	}{
+		{"log-max-size", 100, "Maximum size in megabytes of a log file before it is rotated"},
+		{"log-max-backups", 5, "Maximum number of rotated log files to keep (0 = all)"},
+		{"log-max-age", 28, "Maximum number of days to keep the rotated log files (0 = forever)"},
+	}
+	for _, arg := range uintArgs {
+		command.PersistentFlags().Uint(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.name, command.PersistentFlags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+
+	command.PersistentFlags().Bool("log-compress", false, "Compress the rotated log files")
+	if err := viper.BindPFlag("log-compress", command.PersistentFlags().Lookup("log-compress")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("worker-id", "", "ID of the migrator process added to every log line (default <hostname>-<pid>)")
+	if err := viper.BindPFlag("worker-id", command.PersistentFlags().Lookup("worker-id")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
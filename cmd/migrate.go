@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log/slog"
 	"math/big"
-	"os"
-	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
@@ -14,12 +12,16 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
 
 	"github.com/liftedinit/mfx-migrator/internal/many"
 	"github.com/liftedinit/mfx-migrator/internal/utils"
 
+	"github.com/liftedinit/mfx-migrator/internal/logging"
 	"github.com/liftedinit/mfx-migrator/internal/manifest"
 	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/whitelist"
 )
 
 // migrateCmd represents the migrate command
@@ -29,27 +31,36 @@ var migrateCmd = &cobra.Command{
 	RunE:  MigrateCmdRunE,
 }
 
-func MigrateCmdRunE(cmd *cobra.Command, args []string) error {
+func MigrateCmdRunE(cmd *cobra.Command, args []string) (err error) {
 	c := LoadConfigFromCLI("migrate-uuid")
 	slog.Debug("args", "c", c)
 	if err := c.Validate(); err != nil {
 		return err
 	}
+	defer logging.WithWorkItem(c.Neighborhood, c.UUID)()
 
-	migrateConfig := LoadMigrationConfigFromCLI()
-	slog.Debug("args", "migrate-c", migrateConfig)
-	if err := migrateConfig.Validate(); err != nil {
+	migrateConfig, whitelistConfig, err := loadNeighborhoodConfigFromCLI(cmd.Context(), c.Neighborhood)
+	if err != nil {
 		return err
 	}
 
 	authConfig := LoadAuthConfigFromCLI()
+	if err := resolveAuthSecrets(cmd.Context(), &authConfig); err != nil {
+		return err
+	}
 	slog.Debug("args", "auth-c", authConfig)
 	if err := authConfig.Validate(); err != nil {
 		return err
 	}
 
-	slog.Info("Loading state...", "uuid", c.UUID)
-	item, err := store.LoadState(c.UUID)
+	denylistConfig := LoadDenylistConfigFromCLI()
+	slog.Debug("args", "denylist-c", denylistConfig)
+	if err := denylistConfig.Validate(); err != nil {
+		return err
+	}
+
+	slog.Info("Loading state...", "neighborhood", c.Neighborhood, "uuid", c.UUID)
+	item, err := store.LoadState(c.Neighborhood, c.UUID)
 	if err != nil {
 		return errors.WithMessage(err, "unable to load state")
 	}
@@ -57,37 +68,131 @@ func MigrateCmdRunE(cmd *cobra.Command, args []string) error {
 	if err := verifyItemStatus(item); err != nil {
 		return err
 	}
-	r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
-	if err := AuthenticateRestClient(r, authConfig.Username, authConfig.Password); err != nil {
+
+	ctx, shutdown := startGracefulShutdown(cmd.Context())
+	defer shutdown.Close()
+
+	ctx, span := tracing.Start(ctx, "migrate", tracing.Neighborhood(c.Neighborhood), tracing.WorkItem(c.UUID))
+	defer func() { tracing.End(span, err) }()
+
+	r := CreateRestClient(ctx, c.Url, c.Neighborhood)
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
 		return err
 	}
 
-	if err := verifyManyAddressIsAllowed(item, r); err != nil {
-		// An unauthorized address scheduled a migration
-		// Mark the migration as failed
-		slog.Error("Migration failed", "error", err)
-		errStr := err.Error()
-		sErr := setAsFailed(r, *item, &errStr)
-		if sErr != nil {
-			return errors.WithMessage(err, sErr.Error())
-		}
+	provider, err := whitelist.NewProvider(whitelistConfig, r)
+	if err != nil {
+		return errors.WithMessage(err, "unable to create whitelist provider")
+	}
 
-		return err
+	return migrateWorkItem(ctx, r, item, migrateConfig, provider, denylist.NewScreenerFromConfig(denylistConfig))
+}
+
+// loadNeighborhoodConfigFromCLI loads and validates the migrate and whitelist settings of the neighborhood
+func loadNeighborhoodConfigFromCLI(ctx context.Context, neighborhood uint64) (config.MigrateConfig, config.WhitelistConfig, error) {
+	migrateConfig, err := LoadMigrationConfigFromCLI()
+	if err != nil {
+		return config.MigrateConfig{}, config.WhitelistConfig{}, err
+	}
+	whitelistConfig := LoadWhitelistConfigFromCLI()
+	if err := applyNeighborhoodOverrides(neighborhood, &migrateConfig, &whitelistConfig); err != nil {
+		return config.MigrateConfig{}, config.WhitelistConfig{}, err
+	}
+	if err := resolveMigrateSecrets(ctx, &migrateConfig); err != nil {
+		return config.MigrateConfig{}, config.WhitelistConfig{}, err
+	}
+	slog.Debug("args", "migrate-c", migrateConfig)
+	if err := migrateConfig.Validate(); err != nil {
+		return config.MigrateConfig{}, config.WhitelistConfig{}, err
+	}
+
+	slog.Debug("args", "whitelist-c", whitelistConfig)
+	if err := whitelistConfig.Validate(); err != nil {
+		return config.MigrateConfig{}, config.WhitelistConfig{}, err
 	}
 
-	err = migrate(r, item, migrateConfig)
+	return migrateConfig, whitelistConfig, nil
+}
+
+// migrateWorkItem verifies the MANY sender address is allowed and migrates the work item.
+// The migration error, if any, is handled according to its class.
+func migrateWorkItem(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, provider whitelist.Provider, screener *denylist.Screener) error {
+	defer trackWorkItem(ctx, item)()
 
-	// The migration failed for some reason, update the work item status and save the state
+	err := traceStep(ctx, item, "verifyManyAddressIsAllowed", func(ctx context.Context) error {
+		return verifyManyAddressIsAllowed(ctx, item, r, provider)
+	})
 	if err != nil {
+		return handleMigrationError(ctx, r, item, err)
+	}
+
+	err = migrate(ctx, r, item, config, screener)
+	return handleMigrationError(ctx, r, item, err)
+}
+
+// handleMigrationError leaves the work item as is, fails it or escalates it, depending on the class of the error.
+func handleMigrationError(ctx context.Context, r *resty.Client, item *store.WorkItem, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	class := classifyError(err)
+	defer notifyMigrationError(ctx, item, class, err)
+
+	switch class {
+	case retryableError:
+		// No tokens were sent, e.g., the remote database is unavailable or the mempool is full
+		// Leave the work item as is so the migration can be retried
+		slog.Warn("Migration postponed, the migration will be retried", "error", err)
+	case escalatedError:
+		// An operator must review the work item before it is migrated again
+		if eErr := escalate(item, err); eErr != nil {
+			return errors.WithMessage(err, eErr.Error())
+		}
+	default:
+		// The migration can never succeed, update the work item status and quarantine the state
 		slog.Error("Migration failed", "error", err)
 		errStr := err.Error()
-		sErr := setAsFailed(r, *item, &errStr)
-		if sErr != nil {
+		failed := latestState(item)
+		if sErr := setAsFailed(ctx, r, failed, &errStr); sErr != nil {
 			return errors.WithMessage(err, sErr.Error())
 		}
+		if qErr := store.QuarantineWorkItem(*failed, errStr); qErr != nil {
+			return errors.WithMessage(err, qErr.Error())
+		}
 	}
+
 	return err
+}
+
+// escalate records why the work item needs an operator review in the local state.
+// The remote work item is left untouched.
+func escalate(item *store.WorkItem, err error) error {
+	// The transaction may have landed, record the unknown outcome
+	if errors.Is(err, manifest.ErrUnknownOutcome) {
+		var txHash string
+		var outcomeErr *manifest.UnknownOutcomeError
+		if errors.As(err, &outcomeErr) {
+			txHash = outcomeErr.TxHash
+		}
+		return store.SetUnknownOutcome(item.Neighborhood, item.UUID.String(), txHash, err.Error())
+	}
 
+	// A screened address matched a denylist, or the tokens were sent but the work item was not updated
+	// Hold the latest local state of the work item
+	slog.Error("Migration held", "error", err)
+	return store.HoldWorkItem(*latestState(item), err.Error())
+}
+
+// latestState returns the latest local state of the work item, e.g., after its status changed during the migration.
+// It returns the given work item if the state cannot be loaded.
+func latestState(item *store.WorkItem) *store.WorkItem {
+	latest, err := store.LoadState(item.Neighborhood, item.UUID.String())
+	if err != nil {
+		slog.Warn("unable to load local state, continuing", "warning", err)
+		return item
+	}
+	return latest
 }
 
 func init() {
@@ -95,45 +200,30 @@ func init() {
 	rootCmd.AddCommand(migrateCmd)
 }
 
-// verifyManyAddressIsAllowed verifies that the manifest address is in the whitelist and allowed to migrate tokens.
-func verifyManyAddressIsAllowed(item *store.WorkItem, client *resty.Client) error {
-	txArgs, err := many.GetTxInfo(client, item.ManyHash)
+// verifyManyAddressIsAllowed verifies that the MANY sender address is in the whitelist and allowed to migrate tokens.
+// It returns a whitelist.NotAllowedError if the address is not allowed.
+func verifyManyAddressIsAllowed(ctx context.Context, item *store.WorkItem, client *resty.Client, provider whitelist.Provider) error {
+	txArgs, err := many.GetTxInfo(ctx, client, item.ManyHash)
 	if err != nil {
 		return errors.WithMessage(err, "error getting MANY tx info")
 	}
 
-	resp, err := client.R().
-		SetPathParam("address", txArgs.From).
-		Get("migrations-whitelist/{address}")
-	if err != nil {
-		return errors.WithMessage(err, "error getting migration whitelisted addresses")
-	}
-
-	if resp == nil {
-		return fmt.Errorf("no response returned when getting migration whitelisted addresses")
-	}
-
-	statusCode := resp.StatusCode()
-	if statusCode != 200 {
-		return fmt.Errorf("response status code: %d", statusCode)
-	}
-
-	var isAllowed bool
-	if err := json.Unmarshal(resp.Body(), &isAllowed); err != nil {
-		return errors.WithMessage(err, "error unmarshalling response")
-	}
-
-	if !isAllowed {
-		return fmt.Errorf("address %s not allowed to migrate", txArgs.From)
-	}
-
-	return nil
+	return whitelist.Verify(ctx, provider, txArgs.From)
 }
 
 // verifyItemStatus verifies the status of the work item is valid for migration.
 func verifyItemStatus(item *store.WorkItem) error {
+	if item.Hold != nil {
+		return terminalf("work item is held: %s, %s", item.UUID, item.Hold.Reason)
+	}
+	if item.UnknownOutcome != nil {
+		return terminalf("work item transaction outcome unknown, check the transaction on chain: %s, %s", item.UUID, item.UnknownOutcome.TxHash)
+	}
+	if item.Broadcast != nil {
+		return terminalf("work item transaction broadcast but not confirmed, check the transaction on chain: %s, %s", item.UUID, item.Broadcast.TxHash)
+	}
 	if !(item.Status == store.CLAIMED || item.Status == store.MIGRATING) {
-		return fmt.Errorf("work item status not valid for migration: %s, %s", item.UUID, item.Status)
+		return terminalf("work item status not valid for migration: %s, %s", item.UUID, item.Status)
 	}
 	return nil
 }
@@ -141,29 +231,49 @@ func verifyItemStatus(item *store.WorkItem) error {
 // compareItems compares the local and remote work items to ensure they match.
 func compareItems(item *store.WorkItem, remoteItem *store.WorkItem) error {
 	if !item.Equal(*remoteItem) {
-		return fmt.Errorf("local and remote work items do not match: %s, %s", item.UUID, remoteItem.UUID)
+		return terminalf("local and remote work items do not match: %s, %s", item.UUID, remoteItem.UUID)
+	}
+	return nil
+}
+
+// verifyTxInfoWithNode verifies the MANY transaction info returned by Talib matches the one returned by the MANY node.
+func verifyTxInfoWithNode(ctx context.Context, item *store.WorkItem, txArgs *many.Arguments, nodeAddress string) error {
+	slog.Info("Cross-verifying MANY tx info with MANY node...", "uuid", item.UUID)
+	nodeArgs, err := many.NewNodeClient(newRestClient(ctx), nodeAddress).GetTxInfo(ctx, item.ManyHash)
+	if err != nil {
+		return errors.WithMessage(err, "error getting MANY tx info from MANY node")
+	}
+
+	if !txArgs.Equal(*nodeArgs) {
+		slog.Debug("MANY tx info mismatch", "talib", txArgs, "node", nodeArgs)
+		return terminalf("talib and MANY node tx info do not match: %s", item.ManyHash)
 	}
+
 	return nil
 }
 
 func setupStringCmdFlags(command *cobra.Command) {
 	args := []struct {
-		name     string
-		key      string
-		value    string
-		usage    string
-		required bool
+		name  string
+		key   string
+		value string
+		usage string
 	}{
-		{"chain-id", "chain-id", "manifest-1", "Chain ID of the blockchain to migrate to", false},
-		{"address-prefix", "address-prefix", "manifest", "Address prefix of the blockchain to migrate to", false},
-		{"node-address", "node-address", "http://localhost:26657", "Node address of the blockchain to migrate to", false},
-		{"keyring-backend", "keyring-backend", "test", "Keyring backend to use", false},
-		{"bank-address", "bank-address", "bank", "Bank address to send tokens from", false},
-		{"chain-home", "chain-home", "", "Root directory of the chain configuration", false},
-		{"uuid", "migrate-uuid", "", "UUID of the work item to migrate", true},
-		{"binary", "binary", "manifestd", "Binary name of the blockchain to migrate to", false},
-		{"gas-denom", "gas-denom", "umfx", "Denomination of the gas price", false},
-		{"fee-granter", "fee-granter", "", "The address of the gas fee granter", false},
+		{"chain-id", "chain-id", "manifest-1", "Chain ID of the blockchain to migrate to"},
+		{"address-prefix", "address-prefix", "manifest", "Address prefix of the blockchain to migrate to"},
+		{"node-address", "node-address", "http://localhost:26657", "Node address of the blockchain to migrate to"},
+		{"keyring-backend", "keyring-backend", "test", "Keyring backend to use"},
+		{"bank-address", "bank-address", "bank", "Bank address to send tokens from"},
+		{"chain-home", "chain-home", "", "Root directory of the chain configuration"},
+		{"binary", "binary", "manifestd", "Binary name of the blockchain to migrate to"},
+		{"gas-denom", "gas-denom", "umfx", "Denomination of the gas price"},
+		{"fee-granter", "fee-granter", "", "The address of the gas fee granter"},
+		{"whitelist-mode", "whitelist-mode", "remote", "Whitelist mode (remote|file|combined)"},
+		{"whitelist-cache-file", "whitelist-cache-file", "", "File used to persist the remote whitelist cache"},
+		{"whitelist-file", "whitelist-file", "", "Signed local allowlist file"},
+		{"whitelist-public-key", "whitelist-public-key", "", "Hex encoded ed25519 public key used to verify the local allowlist file"},
+		{"many-node-address", "many-node-address", "", "Address of the MANY node used to cross-verify the MANY transaction"},
+		{"keyring-passphrase-secret", "keyring-passphrase-secret", "", "Reference of the secret holding the keyring passphrase: file:PATH, env:NAME or an http(s) URL"},
 	}
 
 	for _, arg := range args {
@@ -171,11 +281,6 @@ func setupStringCmdFlags(command *cobra.Command) {
 		if err := viper.BindPFlag(arg.key, command.Flags().Lookup(arg.name)); err != nil {
 			slog.Error(ErrorBindingFlag, "error", err)
 		}
-		if arg.required {
-			if err := command.MarkFlagRequired(arg.name); err != nil {
-				slog.Error(ErrorMarkingFlagRequired, "error", err)
-			}
-		}
 	}
 }
 
@@ -188,6 +293,12 @@ func setupUIntCmdFlags(command *cobra.Command) {
 	}{
 		{"wait-for-tx-timeout", "wait-for-tx-timeout", 15, "Number of seconds spent waiting for the transaction to be included in a block"},
 		{"wait-for-block-timeout", "wait-for-block-timeout", 30, "Number of seconds spent waiting for the block to be committed"},
+		{"denylist-refresh-interval", "denylist-refresh-interval", 60, "Number of seconds between two fetches of the HTTP denylists"},
+		{"max-fee", "max-fee", 0, "Maximum fee of a single transaction, in gas denomination (0 = no limit)"},
+		{"daily-fee-budget", "daily-fee-budget", 0, "Maximum fees spent per UTC day, in gas denomination (0 = no limit)"},
+		{"broadcast-retries", "broadcast-retries", 3, "Maximum number of broadcast retries on retryable errors"},
+		{"broadcast-retry-wait", "broadcast-retry-wait", 2, "Number of seconds to wait before the first broadcast retry, doubled on each retry"},
+		{"whitelist-cache-ttl", "whitelist-cache-ttl", 300, "Number of seconds the remote whitelist results are cached"},
 	}
 
 	for _, arg := range args {
@@ -217,70 +328,137 @@ func setupFloatCmdFlags(command *cobra.Command) {
 	}
 }
 
+func setupStringSliceCmdFlags(command *cobra.Command) {
+	args := []struct {
+		name  string
+		key   string
+		value []string
+		usage string
+	}{
+		{"denylist-file", "denylist-file", nil, "Local denylist file (can be repeated)"},
+		{"denylist-url", "denylist-url", nil, "HTTP denylist endpoint (can be repeated)"},
+	}
+
+	for _, arg := range args {
+		command.Flags().StringSlice(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.key, command.Flags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+}
+
 func SetupMigrateCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the work item to migrate")
+	if err := viper.BindPFlag("migrate-uuid", command.Flags().Lookup("uuid")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+	if err := command.MarkFlagRequired("uuid"); err != nil {
+		slog.Error(ErrorMarkingFlagRequired, "error", err)
+	}
+
+	setupMigrateSettingFlags(command)
+}
+
+// setupMigrateSettingFlags sets up the flags of the migration settings, shared by the migrate and process commands
+func setupMigrateSettingFlags(command *cobra.Command) {
 	setupStringCmdFlags(command)
+	setupStringSliceCmdFlags(command)
 	setupUIntCmdFlags(command)
 	setupFloatCmdFlags(command)
 }
 
 func mapToken(symbol string, tokenMap map[string]utils.TokenInfo) (*utils.TokenInfo, error) {
 	if _, ok := tokenMap[symbol]; !ok {
-		return nil, fmt.Errorf("token %s not found in token map", symbol)
+		return nil, utils.Mark(fmt.Errorf("token %s not found in token map", symbol), config.ErrInvalidConfig)
 	}
 	info := tokenMap[symbol]
 	return &info, nil
 }
 
 // migrate migrates a work item to the Manifest Ledger.
-func migrate(r *resty.Client, item *store.WorkItem, config config.MigrateConfig) error {
+// Each step runs in its own span.
+func migrate(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, screener *denylist.Screener) error {
 	slog.Info("Migrating work item...", "uuid", item.UUID)
 
-	remoteItem, err := store.GetWorkItem(r, item.UUID)
+	var remoteItem *store.WorkItem
+	err := traceStep(ctx, item, "getRemoteWorkItem", func(ctx context.Context) (err error) {
+		remoteItem, err = store.GetWorkItem(ctx, r, item.UUID)
+		return err
+	})
 	if err != nil {
 		return errors.WithMessage(err, "error getting remote work item")
 	}
 
-	// Verify the item is ready for migration
-	if err = verifyItemStatus(remoteItem); err != nil {
-		return errors.WithMessage(err, "error verifying item status")
-	}
+	err = traceStep(ctx, item, "verifyRemoteWorkItem", func(ctx context.Context) error {
+		// Verify the item is ready for migration
+		if err := verifyItemStatus(remoteItem); err != nil {
+			return errors.WithMessage(err, "error verifying item status")
+		}
 
-	// Verify the local and remote items match
-	if err = compareItems(item, remoteItem); err != nil {
-		return errors.WithMessage(err, "error comparing items")
+		// Verify the local and remote items match
+		if err := compareItems(item, remoteItem); err != nil {
+			return errors.WithMessage(err, "error comparing items")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	txArgs, err := many.GetTxInfo(r, item.ManyHash)
+	var txArgs *many.Arguments
+	err = traceStep(ctx, item, "getTxInfo", func(ctx context.Context) (err error) {
+		txArgs, err = many.GetTxInfo(ctx, r, item.ManyHash)
+		return err
+	})
 	if err != nil {
 		return errors.WithMessage(err, "error getting MANY tx info")
 	}
 
 	// Check the MANY transaction info
-	if err = many.CheckTxInfo(txArgs, item.UUID, item.ManifestAddress); err != nil {
+	err = traceStep(ctx, item, "checkTxInfo", func(ctx context.Context) error {
+		return many.CheckTxInfo(txArgs, item.UUID, item.ManifestAddress)
+	})
+	if err != nil {
 		return errors.WithMessage(err, "error checking MANY tx info")
 	}
 
+	// Cross-verify the MANY transaction info with the MANY node, if configured
+	if config.ManyNodeAddress != "" {
+		err = traceStep(ctx, item, "verifyTxInfoWithNode", func(ctx context.Context) error {
+			return verifyTxInfoWithNode(ctx, item, txArgs, config.ManyNodeAddress)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "error cross-verifying MANY tx info")
+		}
+	}
+
 	// Map the MANY token symbol to the destination chain token
 	tokenInfo, err := mapToken(txArgs.Symbol, config.TokenMap)
 	if err != nil {
 		return errors.WithMessage(err, "error mapping token")
 	}
 
-	slog.Debug("Original amount", "amount", txArgs.Amount)
-
-	var newItem = *item
+	// Route the token to its destination chain and bank account
+	destination, err := config.ForToken(*tokenInfo)
+	if err != nil {
+		return errors.WithMessage(err, "error routing token")
+	}
+	slog.Debug("Token routed", "symbol", txArgs.Symbol, "destination", destination.Destination, "chainId", destination.ChainID)
 
-	// If the item status is not MIGRATING, set it to MIGRATING
-	if newItem.Status != store.MIGRATING {
-		if err = setAsMigrating(r, newItem); err != nil {
-			return errors.WithMessage(err, "could not set status to MIGRATING")
-		}
+	// Screen the destination and MANY sender addresses against the denylists
+	err = traceStep(ctx, item, "screenAddresses", func(ctx context.Context) error {
+		return screener.Screen(ctx, item.ManifestAddress, txArgs.From)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error screening addresses")
 	}
 
+	slog.Debug("Original amount", "amount", txArgs.Amount)
+
 	amount := new(big.Int)
 	_, ok := amount.SetString(txArgs.Amount, 10)
 	if !ok {
-		return fmt.Errorf("error parsing big.Int: %s", txArgs.Amount)
+		return terminalf("error parsing big.Int: %s", txArgs.Amount)
 	}
 
 	// The MANY chain supports 9 decimal places
@@ -292,59 +470,121 @@ func migrate(r *resty.Client, item *store.WorkItem, config config.MigrateConfig)
 
 	slog.Info("NEW AMOUNT", "newAmount", newAmount.String())
 
+	// Check the destination can send the tokens before the work item is marked as migrating
+	err = traceStep(ctx, item, "preflight", func(ctx context.Context) error {
+		return manifest.Preflight(ctx, destination, *tokenInfo, newAmount)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error checking destination")
+	}
+
+	// Nothing was sent yet, stop here if the migrator is shutting down
+	if err := checkShutdown(ctx); err != nil {
+		return err
+	}
+
+	var newItem = *item
+
+	// If the item status is not MIGRATING, set it to MIGRATING
+	if newItem.Status != store.MIGRATING {
+		err = traceStep(ctx, item, "setAsMigrating", func(ctx context.Context) error {
+			return setAsMigrating(ctx, r, &newItem)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "could not set status to MIGRATING")
+		}
+	}
+
 	// Send the tokens
-	txHash, blockTime, err := sendTokens(&newItem, config, tokenInfo.Denom, newAmount)
+	var result *manifest.MigrateResult
+	err = traceStep(ctx, item, "sendTokens", func(ctx context.Context) (err error) {
+		result, err = sendTokens(ctx, &newItem, destination, *tokenInfo, newAmount)
+		return err
+	})
 	if err != nil {
 		return errors.WithMessage(err, "error sending tokens")
 	}
 
-	slog.Info("Migration succeeded on chain...", "hash", txHash, "timestamp", blockTime)
+	slog.Info("Migration succeeded on chain...", "hash", result.TxHash, "timestamp", result.BlockTime, "gasUsed", result.GasUsed, "fee", result.Fee.String())
 	// Set the status to COMPLETED
-	if err = setAsCompleted(r, newItem, txHash, blockTime); err != nil {
-		return errors.WithMessage(err, "error setting status to COMPLETED")
+	err = traceStep(ctx, item, "setAsCompleted", func(ctx context.Context) error {
+		return setAsCompleted(ctx, r, &newItem, result)
+	})
+	if err != nil {
+		// The tokens were sent, the work item must never be migrated again
+		return &notRecordedError{TxHash: result.TxHash, Err: errors.WithMessage(err, "error setting status to COMPLETED")}
 	}
 
-	// Delete the state file, as the work item is now completed and the state is stored in the database
-	if err = deleteState(&newItem); err != nil {
-		return errors.WithMessage(err, "error deleting state")
+	// Archive the state file, as the work item is now completed and the state is stored in the database.
+	// The archived state keeps the gas used and the fee paid, which are not stored remotely.
+	if err = archiveState(&newItem); err != nil {
+		return errors.WithMessage(err, "error archiving state")
 	}
 
 	slog.Info("Migration complete", "uuid", newItem.UUID)
 
+	_ = traceStep(ctx, item, "checkBankBalance", func(ctx context.Context) error {
+		checkBankBalance(ctx, destination, *tokenInfo)
+		return nil
+	})
+
 	return nil
 }
 
-func deleteState(item *store.WorkItem) error {
-	slog.Info("Deleting local state file...")
-	if err := os.Remove(fmt.Sprintf("%s.json", item.UUID)); err != nil {
-		return errors.WithMessage(err, "error deleting state")
+// traceStep runs a migration step in its own span
+func traceStep(ctx context.Context, item *store.WorkItem, name string, step func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, "migrate."+name, tracing.Neighborhood(item.Neighborhood), tracing.WorkItem(item.UUID.String()))
+	err := step(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func archiveState(item *store.WorkItem) error {
+	slog.Info("Archiving local state file...")
+	if err := store.ArchiveWorkItem(*item); err != nil {
+		return errors.WithMessage(err, "error archiving state")
 	}
 	return nil
 }
 
 // setAsMigrating sets the status of the work item to MIGRATING and updates the state.
-func setAsMigrating(r *resty.Client, newItem store.WorkItem) error {
-	newItem.Status = store.MIGRATING
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+func setAsMigrating(ctx context.Context, r *resty.Client, item *store.WorkItem) error {
+	newItem := *item
+	if err := newItem.Transition(store.MIGRATING); err != nil {
+		return err
+	}
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to MIGRATING")
 	}
+	*item = newItem
 	return nil
 }
 
 // setAsCompleted sets the status of the work item to COMPLETED.
-// It also sets the manifest hash and updates the state.
-func setAsCompleted(r *resty.Client, newItem store.WorkItem, txHash *string, blockTime *time.Time) error {
-	newItem.Status = store.COMPLETED
-	newItem.ManifestHash = txHash
-	newItem.ManifestDatetime = blockTime
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+// It also sets the manifest hash, the gas used and the fee, and updates the state.
+func setAsCompleted(ctx context.Context, r *resty.Client, item *store.WorkItem, result *manifest.MigrateResult) error {
+	newItem := *item
+	if err := newItem.Transition(store.COMPLETED); err != nil {
+		return err
+	}
+	fee := result.Fee.String()
+	newItem.ManifestHash = &result.TxHash
+	newItem.ManifestDatetime = result.BlockTime
+	newItem.GasUsed = &result.GasUsed
+	newItem.Fee = &fee
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to COMPLETED")
 	}
+	*item = newItem
 	return nil
 }
 
-func setAsFailed(r *resty.Client, newItem store.WorkItem, errStr *string) error {
-	newItem.Status = store.FAILED
+// setAsFailed sets the status of the work item to FAILED with the given error and updates the state.
+func setAsFailed(ctx context.Context, r *resty.Client, item *store.WorkItem, errStr *string) error {
+	newItem := *item
+	if err := newItem.Transition(store.FAILED); err != nil {
+		return err
+	}
 
 	// Truncate the error string if it is too long (Talib limitation)
 	maxLen := 8192
@@ -355,22 +595,28 @@ func setAsFailed(r *resty.Client, newItem store.WorkItem, errStr *string) error
 	}
 	newItem.Error = errStr
 
-	if err := store.UpdateWorkItemAndSaveState(r, newItem); err != nil {
+	if err := store.UpdateWorkItemAndSaveState(ctx, r, newItem); err != nil {
 		return errors.WithMessage(err, "error setting status to FAILED")
 	}
+	*item = newItem
 	return nil
 }
 
 // sendTokens sends the tokens from the bank account to the user account.
-func sendTokens(item *store.WorkItem, config config.MigrateConfig, denom string, amount *big.Int) (*string, *time.Time, error) {
-	txResponse, blockTime, err := manifest.Migrate(item, config, denom, amount)
-	if err != nil {
-		return nil, nil, errors.WithMessage(err, "error during migration, operator intervention required")
+func sendTokens(ctx context.Context, item *store.WorkItem, config config.MigrateConfig, tokenInfo utils.TokenInfo, amount *big.Int) (*manifest.MigrateResult, error) {
+	result, err := manifest.Migrate(ctx, item, config, tokenInfo, amount)
+	if manifest.IsRetryable(err) {
+		return nil, err
+	}
+
+	var txErr *manifest.TxError
+	if errors.As(err, &txErr) {
+		return nil, errors.WithMessagef(err, "migration failed: %s", txErr.Reason())
 	}
 
-	if txResponse.Code != 0 {
-		return nil, nil, errors.WithMessagef(err, "migration failed: %s", txResponse.RawLog)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error during migration, operator intervention required")
 	}
 
-	return &txResponse.TxHash, blockTime, nil
+	return result, nil
 }
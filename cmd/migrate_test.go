@@ -2,15 +2,33 @@ package cmd_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/jarcoal/httpmock"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/many"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
 	"github.com/liftedinit/mfx-migrator/testutils"
 )
 
@@ -79,3 +97,216 @@ func TestMigrateCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateCmd_ErrorHandling(t *testing.T) {
+	lowBalanceChain := testutils.DefaultFakeChain
+	lowBalanceChain.BalanceOutputs = []string{testutils.FakeBalanceOutput("999", "umfx")}
+
+	tt := []struct {
+		name       string
+		chain      testutils.FakeChain
+		migration  httpmock.Responder
+		amount     string
+		lowBalance uint64
+		routed     bool // Route the token to the `other` destination
+		unmapped   bool // Remove the token from the token map
+		denylisted bool // Denylist the MANIFEST destination address
+		notified   []string
+		check      func(t *testing.T, err error, item *store.WorkItem)
+		calls      func(t *testing.T, calls []string) // Checks the fake chain invocations (optional)
+	}{
+		{name: "success", chain: testutils.DefaultFakeChain, amount: "100", check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.NoError(t, err)
+			require.Nil(t, item) // The state file is archived, keeping the gas used and the fee
+			item, aErr := store.LoadCompleted(0, testutils.Uuid)
+			require.NoError(t, aErr)
+			require.Equal(t, store.COMPLETED, item.Status)
+			require.Equal(t, uint64(81234), *item.GasUsed)
+			require.Equal(t, "110umfx", *item.Fee)
+		}},
+		{name: "low_balance", chain: lowBalanceChain, amount: "100", lowBalance: 1000, notified: []string{"low-balance"}, check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.NoError(t, err)
+			require.Nil(t, item)
+		}},
+		{name: "retryable", chain: testutils.DefaultFakeChain, amount: "100", migration: httpmock.NewStringResponder(http.StatusServiceUnavailable, ""), check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.ErrorIs(t, err, store.ErrUnavailable)
+			require.Equal(t, store.CLAIMED, item.Status)
+			require.Nil(t, item.Error)
+		}},
+		{name: "terminal", chain: testutils.DefaultFakeChain, amount: "99", notified: []string{"failed"}, check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.ErrorIs(t, err, many.ErrInvalidTx)
+			require.Nil(t, item) // The state file is quarantined
+			item, qErr := store.LoadQuarantined(0, testutils.Uuid)
+			require.NoError(t, qErr)
+			require.NotNil(t, item.Quarantine)
+			require.Equal(t, *item.Error, item.Quarantine.Reason)
+			require.Equal(t, store.FAILED, item.Status)
+			require.Contains(t, *item.Error, "amount must be greater")
+			require.Len(t, item.Transitions, 1)
+			require.Equal(t, store.CLAIMED, item.Transitions[0].From)
+		}},
+		{name: "escalated", chain: testutils.FakeChain{GasEstimate: "100000", AccountOutputs: testutils.DefaultFakeChain.AccountOutputs, KeyOutputs: testutils.DefaultFakeChain.KeyOutputs, BalanceOutputs: testutils.DefaultFakeChain.BalanceOutputs}, amount: "100", notified: []string{"unknown-outcome"}, check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+			require.Equal(t, store.MIGRATING, item.Status)
+			require.Nil(t, item.Error)
+			require.NotNil(t, item.UnknownOutcome)
+			require.NotNil(t, item.Broadcast) // The signed sequence is recorded
+			require.Equal(t, uint64(5), *item.Broadcast.Sequence)
+			require.Len(t, item.Transitions, 1)
+			require.Equal(t, store.MIGRATING, item.Transitions[0].To)
+		}},
+		{name: "denylisted", chain: testutils.DefaultFakeChain, amount: "100", denylisted: true, notified: []string{"held"}, check: func(t *testing.T, err error, item *store.WorkItem) {
+			var matchErr *denylist.MatchError
+			require.ErrorAs(t, err, &matchErr)
+			require.Equal(t, store.CLAIMED, item.Status)
+			require.NotNil(t, item.Hold)
+		}},
+		{name: "insufficient_balance", chain: lowBalanceChain, amount: "100000000", check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.ErrorIs(t, err, manifest.ErrInsufficientBalance)
+			require.Equal(t, store.CLAIMED, item.Status)
+			require.Nil(t, item.Error)
+		}, calls: func(t *testing.T, calls []string) {
+			for _, call := range calls {
+				require.NotContains(t, call, "tx bank send")
+			}
+		}},
+		{name: "unmapped_token", chain: testutils.DefaultFakeChain, amount: "100", unmapped: true, check: func(t *testing.T, err error, item *store.WorkItem) {
+			// A configuration error is fixed by the operator, the work item is left untouched
+			require.ErrorIs(t, err, config.ErrInvalidConfig)
+			require.Equal(t, store.CLAIMED, item.Status)
+			require.Nil(t, item.Error)
+		}},
+		{name: "routed", chain: testutils.DefaultFakeChain, amount: "100", routed: true, check: func(t *testing.T, err error, item *store.WorkItem) {
+			require.NoError(t, err)
+			require.Nil(t, item)
+			require.FileExists(t, "bank-sequence-other.state")
+			require.NoFileExists(t, manifest.SequenceFile)
+		}, calls: func(t *testing.T, calls []string) {
+			for _, call := range calls {
+				if strings.HasPrefix(call, "tx bank send") {
+					require.Contains(t, call, "tx bank send other-bank ")
+					require.Contains(t, call, "--chain-id other-1")
+					if !strings.Contains(call, "--dry-run") {
+						require.Contains(t, call, "--fees 110uother")
+					}
+				}
+			}
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, os.Chdir(t.TempDir()))
+			testutils.SetupWorkItem(t)
+			if tc.lowBalance > 0 {
+				viper.Set("token-map", map[string]utils.TokenInfo{"dummy": {Denom: "umfx", LowBalance: tc.lowBalance}})
+			}
+			if tc.unmapped {
+				viper.Set("token-map", map[string]utils.TokenInfo{"other": {Denom: "umfx"}})
+			}
+			if tc.routed {
+				viper.Set("token-map", map[string]utils.TokenInfo{"dummy": {Denom: "umfx", Destination: "other"}})
+				viper.Set("destinations", map[string]any{
+					"other": map[string]any{"chain-id": "other-1", "bank-address": "other-bank", "gas-denom": "uother"},
+				})
+				t.Cleanup(func() { viper.Set("destinations", nil) })
+			}
+			binary := testutils.NewFakeChainBinary(t, tc.chain)
+
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+			var notified []string
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload struct{ Kind string }
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+				notified = append(notified, payload.Kind)
+			}))
+			defer webhook.Close()
+
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			client := resty.New()
+			command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+			httpmock.ActivateNonDefault(client.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			migration := tc.migration
+			if migration == nil {
+				migration = testutils.MustMigrationGetResponder(store.CLAIMED)
+			}
+			httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+			httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.WhiteListResponder)
+			httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultMigrationUrl, migration)
+			httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultTransactionUrl, testutils.MustNewLedgerSendTransactionResponseResponder(tc.amount))
+			httpmock.RegisterResponder("PUT", "=~^"+testutils.DefaultMigrationUrl, testutils.MigrationUpdateResponder)
+
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+
+			args := []string{
+				"--uuid", testutils.Uuid,
+				"--url", testutils.RootUrl,
+				"--username", "user",
+				"--password", "pass",
+				"--chain-home", t.TempDir(),
+				"--fee-granter", "feegranter",
+				"--binary", binary,
+				"--broadcast-retries", "0",
+				"--notify-webhook-url", webhook.URL,
+				"--notify-state-file", filepath.Join(t.TempDir(), "notify-state.json"),
+			}
+			if tc.denylisted {
+				denylistFile := filepath.Join(t.TempDir(), "denylist.txt")
+				require.NoError(t, os.WriteFile(denylistFile, []byte(testutils.ManifestAddress+"\n"), 0600))
+				args = append(args, "--denylist-file", denylistFile)
+			}
+			out, err := testutils.Execute(t, command, args...)
+			require.Equal(t, tc.notified, notified, out)
+			requireMigrateSpans(t, recorder.Ended(), err)
+
+			item, lErr := store.LoadState(0, testutils.Uuid)
+			if lErr != nil {
+				item = nil
+			}
+			tc.check(t, err, item)
+			if tc.calls != nil {
+				tc.calls(t, testutils.FakeChainCalls(t, binary))
+			}
+		})
+	}
+}
+
+// requireMigrateSpans verifies the migration spans are children of the root span and carry the work item UUID
+func requireMigrateSpans(t *testing.T, spans []sdktrace.ReadOnlySpan, err error) {
+	t.Helper()
+
+	names := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spans {
+		names[span.Name()] = span
+	}
+
+	root, ok := names["migrate"]
+	require.True(t, ok, "missing root span")
+	require.Contains(t, root.Attributes(), tracing.WorkItem(testutils.Uuid))
+	if err != nil {
+		require.Equal(t, codes.Error, root.Status().Code)
+	} else {
+		require.Equal(t, codes.Unset, root.Status().Code)
+	}
+
+	for _, name := range []string{"authenticate", "migrate.verifyManyAddressIsAllowed", "migrate.getRemoteWorkItem", "store.GetWorkItem"} {
+		span, ok := names[name]
+		require.True(t, ok, "missing span %s", name)
+		require.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID(), name)
+	}
+	require.Contains(t, names["store.GetWorkItem"].Attributes(), tracing.WorkItem(testutils.Uuid))
+
+	if err == nil {
+		for _, name := range []string{"migrate.sendTokens", "migrate.setAsMigrating", "migrate.setAsCompleted", "store.UpdateWorkItemAndSaveState", "executeCommand", "executeCommandCombined"} {
+			_, ok := names[name]
+			require.True(t, ok, "missing span %s", name)
+		}
+		require.Contains(t, names["migrate.sendTokens"].Attributes(), tracing.WorkItem(testutils.Uuid))
+	}
+}
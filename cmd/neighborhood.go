@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// LoadNeighborhoodsFromCLI loads the IDs of the neighborhoods to process.
+// It defaults to the neighborhood setting when no list is configured.
+func LoadNeighborhoodsFromCLI() ([]uint64, error) {
+	var neighborhoods []uint64
+	for _, value := range viper.GetStringSlice("neighborhoods") {
+		// The environment variable holds a comma or space separated list
+		for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			id, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, utils.Mark(fmt.Errorf("could not parse neighborhood ID %s: %w", field, err), config.ErrInvalidConfig)
+			}
+			neighborhoods = append(neighborhoods, id)
+		}
+	}
+
+	if len(neighborhoods) == 0 {
+		neighborhoods = []uint64{viper.GetUint64("neighborhood")}
+	}
+
+	if err := config.ValidateNeighborhoods(neighborhoods); err != nil {
+		return nil, err
+	}
+
+	return neighborhoods, nil
+}
+
+// LoadNeighborhoodOverridesFromCLI loads the per-neighborhood overrides of the configuration file
+func LoadNeighborhoodOverridesFromCLI() (config.NeighborhoodOverrides, error) {
+	var overrides config.NeighborhoodOverrides
+	if err := unmarshalStructuredKey("neighborhood-overrides", &overrides); err != nil {
+		return nil, utils.Mark(fmt.Errorf("could not parse neighborhood overrides: %w", err), config.ErrInvalidConfig)
+	}
+	return overrides, nil
+}
+
+// applyNeighborhoodOverrides overrides the migrate and whitelist settings with the settings of the neighborhood
+func applyNeighborhoodOverrides(neighborhood uint64, m *config.MigrateConfig, w *config.WhitelistConfig) error {
+	overrides, err := LoadNeighborhoodOverridesFromCLI()
+	if err != nil {
+		return err
+	}
+	overrides.Apply(neighborhood, m, w)
+	return nil
+}
+
+// validateNeighborhoods validates the neighborhoods, their overrides and the whitelist settings of each of them
+func validateNeighborhoods() error {
+	neighborhoods, err := LoadNeighborhoodsFromCLI()
+	if err != nil {
+		return err
+	}
+
+	overrides, err := LoadNeighborhoodOverridesFromCLI()
+	if err != nil {
+		return err
+	}
+
+	for _, neighborhood := range neighborhoods {
+		var m config.MigrateConfig
+		w := LoadWhitelistConfigFromCLI()
+		overrides.Apply(neighborhood, &m, &w)
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("neighborhood %d: %w", neighborhood, err)
+		}
+	}
+
+	return nil
+}
+
+func setupNeighborhoodFlags(command *cobra.Command) {
+	command.PersistentFlags().Uint64("neighborhood", 0, "Neighborhood ID")
+	if err := viper.BindPFlag("neighborhood", command.PersistentFlags().Lookup("neighborhood")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().StringSlice("neighborhoods", nil, "IDs of the neighborhoods to claim work items from (default the neighborhood)")
+	if err := viper.BindPFlag("neighborhoods", command.PersistentFlags().Lookup("neighborhoods")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
@@ -0,0 +1,74 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+func TestLoadNeighborhoodsFromCLI(t *testing.T) {
+	newConfigCmd("validate", cmd.ConfigValidateCmdRunE) // Bind the root and migrate flags
+	t.Setenv("MFX_MIGRATOR_NEIGHBORHOOD", "3")
+
+	neighborhoods, err := cmd.LoadNeighborhoodsFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, neighborhoods)
+
+	t.Setenv("MFX_MIGRATOR_NEIGHBORHOODS", "1,2 5")
+	neighborhoods, err = cmd.LoadNeighborhoodsFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 5}, neighborhoods)
+
+	t.Setenv("MFX_MIGRATOR_NEIGHBORHOODS", "1,1")
+	_, err = cmd.LoadNeighborhoodsFromCLI()
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+	require.ErrorContains(t, err, "duplicate neighborhood: 1")
+
+	t.Setenv("MFX_MIGRATOR_NEIGHBORHOODS", "one")
+	_, err = cmd.LoadNeighborhoodsFromCLI()
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+}
+
+func TestNeighborhoodOverrides(t *testing.T) {
+	newConfigCmd("validate", cmd.ConfigValidateCmdRunE) // Bind the root and migrate flags
+	viper.Set("neighborhood-overrides", map[string]any{
+		"2": map[string]any{
+			"token-map":      map[string]any{"dummy": map[string]any{"denom": "utwo"}},
+			"whitelist-mode": "file",
+			"whitelist-file": "allowlist-2.json",
+		},
+	})
+	t.Cleanup(func() { viper.Set("neighborhood-overrides", nil) })
+
+	overrides, err := cmd.LoadNeighborhoodOverridesFromCLI()
+	require.NoError(t, err)
+
+	topLevel := func() (config.MigrateConfig, config.WhitelistConfig) {
+		return config.MigrateConfig{TokenMap: map[string]utils.TokenInfo{"dummy": {Denom: "umfx"}}},
+			config.WhitelistConfig{Mode: "remote", CacheFile: "whitelist-cache.json", PublicKey: "key"}
+	}
+
+	m, w := topLevel()
+	overrides.Apply(2, &m, &w)
+	require.Equal(t, map[string]utils.TokenInfo{"dummy": {Denom: "utwo"}}, m.TokenMap)
+	require.Equal(t, config.WhitelistConfig{Mode: "file", CacheFile: "whitelist-cache.neighborhood-2.json", File: "allowlist-2.json", PublicKey: "key"}, w)
+
+	// A neighborhood without overrides keeps the top-level settings
+	m, w = topLevel()
+	overrides.Apply(0, &m, &w)
+	expectedM, expectedW := topLevel()
+	require.Equal(t, expectedM, m)
+	require.Equal(t, expectedW, w)
+
+	// The environment variable holds the overrides as JSON
+	viper.Set("neighborhood-overrides", nil)
+	t.Setenv("MFX_MIGRATOR_NEIGHBORHOOD_OVERRIDES", `{"3": {"whitelist-mode": "combined"}}`)
+	overrides, err = cmd.LoadNeighborhoodOverridesFromCLI()
+	require.NoError(t, err)
+	require.Equal(t, "combined", overrides[3].WhitelistMode)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/notify"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// notifier sends the operator notifications. It is set up by RootCmdPersistentPreRunE.
+var notifier *notify.Dispatcher
+
+// notifyTimeout bounds the notification of a migration error, which is sent even if the migration was cancelled.
+// It fits in the time the container runtime leaves after the default shutdown grace period.
+const notifyTimeout = 2 * time.Second
+
+func LoadNotifyConfigFromCLI() config.NotifyConfig {
+	return config.NotifyConfig{
+		SlackUrls:            viper.GetStringSlice("notify-slack-url"),
+		DiscordUrls:          viper.GetStringSlice("notify-discord-url"),
+		WebhookUrls:          viper.GetStringSlice("notify-webhook-url"),
+		Template:             viper.GetString("notify-template"),
+		DedupWindow:          viper.GetUint("notify-dedup-window"),
+		RateLimit:            viper.GetUint("notify-rate-limit"),
+		RateLimitWindow:      viper.GetUint("notify-rate-limit-window"),
+		AuthFailureThreshold: viper.GetUint("notify-auth-failure-threshold"),
+		StateFile:            viper.GetString("notify-state-file"),
+	}
+}
+
+// setupNotifier creates the notifier from the CLI flags
+func setupNotifier() error {
+	notifyConfig := LoadNotifyConfigFromCLI()
+	slog.Debug("args", "notify-c", notifyConfig)
+	if err := notifyConfig.Validate(); err != nil {
+		return err
+	}
+
+	d, err := notify.NewDispatcher(notifyConfig)
+	if err != nil {
+		return errors.WithMessage(err, "unable to create notifier")
+	}
+	notifier = d
+
+	return nil
+}
+
+func setupNotifyFlags(command *cobra.Command) {
+	sliceArgs := []struct {
+		name  string
+		usage string
+	}{
+		{"notify-slack-url", "Slack incoming webhook URL notified of the events requiring an operator (can be repeated)"},
+		{"notify-discord-url", "Discord webhook URL notified of the events requiring an operator (can be repeated)"},
+		{"notify-webhook-url", "Generic JSON webhook URL notified of the events requiring an operator (can be repeated)"},
+	}
+	for _, arg := range sliceArgs {
+		command.PersistentFlags().StringSlice(arg.name, nil, arg.usage)
+		if err := viper.BindPFlag(arg.name, command.PersistentFlags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+
+	stringArgs := []struct {
+		name  string
+		value string
+		usage string
+	}{
+		{"notify-template", "", "Go template of the notification text"},
+		{"notify-state-file", "notify-state.json", "File holding the notification deduplication and rate limiting state"},
+	}
+	for _, arg := range stringArgs {
+		command.PersistentFlags().String(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.name, command.PersistentFlags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+
+	uintArgs := []struct {
+		name  string
+		value uint
+		usage string
+	}{
+		{"notify-dedup-window", 3600, "Number of seconds during which an identical notification is not sent again"},
+		{"notify-rate-limit", 20, "Maximum number of notifications sent per rate limit window (0 = no limit)"},
+		{"notify-rate-limit-window", 3600, "Number of seconds of the notification rate limit window"},
+		{"notify-auth-failure-threshold", 3, "Number of consecutive Talib authentication failures triggering a notification"},
+	}
+	for _, arg := range uintArgs {
+		command.PersistentFlags().Uint(arg.name, arg.value, arg.usage)
+		if err := viper.BindPFlag(arg.name, command.PersistentFlags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+}
+
+// notifyMigrationError notifies the operator of the migration errors requiring an intervention
+func notifyMigrationError(ctx context.Context, item *store.WorkItem, class errorClass, err error) {
+	// The migration context is cancelled when the shutdown grace period expires, when the notification matters the most
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	uuid := item.UUID.String()
+	neighborhood := item.Neighborhood
+	switch {
+	case errors.Is(err, manifest.ErrFeeBudgetExceeded):
+		// Every broadcast is refused until the budget is replenished, notify once
+		notifier.Notify(ctx, notify.Event{Kind: notify.CircuitBreakerTripped, Key: "circuit-breaker:daily-fee-budget", Neighborhood: neighborhood, UUID: uuid, Message: err.Error()})
+	case errors.Is(err, manifest.ErrUnknownOutcome):
+		event := notify.Event{Kind: notify.UnknownOutcome, Neighborhood: neighborhood, UUID: uuid, Message: err.Error()}
+		var outcomeErr *manifest.UnknownOutcomeError
+		if errors.As(err, &outcomeErr) {
+			event.Fields = map[string]string{"txHash": outcomeErr.TxHash}
+		}
+		notifier.Notify(ctx, event)
+	case class == escalatedError:
+		// A screened address matched a denylist, or the tokens were sent but the work item was not updated
+		event := notify.Event{Kind: notify.WorkItemHeld, Neighborhood: neighborhood, UUID: uuid, Message: err.Error()}
+		var notRecordedErr *notRecordedError
+		var matchErr *denylist.MatchError
+		switch {
+		case errors.As(err, &notRecordedErr):
+			event.Fields = map[string]string{"txHash": notRecordedErr.TxHash}
+		case errors.As(err, &matchErr):
+			event.Fields = map[string]string{"address": matchErr.Address, "source": matchErr.Source}
+		}
+		notifier.Notify(ctx, event)
+	case class == terminalError:
+		notifier.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, Neighborhood: neighborhood, UUID: uuid, Message: err.Error()})
+	}
+}
+
+// checkBankBalance notifies the operator when the bank balance of the token is below its low balance threshold.
+// The balance is the one of the bank account of the destination of the config.
+func checkBankBalance(ctx context.Context, c config.MigrateConfig, tokenInfo utils.TokenInfo) {
+	if tokenInfo.LowBalance == 0 {
+		return
+	}
+
+	balance, err := manifest.QueryBalance(ctx, c, tokenInfo.Denom)
+	if err != nil {
+		slog.Warn("Unable to check bank balance", "denom", tokenInfo.Denom, "warning", err)
+		return
+	}
+
+	if balance.Cmp(new(big.Int).SetUint64(tokenInfo.LowBalance)) >= 0 {
+		return
+	}
+
+	slog.Warn("Low bank balance", "destination", c.Destination, "denom", tokenInfo.Denom, "balance", balance.String(), "threshold", tokenInfo.LowBalance)
+	// The bank accounts of the destinations are funded independently, the default destination has an empty name
+	event := notify.Event{
+		Kind:    notify.LowBalance,
+		Key:     "low-balance:" + c.Destination + ":" + tokenInfo.Denom,
+		Message: balance.String() + tokenInfo.Denom + " left in the bank account",
+		Fields:  map[string]string{"denom": tokenInfo.Denom, "balance": balance.String()},
+	}
+	if c.Destination != "" {
+		event.Message += " of destination " + c.Destination
+		event.Fields["destination"] = c.Destination
+	}
+	notifier.Notify(ctx, event)
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/whitelist"
+)
+
+// processCmd represents the process command
+var processCmd = &cobra.Command{
+	Use:   "process",
+	Short: "Migrate every claimed local work item of the neighborhoods, oldest first",
+	Long: `Migrate every claimed or migrating work item having a local state file, in the neighborhoods of --neighborhoods.
+The work items are migrated one at a time, oldest first, with a single authenticated client. A work item failing does not
+prevent the migration of the others. The command fails if any work item was not migrated.`,
+	RunE: ProcessCmdRunE,
+}
+
+// processSummary counts the processed work items by outcome
+type processSummary struct {
+	Migrated  int // Completed
+	Postponed int // Left untouched after a retryable error, the migration will be retried
+	Failed    int // Failed and quarantined, or the local state could not be loaded
+	Escalated int // Held or with an unknown outcome, waiting for an operator
+	Skipped   int // Not ready for migration, e.g., held by a previous run
+}
+
+// record counts the outcome of the migration of a work item
+func (s *processSummary) record(err error) {
+	if err == nil {
+		s.Migrated++
+		return
+	}
+
+	switch classifyError(err) {
+	case retryableError:
+		s.Postponed++
+	case escalatedError:
+		s.Escalated++
+	default:
+		s.Failed++
+	}
+}
+
+// total returns the number of work items processed
+func (s processSummary) total() int {
+	return s.Migrated + s.Postponed + s.Failed + s.Escalated + s.Skipped
+}
+
+// print writes the summary to the command output and logs it
+func (s processSummary) print(w io.Writer) {
+	slog.Info("Processing complete", "total", s.total(), "migrated", s.Migrated, "postponed", s.Postponed, "failed", s.Failed, "escalated", s.Escalated, "skipped", s.Skipped)
+	fmt.Fprintf(w, "Processed %d work item(s): %d migrated, %d postponed, %d failed, %d escalated, %d skipped\n",
+		s.total(), s.Migrated, s.Postponed, s.Failed, s.Escalated, s.Skipped)
+}
+
+// err returns an error if any work item was not migrated. The work items skipped are not errors.
+func (s processSummary) err() error {
+	if n := s.Postponed + s.Failed + s.Escalated; n > 0 {
+		return fmt.Errorf("%d of %d work item(s) not migrated", n, s.total())
+	}
+	return nil
+}
+
+// processNeighborhood holds the settings the work items of a neighborhood are migrated with
+type processNeighborhood struct {
+	live            *LiveMigrateConfig
+	whitelistConfig config.WhitelistConfig
+	provider        whitelist.Provider
+}
+
+func ProcessCmdRunE(cmd *cobra.Command, args []string) (err error) {
+	c := LoadConfigFromCLI("")
+	slog.Debug("args", "c", c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	neighborhoods, err := LoadNeighborhoodsFromCLI()
+	if err != nil {
+		return err
+	}
+
+	// Load and validate the settings of every neighborhood once
+	settings := make(map[uint64]*processNeighborhood, len(neighborhoods))
+	for _, neighborhood := range neighborhoods {
+		migrateConfig, whitelistConfig, err := loadNeighborhoodConfigFromCLI(cmd.Context(), neighborhood)
+		if err != nil {
+			return fmt.Errorf("neighborhood %d: %w", neighborhood, err)
+		}
+		settings[neighborhood] = &processNeighborhood{
+			live:            NewLiveMigrateConfig(cmd, neighborhood, migrateConfig),
+			whitelistConfig: whitelistConfig,
+		}
+	}
+
+	authConfig := LoadAuthConfigFromCLI()
+	if err := resolveAuthSecrets(cmd.Context(), &authConfig); err != nil {
+		return err
+	}
+	slog.Debug("args", "auth-c", authConfig)
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	denylistConfig := LoadDenylistConfigFromCLI()
+	slog.Debug("args", "denylist-c", denylistConfig)
+	if err := denylistConfig.Validate(); err != nil {
+		return err
+	}
+
+	var summary processSummary
+	items := loadProcessableWorkItems(neighborhoods, &summary)
+	if len(items) == 0 {
+		summary.print(cmd.OutOrStdout())
+		return summary.err()
+	}
+
+	ctx, shutdown := startGracefulShutdown(cmd.Context())
+	defer shutdown.Close()
+
+	ctx, span := tracing.Start(ctx, "process")
+	defer func() { tracing.End(span, err) }()
+
+	// The settings reloaded before the migration of a work item starts apply to it
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	watchConfig(watchCtx, func(trigger string) {
+		for _, s := range settings {
+			_ = s.live.reload(trigger)
+		}
+	})
+
+	r := CreateRestClient(ctx, c.Url, c.Neighborhood)
+	if err := AuthenticateRestClient(ctx, r, authConfig.Username, authConfig.Password); err != nil {
+		return err
+	}
+
+	for _, s := range settings {
+		if s.provider, err = whitelist.NewProvider(s.whitelistConfig, r); err != nil {
+			return errors.WithMessage(err, "unable to create whitelist provider")
+		}
+	}
+	screener := denylist.NewScreenerFromConfig(denylistConfig)
+
+	for i, item := range items {
+		if err := checkShutdown(ctx); err != nil {
+			slog.Warn("Shutting down, the work items left are postponed", "count", len(items)-i)
+			summary.Postponed += len(items) - i
+			break
+		}
+
+		s := settings[item.Neighborhood]
+		summary.record(processWorkItem(ctx, r, item, s.live.Load(), s.provider, screener))
+	}
+
+	summary.print(cmd.OutOrStdout())
+	return summary.err()
+}
+
+// processWorkItem migrates a work item of the neighborhood in its own span
+func processWorkItem(ctx context.Context, r *resty.Client, item *store.WorkItem, config config.MigrateConfig, provider whitelist.Provider, screener *denylist.Screener) (err error) {
+	defer logging.WithWorkItem(item.Neighborhood, item.UUID.String())()
+
+	ctx, span := tracing.Start(ctx, "migrate", tracing.Neighborhood(item.Neighborhood), tracing.WorkItem(item.UUID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	r.SetPathParam("neighborhood", strconv.FormatUint(item.Neighborhood, 10))
+	return migrateWorkItem(ctx, r, item, config, provider, screener)
+}
+
+// loadProcessableWorkItems loads the local work items of the neighborhoods ready for migration, oldest first.
+// The work items whose state cannot be loaded are counted as failed and the ones not ready for migration as skipped.
+func loadProcessableWorkItems(neighborhoods []uint64, summary *processSummary) []*store.WorkItem {
+	var items []*store.WorkItem
+	for _, neighborhood := range neighborhoods {
+		uuids, err := store.ListStates(neighborhood)
+		if err != nil {
+			slog.Error("Unable to list local states", "neighborhood", neighborhood, "error", err)
+			summary.Failed++
+			continue
+		}
+
+		for _, uuidStr := range uuids {
+			item, err := store.LoadState(neighborhood, uuidStr)
+			if err != nil {
+				slog.Error("Unable to load state", "neighborhood", neighborhood, "uuid", uuidStr, "error", err)
+				summary.Failed++
+				continue
+			}
+
+			// A work item failed by a version of the migrator which did not quarantine the failed work items
+			if item.Status == store.FAILED {
+				quarantineFailedState(item)
+				summary.Skipped++
+				continue
+			}
+
+			if err := verifyItemStatus(item); err != nil {
+				slog.Info("Skipping work item", "neighborhood", neighborhood, "uuid", uuidStr, "reason", err)
+				summary.Skipped++
+				continue
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	// Oldest first, the work items without creation date last
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].CreatedDate, items[j].CreatedDate
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		default:
+			return items[i].UUID.String() < items[j].UUID.String()
+		}
+	})
+
+	slog.Info("Work items to process", "count", len(items))
+	return items
+}
+
+// quarantineFailedState moves the local state of a failed work item left in the state directory to the quarantine
+// directory, recording its error as the quarantine reason
+func quarantineFailedState(item *store.WorkItem) {
+	reason := "failed before quarantine"
+	if item.Error != nil {
+		reason = *item.Error
+	}
+	if err := store.QuarantineWorkItem(*item, reason); err != nil {
+		slog.Error("Unable to quarantine failed work item", "neighborhood", item.Neighborhood, "uuid", item.UUID, "error", err)
+	}
+}
+
+func SetupProcessCmdFlags(command *cobra.Command) {
+	setupMigrateSettingFlags(command)
+}
+
+func init() {
+	SetupProcessCmdFlags(processCmd)
+	rootCmd.AddCommand(processCmd)
+}
@@ -0,0 +1,100 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestProcessCmd(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+	testutils.SetupWorkItem(t)
+
+	// An older work item, not matching the remote work item, a held work item, a work item whose transaction was
+	// broadcast by an interrupted migration and a work item failed by an older migrator, not quarantined
+	older := testutils.CreatedDate.Add(-time.Hour)
+	mismatched := store.WorkItem{Status: store.CLAIMED, CreatedDate: &older, UUID: uuid.New(), ManyHash: testutils.ManyHash, ManifestAddress: testutils.ManifestAddress}
+	require.NoError(t, store.SaveState(&mismatched))
+	held := store.WorkItem{Status: store.CLAIMED, UUID: uuid.New(), Hold: &store.Hold{Reason: "denylisted"}}
+	require.NoError(t, store.SaveState(&held))
+	broadcast := store.WorkItem{Status: store.MIGRATING, UUID: uuid.New(), Broadcast: &store.Broadcast{TxHash: testutils.FakeTxHash}}
+	require.NoError(t, store.SaveState(&broadcast))
+	failedErr := "amount must be greater than 0"
+	failed := store.WorkItem{Status: store.FAILED, UUID: uuid.New(), Error: &failedErr}
+	require.NoError(t, store.SaveState(&failed))
+	require.NoError(t, os.WriteFile("config.json", []byte("{}"), 0600))
+
+	binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+
+	command := &cobra.Command{Use: "process", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.ProcessCmdRunE}
+	client := resty.New()
+	command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+	httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.WhiteListResponder)
+	httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultMigrationUrl, testutils.MustMigrationGetResponder(store.CLAIMED))
+	httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultTransactionUrl, testutils.MustNewLedgerSendTransactionResponseResponder("100"))
+	httpmock.RegisterResponder("PUT", "=~^"+testutils.DefaultMigrationUrl, testutils.MigrationUpdateResponder)
+
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupProcessCmdFlags(command)
+
+	out, err := testutils.Execute(t, command,
+		"--url", testutils.RootUrl,
+		"--username", "user",
+		"--password", "pass",
+		"--chain-home", t.TempDir(),
+		"--fee-granter", "feegranter",
+		"--binary", binary,
+		"--broadcast-retries", "0",
+		"--notify-state-file", filepath.Join(t.TempDir(), "notify-state.json"),
+	)
+	require.ErrorContains(t, err, "1 of 5 work item(s) not migrated")
+	require.Contains(t, out, "Processed 5 work item(s): 1 migrated, 0 postponed, 1 failed, 0 escalated, 3 skipped")
+
+	// A single login for every work item
+	info := httpmock.GetCallCountInfo()
+	require.Equal(t, 1, info["POST "+testutils.LoginUrl])
+
+	// The migrated work item state is archived, the failed one quarantined and the held one left untouched
+	require.NoFileExists(t, store.StatePath(0, testutils.Uuid))
+	require.FileExists(t, store.CompletedPath(0, testutils.Uuid))
+	require.NoFileExists(t, store.StatePath(0, mismatched.UUID.String()))
+	quarantined, err := store.LoadQuarantined(0, mismatched.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.FAILED, quarantined.Status)
+	require.Contains(t, quarantined.Quarantine.Reason, "local and remote work items do not match")
+	_, err = store.LoadState(0, held.UUID.String())
+	require.NoError(t, err)
+	require.NoFileExists(t, store.StatePath(0, failed.UUID.String()))
+	quarantined, err = store.LoadQuarantined(0, failed.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, failedErr, quarantined.Quarantine.Reason)
+}
+
+func TestProcessCmd_NothingToProcess(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+
+	command := &cobra.Command{Use: "process", RunE: cmd.ProcessCmdRunE}
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupProcessCmdFlags(command)
+	setupMigrateEnv(t)
+
+	out, err := testutils.Execute(t, command, append([]string{"--binary", "sh"}, validConfigArgs...)...)
+	require.NoError(t, err)
+	require.Contains(t, out, "Processed 0 work item(s)")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// profileSettings maps the settings set by the selected profile to the profile name
+var profileSettings = map[string]string{}
+
+// applyProfile applies the settings of the selected profile, if any.
+// The flags and the environment variables take precedence over the profile, which takes precedence over the top-level
+// settings of the configuration file.
+func applyProfile(cmd *cobra.Command) error {
+	profileSettings = map[string]string{}
+	name := viper.GetString("profile")
+	if name == "" {
+		return nil
+	}
+
+	profile := viper.Sub("profiles." + name)
+	if profile == nil {
+		return utils.Mark(fmt.Errorf("profile %s not found", name), config.ErrInvalidConfig)
+	}
+
+	for key, value := range profile.AllSettings() {
+		if key == "environment" {
+			continue
+		}
+
+		if !slices.Contains(config.ProfileKeys, key) {
+			return utils.Mark(fmt.Errorf("setting %s cannot be set by profile %s", key, name), config.ErrInvalidConfig)
+		}
+
+		if flag := cmd.Flags().Lookup(key); (flag != nil && flag.Changed) || isEnvSet(settingEnv(key)) {
+			slog.Debug("Profile setting overridden", "profile", name, "key", key)
+			continue
+		}
+
+		viper.Set(key, value)
+		profileSettings[key] = name
+	}
+
+	slog.Info("Using profile", "profile", name)
+	return nil
+}
+
+// checkProfileEnvironment refuses a chain ID and a Talib URL belonging to different environments.
+// Every effective chain ID is checked, i.e., the top-level one, the one of each destination and the one of the
+// destinations routed to by the neighborhood overrides.
+func checkProfileEnvironment() error {
+	var profiles config.Profiles
+	if err := viper.UnmarshalKey("profiles", &profiles); err != nil {
+		return utils.Mark(fmt.Errorf("could not parse profiles: %w", err), config.ErrInvalidConfig)
+	}
+
+	if err := profiles.Validate(); err != nil {
+		return err
+	}
+
+	migrateConfig, err := LoadMigrationConfigFromCLI()
+	if err != nil {
+		return err
+	}
+	overrides, err := LoadNeighborhoodOverridesFromCLI()
+	if err != nil {
+		return err
+	}
+
+	return profiles.CheckEnvironment(viper.GetString("profile"), viper.GetString("url"), migrateConfig.ChainIDs(overrides)...)
+}
+
+func setupProfileFlags(command *cobra.Command) {
+	command.PersistentFlags().String("profile", "", "Name of the configuration profile to use, e.g., mainnet, testnet or local")
+	if err := viper.BindPFlag("profile", command.PersistentFlags().Lookup("profile")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+// setupProfiles sets mainnet and testnet profiles, as if read from the configuration file
+func setupProfiles(t *testing.T) {
+	viper.Set("profiles", map[string]any{
+		"mainnet": map[string]any{
+			"url":      "https://talib.example",
+			"chain-id": "manifest-1",
+		},
+		"testnet": map[string]any{
+			"url":          "https://testnet.talib.example/",
+			"neighborhood": 2,
+			"chain-id":     "manifest-testnet",
+			"token-map":    map[string]any{"dummy": map[string]any{"denom": "utest"}},
+		},
+	})
+	t.Cleanup(func() {
+		// The profile settings override the flags, reset them
+		viper.Set("profiles", nil)
+		for _, key := range config.ProfileKeys {
+			viper.Set(key, nil)
+		}
+	})
+}
+
+func TestProfile_Show(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+	setupProfiles(t)
+
+	out, err := execConfigCmd(t, "show", "--profile", "testnet", "--neighborhood", "3")
+	require.NoError(t, err)
+	require.Contains(t, out, `url = "https://testnet.talib.example/" (profile testnet)`)
+	require.Contains(t, out, `chain-id = "manifest-testnet" (profile testnet)`)
+	require.Contains(t, out, `token-map = {"dummy":{"denom":"utest"}} (profile testnet)`)
+	require.Contains(t, out, "neighborhood = 3 (flag)")
+}
+
+func TestProfile_Validate(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+	setupMigrateEnv(t)
+
+	tt := []struct {
+		name         string
+		args         []string
+		destinations map[string]any
+		overrides    map[string]any
+		err          error
+		out          string
+	}{
+		{name: "matching profile", args: []string{"--profile", "testnet"}},
+		{name: "no profile", args: []string{"--url", "https://talib.example"}},
+		{name: "unknown profile", args: []string{"--profile", "devnet"}, err: config.ErrInvalidConfig},
+		{name: "url of another environment", args: []string{"--profile", "testnet", "--url", "https://talib.example"}, err: config.ErrInvalidConfig,
+			out: "profile:         Talib URL https://talib.example belongs to the mainnet environment, not to the testnet environment of profile testnet"},
+		{name: "chain id of another environment", args: []string{"--url", "https://TESTNET.talib.example"}, err: config.ErrInvalidConfig,
+			out: "profile:         chain ID manifest-1 (mainnet) and Talib URL https://TESTNET.talib.example (testnet) belong to different environments"},
+		{name: "destination chain id of another environment", args: []string{"--url", "https://talib.example"}, err: config.ErrInvalidConfig,
+			destinations: map[string]any{"other": map[string]any{"chain-id": "manifest-testnet"}},
+			out:          "profile:         chain ID manifest-testnet (testnet) and Talib URL https://talib.example (mainnet) belong to different environments"},
+		{name: "neighborhood destination chain id of another environment", args: []string{"--profile", "mainnet"}, err: config.ErrInvalidConfig,
+			destinations: map[string]any{"other": map[string]any{"chain-id": "manifest-testnet"}},
+			overrides:    map[string]any{"3": map[string]any{"token-map": map[string]any{"dummy": map[string]any{"denom": "utest", "destination": "other"}}}},
+			out:          "profile:         chain ID manifest-testnet belongs to the testnet environment, not to the mainnet environment of profile mainnet"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			setupProfiles(t)
+			viper.Set("destinations", tc.destinations)
+			viper.Set("neighborhood-overrides", tc.overrides)
+			t.Cleanup(func() {
+				viper.Set("destinations", nil)
+				viper.Set("neighborhood-overrides", nil)
+			})
+			args := append([]string{"--username", "user", "--password", "pass"}, tc.args...)
+			out, err := execConfigCmd(t, "validate", args...)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+			require.Contains(t, out, tc.out)
+		})
+	}
+}
+
+func TestProfile_EnvironmentMismatch(t *testing.T) {
+	profiles := config.Profiles{
+		"mainnet": {Url: "https://talib.example", ChainID: "manifest-1"},
+		"staging": {Environment: "testnet", Url: "https://staging.talib.example", ChainID: "manifest-testnet"},
+	}
+	require.NoError(t, profiles.Validate())
+	require.NoError(t, profiles.CheckEnvironment("staging", "https://staging.talib.example", "manifest-testnet"))
+	require.NoError(t, profiles.CheckEnvironment("", "http://localhost:3001", "manifest-1"))
+	require.ErrorIs(t, profiles.CheckEnvironment("", "https://staging.talib.example", "manifest-1"), config.ErrEnvironmentMismatch)
+	require.ErrorIs(t, profiles.CheckEnvironment("mainnet", "http://localhost:3001", "manifest-testnet"), config.ErrEnvironmentMismatch)
+
+	// Every effective chain ID is checked
+	require.NoError(t, profiles.CheckEnvironment("mainnet", "https://talib.example", "manifest-1", "other-1"))
+	require.ErrorIs(t, profiles.CheckEnvironment("mainnet", "https://talib.example", "manifest-1", "manifest-testnet"), config.ErrEnvironmentMismatch)
+	require.ErrorIs(t, profiles.CheckEnvironment("", "https://talib.example", "manifest-1", "manifest-testnet"), config.ErrEnvironmentMismatch)
+
+	profiles["devnet"] = config.Profile{}
+	require.ErrorIs(t, profiles.Validate(), config.ErrInvalidConfig)
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// quarantineCmd represents the quarantine command group
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "List, inspect, restore and purge the quarantined work items",
+	Long: `The local state of a work item is moved to the quarantine directory when its migration fails and can never
+succeed. The remote work item is left untouched.`,
+}
+
+var quarantineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the quarantined work items, oldest first",
+	RunE:  QuarantineListCmdRunE,
+}
+
+var quarantineShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print a quarantined work item",
+	RunE:  QuarantineShowCmdRunE,
+}
+
+var quarantineRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Move a quarantined work item back to the state directory, as is; claim it again with --force to migrate it",
+	RunE:  QuarantineRestoreCmdRunE,
+}
+
+var quarantinePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete a quarantined work item, the ones quarantined before a given age or all of them",
+	RunE:  QuarantinePurgeCmdRunE,
+}
+
+// QuarantineListCmdRunE prints the neighborhood, UUID, status, quarantine date and reason of every quarantined work item
+func QuarantineListCmdRunE(cmd *cobra.Command, args []string) error {
+	items, err := store.ListQuarantined()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NEIGHBORHOOD\tUUID\tSTATUS\tQUARANTINED\tREASON")
+	for _, item := range items {
+		date, reason := "-", "-"
+		if item.Quarantine != nil {
+			date = item.Quarantine.Date.Format(time.RFC3339)
+			reason = item.Quarantine.Reason
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", item.Neighborhood, item.UUID, item.Status, date, reason)
+	}
+
+	return w.Flush()
+}
+
+// QuarantineShowCmdRunE prints the quarantined work item as JSON
+func QuarantineShowCmdRunE(cmd *cobra.Command, args []string) error {
+	neighborhood, uuidStr, err := loadWorkItemTarget(cmd)
+	if err != nil {
+		return err
+	}
+
+	item, err := store.LoadQuarantined(neighborhood, uuidStr)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal work item: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return nil
+}
+
+// QuarantineRestoreCmdRunE moves the quarantined work item back to the state directory, e.g., to keep it next to the
+// other local states. The work item keeps its FAILED status, so it is not migrated until it is claimed again with
+// `claim --force`, which overwrites the local state file.
+func QuarantineRestoreCmdRunE(cmd *cobra.Command, args []string) error {
+	neighborhood, uuidStr, err := loadWorkItemTarget(cmd)
+	if err != nil {
+		return err
+	}
+
+	item, err := store.RestoreWorkItem(neighborhood, uuidStr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Work item %s restored to %s, claim it again with --force to migrate it\n", item.UUID, store.StatePath(neighborhood, uuidStr))
+	return nil
+}
+
+// QuarantinePurgeCmdRunE deletes the given quarantined work item, the ones quarantined before --older-than, or all of
+// them with --all
+func QuarantinePurgeCmdRunE(cmd *cobra.Command, args []string) error {
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return err
+	}
+	olderThan, err := cmd.Flags().GetDuration("older-than")
+	if err != nil {
+		return err
+	}
+	uuidStr, err := cmd.Flags().GetString("uuid")
+	if err != nil {
+		return err
+	}
+
+	selected := 0
+	for _, set := range []bool{all, olderThan > 0, uuidStr != ""} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		return utils.Mark(fmt.Errorf("exactly one of --uuid, --older-than or --all is required"), config.ErrInvalidConfig)
+	}
+
+	if uuidStr != "" {
+		neighborhood, uuidStr, err := loadWorkItemTarget(cmd)
+		if err != nil {
+			return err
+		}
+		if err := store.PurgeQuarantined(neighborhood, uuidStr); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Purged 1 work item")
+		return nil
+	}
+
+	items, err := store.ListQuarantined()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	purged := 0
+	for _, item := range items {
+		if !all && (item.Quarantine == nil || !item.Quarantine.Date.Before(cutoff)) {
+			continue
+		}
+		if err := store.PurgeQuarantined(item.Neighborhood, item.UUID.String()); err != nil {
+			return err
+		}
+		purged++
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Purged %d work item(s)\n", purged)
+	return nil
+}
+
+// loadWorkItemTarget loads the neighborhood and the UUID of the work item to act on
+func loadWorkItemTarget(cmd *cobra.Command) (uint64, string, error) {
+	uuidStr, err := cmd.Flags().GetString("uuid")
+	if err != nil {
+		return 0, "", err
+	}
+	if uuidStr == "" {
+		return 0, "", utils.Mark(fmt.Errorf("uuid is required"), config.ErrInvalidConfig)
+	}
+	if _, err := uuid.Parse(uuidStr); err != nil {
+		return 0, "", utils.Mark(fmt.Errorf("could not parse UUID: %w", err), config.ErrInvalidConfig)
+	}
+
+	return viper.GetUint64("neighborhood"), uuidStr, nil
+}
+
+func SetupQuarantineCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the quarantined work item")
+}
+
+func SetupQuarantinePurgeCmdFlags(command *cobra.Command) {
+	SetupQuarantineCmdFlags(command)
+	command.Flags().Duration("older-than", 0, "Purge the work items quarantined for longer than this duration, e.g., 720h")
+	command.Flags().Bool("all", false, "Purge every quarantined work item")
+}
+
+func init() {
+	SetupQuarantineCmdFlags(quarantineShowCmd)
+	SetupQuarantineCmdFlags(quarantineRestoreCmd)
+	SetupQuarantinePurgeCmdFlags(quarantinePurgeCmd)
+	quarantineCmd.AddCommand(quarantineListCmd, quarantineShowCmd, quarantineRestoreCmd, quarantinePurgeCmd)
+	rootCmd.AddCommand(quarantineCmd)
+}
@@ -0,0 +1,97 @@
+package cmd_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+// execQuarantineCmd executes the named quarantine command
+func execQuarantineCmd(t *testing.T, name string, args ...string) (string, error) {
+	runE := map[string]func(*cobra.Command, []string) error{
+		"list":    cmd.QuarantineListCmdRunE,
+		"show":    cmd.QuarantineShowCmdRunE,
+		"restore": cmd.QuarantineRestoreCmdRunE,
+		"purge":   cmd.QuarantinePurgeCmdRunE,
+	}[name]
+
+	root := &cobra.Command{Use: "root"}
+	cmd.SetupRootCmdFlags(root)
+	command := &cobra.Command{Use: name, RunE: runE}
+	if name == "purge" {
+		cmd.SetupQuarantinePurgeCmdFlags(command)
+	} else {
+		cmd.SetupQuarantineCmdFlags(command)
+	}
+	root.AddCommand(command)
+	return testutils.Execute(t, root, append([]string{name}, args...)...)
+}
+
+// setupQuarantinedWorkItem quarantines the dummy work item
+func setupQuarantinedWorkItem(t *testing.T) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+	testutils.SetupWorkItem(t)
+	item, err := store.LoadState(0, testutils.Uuid)
+	require.NoError(t, err)
+	item.Status = store.FAILED
+	require.NoError(t, store.QuarantineWorkItem(*item, "amount must be greater than 0"))
+}
+
+func TestQuarantineCmd(t *testing.T) {
+	setupQuarantinedWorkItem(t)
+
+	out, err := execQuarantineCmd(t, "list")
+	require.NoError(t, err)
+	require.Contains(t, out, testutils.Uuid)
+	require.Contains(t, out, "failed")
+	require.Contains(t, out, "amount must be greater than 0")
+
+	out, err = execQuarantineCmd(t, "show", "--uuid", testutils.Uuid)
+	require.NoError(t, err)
+	require.Contains(t, out, `"reason": "amount must be greater than 0"`)
+
+	_, err = execQuarantineCmd(t, "show", "--uuid", testutils.Uuid, "--neighborhood", "2")
+	require.ErrorIs(t, err, store.ErrNotQuarantined)
+
+	_, err = execQuarantineCmd(t, "show")
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+
+	out, err = execQuarantineCmd(t, "restore", "--uuid", testutils.Uuid)
+	require.NoError(t, err)
+	require.Contains(t, out, "restored")
+	item, err := store.LoadState(0, testutils.Uuid)
+	require.NoError(t, err)
+	require.Equal(t, store.FAILED, item.Status)
+	require.Nil(t, item.Quarantine)
+
+	out, err = execQuarantineCmd(t, "list")
+	require.NoError(t, err)
+	require.NotContains(t, out, testutils.Uuid)
+}
+
+func TestQuarantinePurgeCmd(t *testing.T) {
+	setupQuarantinedWorkItem(t)
+
+	_, err := execQuarantineCmd(t, "purge")
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+	_, err = execQuarantineCmd(t, "purge", "--all", "--uuid", testutils.Uuid)
+	require.ErrorIs(t, err, config.ErrInvalidConfig)
+
+	// The work item was just quarantined
+	out, err := execQuarantineCmd(t, "purge", "--older-than", "24h")
+	require.NoError(t, err)
+	require.Contains(t, out, "Purged 0 work item(s)")
+	require.FileExists(t, store.QuarantinePath(0, testutils.Uuid))
+
+	out, err = execQuarantineCmd(t, "purge", "--all")
+	require.NoError(t, err)
+	require.Contains(t, out, "Purged 1 work item(s)")
+	require.NoFileExists(t, store.QuarantinePath(0, testutils.Uuid))
+}
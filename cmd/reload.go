@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+)
+
+// configReloadEvent is the audit event of the configuration reloads
+const configReloadEvent = "config-reload"
+
+// reloadMu serializes the configuration reloads of every trigger and every live config, as a reload writes the global
+// viper settings and profile settings
+var reloadMu sync.Mutex
+
+// LiveMigrateConfig holds the migration settings, reloaded on configuration file change and on SIGHUP.
+// Only the reloadable settings change; a reloaded config is validated before it is swapped in.
+// Each migration uses the settings loaded when it starts.
+type LiveMigrateConfig struct {
+	cmd          *cobra.Command
+	neighborhood uint64 // The neighborhood whose overrides apply
+	current      atomic.Pointer[config.MigrateConfig]
+}
+
+// NewLiveMigrateConfig returns the live settings of the command for the neighborhood, starting from the given config
+func NewLiveMigrateConfig(cmd *cobra.Command, neighborhood uint64, initial config.MigrateConfig) *LiveMigrateConfig {
+	l := &LiveMigrateConfig{cmd: cmd, neighborhood: neighborhood}
+	l.current.Store(&initial)
+	return l
+}
+
+// Load returns the current settings
+func (l *LiveMigrateConfig) Load() config.MigrateConfig {
+	return *l.current.Load()
+}
+
+// Reload loads the settings again and swaps in the changed reloadable settings.
+// A reload failing validation is rejected and the current settings are kept.
+func (l *LiveMigrateConfig) Reload(trigger string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return l.reload(trigger)
+}
+
+// reload is Reload, reloadMu must be held
+func (l *LiveMigrateConfig) reload(trigger string) error {
+	reject := func(err error) error {
+		slog.Error("Config reload rejected", logging.AuditKey, configReloadEvent, "trigger", trigger, "error", err)
+		return err
+	}
+
+	if err := applyProfile(l.cmd); err != nil {
+		return reject(err)
+	}
+
+	next, err := LoadMigrationConfigFromCLI()
+	if err != nil {
+		return reject(err)
+	}
+	var whitelistConfig config.WhitelistConfig
+	if err := applyNeighborhoodOverrides(l.neighborhood, &next, &whitelistConfig); err != nil {
+		return reject(err)
+	}
+	if err := resolveMigrateSecrets(context.Background(), &next); err != nil {
+		return reject(err)
+	}
+
+	current := l.Load()
+	reloaded, changed, restartRequired := current.Reload(next)
+	if restartRequired {
+		slog.Warn("Config reload ignores the settings which are not reloadable, restart to apply them", logging.AuditKey, configReloadEvent, "trigger", trigger, "reloadable", config.ReloadableSettings)
+	}
+
+	if len(changed) == 0 {
+		slog.Info("Config reloaded, no change", logging.AuditKey, configReloadEvent, "trigger", trigger)
+		return nil
+	}
+
+	if err := reloaded.Validate(); err != nil {
+		return reject(err)
+	}
+
+	l.current.Store(&reloaded)
+	slog.Info("Config reloaded", logging.AuditKey, configReloadEvent, "trigger", trigger, "changed", changed)
+	return nil
+}
+
+// Watch reloads the settings when the configuration file changes and on SIGHUP, until the context is done
+func (l *LiveMigrateConfig) Watch(ctx context.Context) {
+	watchConfig(ctx, func(trigger string) { _ = l.reload(trigger) })
+}
+
+// watchConfig calls reload when the configuration file changes and on SIGHUP, until the context is done.
+// The configuration file is read again before reload is called on SIGHUP.
+// The reloads are serialized, reload is called with reloadMu held.
+func watchConfig(ctx context.Context, reload func(trigger string)) {
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+			reload("file")
+		})
+		viper.WatchConfig()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reloadOnSignal(reload)
+			}
+		}
+	}()
+}
+
+// reloadOnSignal reads the configuration file again and calls reload, holding reloadMu
+func reloadOnSignal(reload func(trigger string)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if err := readConfigFile(); err != nil {
+		slog.Error("Config reload rejected", logging.AuditKey, configReloadEvent, "trigger", "sighup", "error", err)
+		return
+	}
+	reload("sighup")
+}
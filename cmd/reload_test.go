@@ -0,0 +1,102 @@
+package cmd_test
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// newLiveMigrateConfig returns live settings loaded from the root and migrate flags
+func newLiveMigrateConfig(t *testing.T) *cmd.LiveMigrateConfig {
+	command := &cobra.Command{Use: "migrate"}
+	cmd.SetupRootCmdFlags(command)
+	cmd.SetupMigrateCmdFlags(command)
+	setupMigrateEnv(t)
+
+	t.Cleanup(func() {
+		for _, key := range append(config.ReloadableSettings, "chain-id") {
+			viper.Set(key, nil)
+		}
+	})
+
+	initial, err := cmd.LoadMigrationConfigFromCLI()
+	require.NoError(t, err)
+	require.NoError(t, initial.Validate())
+	return cmd.NewLiveMigrateConfig(command, 0, initial)
+}
+
+func TestLiveMigrateConfig_Reload(t *testing.T) {
+	live := newLiveMigrateConfig(t)
+	initial := live.Load()
+
+	// Reloadable settings are swapped in
+	tokenMap := map[string]utils.TokenInfo{"dummy": {Denom: "umfx", GasLimit: 100000}}
+	viper.Set("token-map", tokenMap)
+	viper.Set("gas-price", 0.002)
+	viper.Set("max-fee", 5000)
+	require.NoError(t, live.Reload("test"))
+	reloaded := live.Load()
+	require.Equal(t, tokenMap, reloaded.TokenMap)
+	require.Equal(t, 0.002, reloaded.GasPrice)
+	require.Equal(t, uint(5000), reloaded.MaxFee)
+
+	// The other settings are not
+	viper.Set("chain-id", "other-chain")
+	require.NoError(t, live.Reload("test"))
+	require.Equal(t, initial.ChainID, live.Load().ChainID)
+
+	// Invalid settings are rejected
+	viper.Set("gas-price", -1)
+	require.ErrorIs(t, live.Reload("test"), config.ErrInvalidConfig)
+	require.Equal(t, 0.002, live.Load().GasPrice)
+
+	viper.Set("token-map", "not a map")
+	require.ErrorIs(t, live.Reload("test"), config.ErrInvalidConfig)
+	require.Equal(t, tokenMap, live.Load().TokenMap)
+}
+
+func TestLiveMigrateConfig_SIGHUP(t *testing.T) {
+	live := newLiveMigrateConfig(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Viper is not safe for concurrent use, change the settings before watching
+	viper.Set("gas-adjustment", 2.0)
+	live.Watch(ctx)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	require.Eventually(t, func() bool { return live.Load().GasAdjustment == 2.0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLiveMigrateConfig_ConcurrentReload(t *testing.T) {
+	// The reloads of every live config apply the profile to the global settings, they must be serialized
+	lives := []*cmd.LiveMigrateConfig{newLiveMigrateConfig(t), newLiveMigrateConfig(t)}
+	setupProfiles(t)
+	viper.Set("profile", "testnet")
+	t.Cleanup(func() { viper.Set("profile", nil) })
+
+	var wg sync.WaitGroup
+	for _, live := range lives {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				require.NoError(t, live.Reload("test"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, live := range lives {
+		require.Equal(t, "utest", live.Load().TokenMap["dummy"].Denom)
+	}
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// resolveCmd represents the resolve command group
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Release or resolve the work items escalated to an operator",
+	Long: `A work item is escalated when an operator must review it before it is migrated again, e.g., a screened address
+matched a denylist, or the migration transaction was broadcast but its outcome is unknown. The escalated work item is
+skipped until it is released or resolved. The transaction of a work item is always checked on chain before it is
+resolved.`,
+}
+
+var resolveReleaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Release a held work item whose transaction was never broadcast, e.g., after reviewing a denylist match",
+	RunE:  ResolveReleaseCmdRunE,
+}
+
+var resolveCompletedCmd = &cobra.Command{
+	Use:   "completed",
+	Short: "Mark a work item completed once its migration transaction is found successful on chain",
+	RunE:  ResolveCompletedCmdRunE,
+}
+
+var resolveNotSentCmd = &cobra.Command{
+	Use:   "not-sent",
+	Short: "Clear the broadcast transaction of a work item once it can no longer succeed on chain, so it is migrated again",
+	RunE:  ResolveNotSentCmdRunE,
+}
+
+// ResolveReleaseCmdRunE releases the hold of the local work item
+func ResolveReleaseCmdRunE(cmd *cobra.Command, args []string) error {
+	neighborhood, uuidStr, err := loadWorkItemTarget(cmd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.ReleaseWorkItem(neighborhood, uuidStr); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Work item %s released\n", uuidStr)
+	return nil
+}
+
+// ResolveCompletedCmdRunE verifies the migration transaction of the work item succeeded on chain and sent the tokens
+// to the work item manifest address, then sets the work item status to COMPLETED and archives its local state
+func ResolveCompletedCmdRunE(cmd *cobra.Command, args []string) error {
+	item, tx, _, err := loadEscalatedTx(cmd)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fmt.Errorf("transaction not found on chain, retry later or use not-sent: %s", item.UUID)
+	}
+	if tx.Code != 0 {
+		return fmt.Errorf("transaction %s failed on chain (%s/%d), use not-sent: %s", tx.TxHash, tx.Codespace, tx.Code, item.UUID)
+	}
+	if !slices.Contains(tx.Recipients(), item.ManifestAddress) {
+		return fmt.Errorf("transaction %s does not send tokens to %s: %s", tx.TxHash, item.ManifestAddress, item.UUID)
+	}
+
+	c := LoadConfigFromCLI("")
+	slog.Debug("args", "c", c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	authConfig := LoadAuthConfigFromCLI()
+	if err := resolveAuthSecrets(cmd.Context(), &authConfig); err != nil {
+		return err
+	}
+	slog.Debug("args", "auth-c", authConfig)
+	if err := authConfig.Validate(); err != nil {
+		return err
+	}
+
+	r := CreateRestClient(cmd.Context(), c.Url, item.Neighborhood)
+	if err := AuthenticateRestClient(cmd.Context(), r, authConfig.Username, authConfig.Password); err != nil {
+		return err
+	}
+
+	newItem := *item
+	if err := newItem.Transition(store.COMPLETED); err != nil {
+		return err
+	}
+	blockTime := tx.Timestamp.UTC()
+	newItem.ManifestHash = &tx.TxHash
+	newItem.ManifestDatetime = &blockTime
+	newItem.Hold, newItem.UnknownOutcome, newItem.Broadcast = nil, nil, nil
+	if err := store.UpdateWorkItemAndSaveState(cmd.Context(), r, newItem); err != nil {
+		return errors.WithMessage(err, "error setting status to COMPLETED")
+	}
+	if err := archiveState(&newItem); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Work item %s completed with transaction %s\n", item.UUID, tx.TxHash)
+	return nil
+}
+
+// ResolveNotSentCmdRunE verifies the migration transaction of the work item can no longer succeed on chain, then clears
+// its unknown outcome and broadcast transaction so the work item is migrated again. A hold, if any, is kept.
+//
+// A transaction not found on chain may still be in the mempool, or not indexed yet. It can no longer land once the bank
+// account sequence moved past the sequence the transaction was signed with. Otherwise, --force is required.
+func ResolveNotSentCmdRunE(cmd *cobra.Command, args []string) error {
+	item, tx, migrateConfig, err := loadEscalatedTx(cmd)
+	if err != nil {
+		return err
+	}
+	if tx != nil && tx.Code == 0 {
+		return fmt.Errorf("transaction %s succeeded on chain, use completed: %s", tx.TxHash, item.UUID)
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	if tx == nil && !force {
+		if item.Broadcast == nil || item.Broadcast.Sequence == nil {
+			return fmt.Errorf("transaction not found on chain and no signer sequence recorded, use --force once the transaction expired: %s", item.UUID)
+		}
+		sequence, err := manifest.QueryAccountSequence(cmd.Context(), migrateConfig)
+		if err != nil {
+			return err
+		}
+		if sequence <= *item.Broadcast.Sequence {
+			return fmt.Errorf("transaction not found on chain but the account sequence %d did not move past %d, it may still land, retry later or use --force: %s", sequence, *item.Broadcast.Sequence, item.UUID)
+		}
+	}
+
+	if _, err := store.ClearBroadcast(item.Neighborhood, item.UUID.String()); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Work item %s cleared, it will be migrated again\n", item.UUID)
+	return nil
+}
+
+// loadEscalatedTx loads the local work item and looks up its migration transaction on the chain it was broadcast to.
+// The transaction is nil if it is not found. The configuration of the chain is returned too.
+func loadEscalatedTx(cmd *cobra.Command) (*store.WorkItem, *manifest.LandedTx, config.MigrateConfig, error) {
+	neighborhood, uuidStr, err := loadWorkItemTarget(cmd)
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+
+	item, err := store.LoadState(neighborhood, uuidStr)
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, errors.WithMessage(err, "unable to load state")
+	}
+	if item.UnknownOutcome == nil && item.Broadcast == nil && item.Hold == nil {
+		return nil, nil, config.MigrateConfig{}, errors.WithMessagef(store.ErrNotEscalated, "%s", uuidStr)
+	}
+
+	hash, err := cmd.Flags().GetString("tx-hash")
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+	if hash == "" {
+		hash = txHash(item)
+	}
+	if hash == "" {
+		return nil, nil, config.MigrateConfig{}, utils.Mark(fmt.Errorf("no transaction hash recorded, --tx-hash is required: %s", uuidStr), config.ErrInvalidConfig)
+	}
+
+	destination, err := cmd.Flags().GetString("destination")
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+	if destination == "" && item.Broadcast != nil {
+		destination = item.Broadcast.Destination
+	}
+
+	migrateConfig, _, err := loadNeighborhoodConfigFromCLI(cmd.Context(), neighborhood)
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+	if migrateConfig, err = migrateConfig.ForDestination(destination); err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+
+	slog.Info("Looking up transaction on chain...", "uuid", uuidStr, "hash", hash, "chainId", migrateConfig.ChainID)
+	tx, err := manifest.QueryTx(cmd.Context(), migrateConfig, hash)
+	if err != nil {
+		return nil, nil, config.MigrateConfig{}, err
+	}
+	return item, tx, migrateConfig, nil
+}
+
+func SetupResolveCmdFlags(command *cobra.Command) {
+	command.Flags().String("uuid", "", "UUID of the escalated work item")
+}
+
+func SetupResolveTxCmdFlags(command *cobra.Command) {
+	SetupResolveCmdFlags(command)
+	command.Flags().String("tx-hash", "", "Hash of the migration transaction (default the recorded hash)")
+	command.Flags().String("destination", "", "Named destination the transaction was broadcast to (default the recorded destination)")
+	setupMigrateSettingFlags(command)
+}
+
+func SetupResolveNotSentCmdFlags(command *cobra.Command) {
+	SetupResolveTxCmdFlags(command)
+	command.Flags().Bool("force", false, "Clear the transaction even if it is not proven it can no longer land")
+}
+
+func init() {
+	SetupResolveCmdFlags(resolveReleaseCmd)
+	SetupResolveTxCmdFlags(resolveCompletedCmd)
+	SetupResolveNotSentCmdFlags(resolveNotSentCmd)
+	resolveCmd.AddCommand(resolveReleaseCmd, resolveCompletedCmd, resolveNotSentCmd)
+	rootCmd.AddCommand(resolveCmd)
+}
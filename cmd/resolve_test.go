@@ -0,0 +1,159 @@
+package cmd_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+// execResolveCmd executes the named resolve command against the fake chain binary
+func execResolveCmd(t *testing.T, name string, binary string, args ...string) (string, error) {
+	runE := map[string]func(*cobra.Command, []string) error{
+		"release":   cmd.ResolveReleaseCmdRunE,
+		"completed": cmd.ResolveCompletedCmdRunE,
+		"not-sent":  cmd.ResolveNotSentCmdRunE,
+	}[name]
+
+	root := &cobra.Command{Use: "root"}
+	cmd.SetupRootCmdFlags(root)
+	command := &cobra.Command{Use: name, RunE: runE}
+	switch name {
+	case "release":
+		cmd.SetupResolveCmdFlags(command)
+	case "not-sent":
+		cmd.SetupResolveNotSentCmdFlags(command)
+	default:
+		cmd.SetupResolveTxCmdFlags(command)
+	}
+	if name != "release" {
+		args = append(args, "--binary", binary, "--chain-home", t.TempDir(), "--fee-granter", "feegranter", "--url", testutils.RootUrl, "--username", "user", "--password", "pass")
+	}
+	root.AddCommand(command)
+
+	client := resty.New()
+	root.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+	httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+	httpmock.RegisterResponder("PUT", "=~^"+testutils.DefaultMigrationUrl, testutils.MigrationUpdateResponder)
+
+	return testutils.Execute(t, root, append([]string{name, "--uuid", testutils.Uuid}, args...)...)
+}
+
+// setupEscalatedWorkItem saves the dummy work item as migrating, with the given hold and broadcast transaction
+func setupEscalatedWorkItem(t *testing.T, hold *store.Hold, broadcast *store.Broadcast) {
+	require.NoError(t, os.Chdir(t.TempDir()))
+	testutils.SetupWorkItem(t)
+	item, err := store.LoadState(0, testutils.Uuid)
+	require.NoError(t, err)
+	item.Status = store.MIGRATING
+	item.Hold = hold
+	item.Broadcast = broadcast
+	require.NoError(t, store.SaveState(item))
+}
+
+func TestResolveCmd(t *testing.T) {
+	landed := testutils.DefaultFakeChain
+	landed.TxOutputs = []string{fmt.Sprintf(`{"txhash":"%s","code":0,"height":"42","timestamp":"%s","tx":{"body":{"messages":[{"to_address":"%s"}]}}}`,
+		testutils.FakeTxHash, testutils.FakeBlockTime, testutils.ManifestAddress)}
+	failed := testutils.DefaultFakeChain
+	failed.TxOutputs = []string{fmt.Sprintf(`{"txhash":"%s","codespace":"sdk","code":5,"height":"42","timestamp":"%s"}`, testutils.FakeTxHash, testutils.FakeBlockTime)}
+	broadcast := &store.Broadcast{TxHash: testutils.FakeTxHash}
+
+	t.Run("release", func(t *testing.T) {
+		setupEscalatedWorkItem(t, &store.Hold{Reason: "denylisted"}, nil)
+		out, err := execResolveCmd(t, "release", "")
+		require.NoError(t, err)
+		require.Contains(t, out, "released")
+
+		item, err := store.LoadState(0, testutils.Uuid)
+		require.NoError(t, err)
+		require.Nil(t, item.Hold)
+	})
+
+	t.Run("release_broadcast", func(t *testing.T) {
+		setupEscalatedWorkItem(t, &store.Hold{Reason: "not recorded"}, broadcast)
+		_, err := execResolveCmd(t, "release", "")
+		require.ErrorIs(t, err, store.ErrTxBroadcast)
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		setupEscalatedWorkItem(t, &store.Hold{Reason: "not recorded"}, broadcast)
+		out, err := execResolveCmd(t, "completed", testutils.NewFakeChainBinary(t, landed))
+		require.NoError(t, err)
+		require.Contains(t, out, "completed with transaction "+testutils.FakeTxHash)
+		require.Equal(t, 1, httpmock.GetCallCountInfo()["PUT =~^"+testutils.DefaultMigrationUrl])
+		require.NoFileExists(t, store.StatePath(0, testutils.Uuid))
+		require.FileExists(t, store.CompletedPath(0, testutils.Uuid))
+	})
+
+	t.Run("completed_not_landed", func(t *testing.T) {
+		setupEscalatedWorkItem(t, nil, broadcast)
+		_, err := execResolveCmd(t, "completed", testutils.NewFakeChainBinary(t, failed))
+		require.ErrorContains(t, err, "failed on chain")
+		_, err = execResolveCmd(t, "completed", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain))
+		require.ErrorContains(t, err, "transaction not found on chain")
+		require.FileExists(t, store.StatePath(0, testutils.Uuid))
+	})
+
+	t.Run("not_sent", func(t *testing.T) {
+		setupEscalatedWorkItem(t, nil, broadcast)
+		_, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, landed))
+		require.ErrorContains(t, err, "use completed")
+
+		out, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, failed))
+		require.NoError(t, err)
+		require.Contains(t, out, "migrated again")
+		item, err := store.LoadState(0, testutils.Uuid)
+		require.NoError(t, err)
+		require.Nil(t, item.Broadcast)
+		require.Equal(t, store.MIGRATING, item.Status)
+	})
+
+	t.Run("not_sent_not_found", func(t *testing.T) {
+		// The fake bank account sequence is 5
+		pending, expired := uint64(5), uint64(4)
+
+		setupEscalatedWorkItem(t, nil, &store.Broadcast{TxHash: testutils.FakeTxHash, Sequence: &pending})
+		_, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain))
+		require.ErrorContains(t, err, "it may still land")
+		item, err := store.LoadState(0, testutils.Uuid)
+		require.NoError(t, err)
+		require.NotNil(t, item.Broadcast)
+
+		setupEscalatedWorkItem(t, nil, &store.Broadcast{TxHash: testutils.FakeTxHash, Sequence: &expired})
+		out, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain))
+		require.NoError(t, err)
+		require.Contains(t, out, "migrated again")
+	})
+
+	t.Run("not_sent_force", func(t *testing.T) {
+		setupEscalatedWorkItem(t, nil, broadcast)
+		_, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain))
+		require.ErrorContains(t, err, "no signer sequence recorded")
+
+		out, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain), "--force")
+		require.NoError(t, err)
+		require.Contains(t, out, "migrated again")
+		item, err := store.LoadState(0, testutils.Uuid)
+		require.NoError(t, err)
+		require.Nil(t, item.Broadcast)
+	})
+
+	t.Run("no_hash", func(t *testing.T) {
+		setupEscalatedWorkItem(t, nil, nil)
+		require.NoError(t, store.SetUnknownOutcome(0, testutils.Uuid, "", "sequence consumed"))
+		_, err := execResolveCmd(t, "not-sent", testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain))
+		require.ErrorContains(t, err, "--tx-hash is required")
+	})
+}
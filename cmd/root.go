@@ -1,17 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
-	"strings"
+	"slices"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
-	"github.com/liftedinit/mfx-migrator/internal/utils"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
 )
 
+// tracingShutdownTimeout bounds the time spent flushing the pending spans on exit
+const tracingShutdownTimeout = 5 * time.Second
+
 var rootCmd = &cobra.Command{
 	Use:               "mfx-migrator",
 	Short:             "Migrate your MFX tokens to the Manifest Ledger",
@@ -19,9 +28,39 @@ var rootCmd = &cobra.Command{
 }
 
 func RootCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
+	if err := bindSettingFlags(cmd); err != nil {
+		return err
+	}
+
 	logLevelArg := viper.GetString("logLevel")
 	urlString := viper.GetString("url")
-	if err := setLogLevel(logLevelArg); err != nil {
+	if err := setupLogging(); err != nil {
+		return err
+	}
+
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+
+	if err := checkProfileEnvironment(); err != nil {
+		return err
+	}
+
+	store.StrictStateVersion = viper.GetBool("strict-state-version")
+	store.SealPlaintextState = viper.GetBool("seal-plaintext-state")
+	keys, err := store.LoadStateKeys(viper.GetString("state-key-file"))
+	if err != nil {
+		return err
+	}
+	store.StateEncryption = keys
+	store.QuarantineDir = viper.GetString("quarantine-dir")
+	store.CompletedDir = viper.GetString("completed-dir")
+
+	if err := setupNotifier(); err != nil {
+		return err
+	}
+
+	if err := setupTracing(cmd.Context()); err != nil {
 		return err
 	}
 
@@ -30,6 +69,19 @@ func RootCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// bindSettingFlags binds the settings to the flags of the running command.
+// The commands sharing settings, e.g., migrate and process, bind them to their own flags when they are set up, the
+// last one set up winning.
+func bindSettingFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err == nil && !slices.Contains(perRunFlags, f.Name) {
+			err = viper.BindPFlag(f.Name, f)
+		}
+	})
+	return errors.WithMessage(err, ErrorBindingFlag)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -39,24 +91,52 @@ func Execute() {
 		slog.Info("No config file found")
 	}
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	shutdownTracing()
+	if err != nil {
 		slog.Error("An error occurred", "error", err)
+	}
+	closeLogs()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
-var (
-	validLogLevels = map[string]slog.Level{
-		"debug": slog.LevelDebug,
-		"info":  slog.LevelInfo,
-		"warn":  slog.LevelWarn,
-		"error": slog.LevelError,
+// tracingShutdown flushes the pending spans. It is set up by RootCmdPersistentPreRunE.
+var tracingShutdown func(context.Context) error
+
+// setupTracing installs the tracer provider from the CLI flags
+func setupTracing(ctx context.Context) error {
+	tracingConfig := LoadTracingConfigFromCLI()
+	slog.Debug("args", "tracing-c", tracingConfig)
+	if err := tracingConfig.Validate(); err != nil {
+		return err
 	}
-	validLogLevelsStr = strings.Join(utils.GetKeys(validLogLevels), "|")
-)
+
+	shutdown, err := tracing.Setup(ctx, tracingConfig, Version)
+	if err != nil {
+		return errors.WithMessage(err, "unable to set up tracing")
+	}
+	tracingShutdown = shutdown
+
+	return nil
+}
+
+// shutdownTracing flushes the pending spans, waiting at most tracingShutdownTimeout
+func shutdownTracing() {
+	if tracingShutdown == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if err := tracingShutdown(ctx); err != nil {
+		slog.Error("Unable to flush traces", "error", err)
+	}
+}
 
 func SetupRootCmdFlags(command *cobra.Command) {
-	command.PersistentFlags().StringP("logLevel", "l", "info", fmt.Sprintf("set log level (%s)", validLogLevelsStr))
+	command.PersistentFlags().StringP("logLevel", "l", "info", fmt.Sprintf("set log level (%s)", logging.ValidLevelsStr))
 	if err := viper.BindPFlag("logLevel", command.PersistentFlags().Lookup("logLevel")); err != nil {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
@@ -66,10 +146,7 @@ func SetupRootCmdFlags(command *cobra.Command) {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
 
-	command.PersistentFlags().Uint64("neighborhood", 0, "Neighborhood ID")
-	if err := viper.BindPFlag("neighborhood", command.PersistentFlags().Lookup("neighborhood")); err != nil {
-		slog.Error(ErrorBindingFlag, "error", err)
-	}
+	setupNeighborhoodFlags(command)
 
 	command.PersistentFlags().String("username", "", "Username for the remote database")
 	if err := viper.BindPFlag("username", command.PersistentFlags().Lookup("username")); err != nil {
@@ -81,6 +158,56 @@ func SetupRootCmdFlags(command *cobra.Command) {
 		slog.Error(ErrorBindingFlag, "error", err)
 	}
 
+	command.PersistentFlags().Bool("strict-state-version", true, "Refuse to load local state files written by a newer migrator")
+	if err := viper.BindPFlag("strict-state-version", command.PersistentFlags().Lookup("strict-state-version")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("state-key-file", "", fmt.Sprintf("File holding the keys encrypting the local state files, the first one is current (default $%s)", store.StateKeyEnv))
+	if err := viper.BindPFlag("state-key-file", command.PersistentFlags().Lookup("state-key-file")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Bool("seal-plaintext-state", false, "Accept the plaintext local state files and encrypt them on load, e.g., after configuring the first state key")
+	if err := viper.BindPFlag("seal-plaintext-state", command.PersistentFlags().Lookup("seal-plaintext-state")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("quarantine-dir", "quarantine", "Directory holding the local state files of the failed work items")
+	if err := viper.BindPFlag("quarantine-dir", command.PersistentFlags().Lookup("quarantine-dir")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("completed-dir", "completed", "Directory holding the local state files of the completed work items")
+	if err := viper.BindPFlag("completed-dir", command.PersistentFlags().Lookup("completed-dir")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Uint("shutdown-grace-period", 8, "Number of seconds the work in flight is given to finish on SIGTERM or SIGINT")
+	if err := viper.BindPFlag("shutdown-grace-period", command.PersistentFlags().Lookup("shutdown-grace-period")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	setupLogFlags(command)
+	setupProfileFlags(command)
+	setupSecretsFlags(command)
+	setupNotifyFlags(command)
+
+	command.PersistentFlags().String("trace-exporter", "none", "OpenTelemetry span exporter (none|stdout|otlp)")
+	if err := viper.BindPFlag("trace-exporter", command.PersistentFlags().Lookup("trace-exporter")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().String("trace-otlp-endpoint", "", "OTLP/HTTP endpoint URL the spans are exported to (default from the OTEL_EXPORTER_OTLP_* environment variables)")
+	if err := viper.BindPFlag("trace-otlp-endpoint", command.PersistentFlags().Lookup("trace-otlp-endpoint")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
+	command.PersistentFlags().Bool("trace-otlp-insecure", false, "Disable TLS when exporting the spans to the OTLP endpoint")
+	if err := viper.BindPFlag("trace-otlp-insecure", command.PersistentFlags().Lookup("trace-otlp-insecure")); err != nil {
+		slog.Error(ErrorBindingFlag, "error", err)
+	}
+
 	command.SilenceUsage = true
 	command.SilenceErrors = true
 }
@@ -92,20 +219,7 @@ func init() {
 	viper.AddConfigPath("/config")
 	viper.SetConfigName("migrator-config")
 
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv()
 }
-
-// setLogLevel sets the log level
-func setLogLevel(logLevel string) error {
-	level, exists := validLogLevels[logLevel]
-	if !exists {
-		return fmt.Errorf("invalid log level: %s. Valid log levels are: %s", logLevel, validLogLevelsStr)
-	}
-
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
-	slog.SetDefault(logger)
-
-	return nil
-}
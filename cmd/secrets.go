@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+func LoadSecretsConfigFromCLI() config.SecretsConfig {
+	return config.SecretsConfig{
+		HttpTokenFile: viper.GetString("secrets-http-token-file"),
+	}
+}
+
+// resolveSecret resolves the secret referenced by the setting, if set
+func resolveSecret(ctx context.Context, key string) (string, bool, error) {
+	ref := viper.GetString(key)
+	if ref == "" {
+		return "", false, nil
+	}
+
+	secrets, err := config.NewSecrets(LoadSecretsConfigFromCLI())
+	if err != nil {
+		return "", false, err
+	}
+
+	secret, err := secrets.Resolve(ctx, ref)
+	if err != nil {
+		return "", false, errors.WithMessagef(err, "unable to resolve %s", key)
+	}
+	return secret, true, nil
+}
+
+// resolveAuthSecrets sets the password from the password secret, if any
+func resolveAuthSecrets(ctx context.Context, c *config.AuthConfig) error {
+	password, ok, err := resolveSecret(ctx, "password-secret")
+	if ok {
+		c.Password = password
+	}
+	return err
+}
+
+// resolveMigrateSecrets sets the keyring passphrase from the keyring passphrase secret, if any
+func resolveMigrateSecrets(ctx context.Context, c *config.MigrateConfig) error {
+	passphrase, ok, err := resolveSecret(ctx, "keyring-passphrase-secret")
+	if ok {
+		c.KeyringPassphrase = passphrase
+	}
+	return err
+}
+
+func setupSecretsFlags(command *cobra.Command) {
+	args := []struct {
+		name  string
+		usage string
+	}{
+		{"password-secret", "Reference of the secret holding the password of the remote database auth: file:PATH, env:NAME or an http(s) URL. Takes precedence over --password"},
+		{"secrets-http-token-file", "File holding the bearer token authenticating the requests to the HTTP secrets endpoint"},
+	}
+	for _, arg := range args {
+		command.PersistentFlags().String(arg.name, "", arg.usage)
+		if err := viper.BindPFlag(arg.name, command.PersistentFlags().Lookup(arg.name)); err != nil {
+			slog.Error(ErrorBindingFlag, "error", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// ErrShuttingDown is returned when a work item is not started, or not sent, because the migrator is shutting down.
+// No tokens were sent, the work item is left untouched so the migration can be retried.
+var ErrShuttingDown = errors.New("migrator shutting down")
+
+// shutdownKey is the context key of the graceful shutdown of the running command
+const shutdownKey store.ContextKey = "shutdown"
+
+// gracefulShutdown stops the command from starting new work on SIGTERM or SIGINT, and gives the work in flight the
+// grace period to finish. The work is cancelled when the grace period expires or on a second signal.
+type gracefulShutdown struct {
+	grace    time.Duration
+	stopping chan struct{}      // Closed on the first signal
+	cancel   context.CancelFunc // Cancels the work
+	mu       sync.Mutex
+	inFlight map[string]*store.WorkItem // The work items being migrated, by state file
+	expired  []*store.WorkItem          // The work items in flight when the work was cancelled
+}
+
+// startGracefulShutdown handles the shutdown signals until the returned shutdown is closed.
+// The returned context is the context of the work, cancelled when the grace period expires.
+func startGracefulShutdown(ctx context.Context) (context.Context, *gracefulShutdown) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &gracefulShutdown{
+		grace:    time.Duration(viper.GetUint("shutdown-grace-period")) * time.Second,
+		stopping: make(chan struct{}),
+		cancel:   cancel,
+		inFlight: map[string]*store.WorkItem{},
+	}
+
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-ctx.Done():
+			return
+		case received := <-sig:
+			slog.Warn("Shutting down, no new work is started", "signal", received.String(), "gracePeriod", s.grace.String(), "inFlight", s.inFlightKeys())
+			close(s.stopping)
+		}
+
+		timer := time.NewTimer(s.grace)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			slog.Error("Shutdown grace period expired, cancelling the work in flight", "inFlight", s.inFlightKeys())
+		case received := <-sig:
+			slog.Error("Second signal received, cancelling the work in flight", "signal", received.String(), "inFlight", s.inFlightKeys())
+		}
+		s.expire()
+	}()
+
+	return context.WithValue(ctx, shutdownKey, s), s
+}
+
+// checkShutdown returns ErrShuttingDown if the migrator is shutting down, i.e., no new work must be started
+func checkShutdown(ctx context.Context) error {
+	if s, ok := ctx.Value(shutdownKey).(*gracefulShutdown); ok && s.isStopping() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// trackWorkItem records the work item as in flight until the returned function is called
+func trackWorkItem(ctx context.Context, item *store.WorkItem) func() {
+	if s, ok := ctx.Value(shutdownKey).(*gracefulShutdown); ok {
+		return s.track(item)
+	}
+	return func() {}
+}
+
+func (s *gracefulShutdown) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// track records the work item as in flight until the returned function is called
+func (s *gracefulShutdown) track(item *store.WorkItem) func() {
+	key := store.StatePath(item.Neighborhood, item.UUID.String())
+	s.mu.Lock()
+	s.inFlight[key] = item
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.inFlight, key)
+		s.mu.Unlock()
+	}
+}
+
+func (s *gracefulShutdown) inFlightKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := utils.GetKeys(s.inFlight)
+	sort.Strings(keys)
+	return keys
+}
+
+// expire cancels the work and records the work items in flight
+func (s *gracefulShutdown) expire() {
+	s.mu.Lock()
+	for _, item := range s.inFlight {
+		s.expired = append(s.expired, item)
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// Close stops handling the shutdown signals and logs the work items left unresolved by the shutdown, i.e., the work
+// items in flight when the work was cancelled which are still in the local state, with their transaction hash, if any.
+func (s *gracefulShutdown) Close() {
+	s.cancel()
+	if !s.isStopping() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unresolved := 0
+	for _, item := range s.expired {
+		latest, err := store.LoadState(item.Neighborhood, item.UUID.String())
+		if err != nil {
+			// The state file is deleted once the work item is completed, and moved once it is quarantined
+			continue
+		}
+
+		unresolved++
+		slog.Error("Work item left unresolved by the shutdown", "neighborhood", latest.Neighborhood, "uuid", latest.UUID, "status", latest.Status.String(), "txHash", txHash(latest))
+	}
+
+	if unresolved == 0 {
+		slog.Info("Shutdown complete, no work item left unresolved")
+		return
+	}
+	slog.Error("Shutdown complete, work items left unresolved", "count", unresolved)
+}
+
+// txHash returns the hash of the migration transaction of the work item, if known
+func txHash(item *store.WorkItem) string {
+	switch {
+	case item.UnknownOutcome != nil:
+		return item.UnknownOutcome.TxHash
+	case item.Broadcast != nil:
+		return item.Broadcast.TxHash
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,117 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/cmd"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestMigrateCmd_GracefulShutdown(t *testing.T) {
+	tt := []struct {
+		name     string
+		grace    string
+		notified []string
+		check    func(t *testing.T, err error)
+	}{
+		{name: "finished", grace: "30", check: func(t *testing.T, err error) {
+			// The transaction in flight is confirmed and the work item completed
+			require.NoError(t, err)
+			require.NoFileExists(t, store.StatePath(0, testutils.Uuid))
+		}},
+		{name: "checkpointed", grace: "1", notified: []string{"unknown-outcome"}, check: func(t *testing.T, err error) {
+			// The grace period expired while waiting for the transaction, its hash is recorded
+			require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+			item, lErr := store.LoadState(0, testutils.Uuid)
+			require.NoError(t, lErr)
+			require.Equal(t, store.MIGRATING, item.Status)
+			require.NotNil(t, item.Broadcast)
+			require.Equal(t, testutils.FakeTxHash, item.Broadcast.TxHash)
+			require.NotNil(t, item.UnknownOutcome)
+			require.Equal(t, testutils.FakeTxHash, item.UnknownOutcome.TxHash)
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, os.Chdir(t.TempDir()))
+			testutils.SetupWorkItem(t)
+
+			chain := testutils.DefaultFakeChain
+			chain.WaitDelay = "3"
+			binary := testutils.NewFakeChainBinary(t, chain)
+
+			command := &cobra.Command{Use: "migrate", PersistentPreRunE: cmd.RootCmdPersistentPreRunE, RunE: cmd.MigrateCmdRunE}
+			client := resty.New()
+			command.SetContext(context.WithValue(context.Background(), cmd.RestyClientKey, client))
+			httpmock.ActivateNonDefault(client.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder("POST", testutils.LoginUrl, testutils.AuthResponder)
+			httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.WhiteListResponder)
+			httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultMigrationUrl, testutils.MustMigrationGetResponder(store.CLAIMED))
+			httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultTransactionUrl, testutils.MustNewLedgerSendTransactionResponseResponder("100"))
+			httpmock.RegisterResponder("PUT", "=~^"+testutils.DefaultMigrationUrl, testutils.MigrationUpdateResponder)
+
+			cmd.SetupRootCmdFlags(command)
+			cmd.SetupMigrateCmdFlags(command)
+
+			// The notifications are sent even though the migration was cancelled
+			var notified []string
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload struct{ Kind string }
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+				notified = append(notified, payload.Kind)
+			}))
+			defer webhook.Close()
+
+			// Send SIGTERM once the transaction is broadcast, while waiting for it
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case <-time.After(50 * time.Millisecond):
+					}
+					if item, err := store.LoadState(0, testutils.Uuid); err == nil && item.Broadcast != nil {
+						_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+						return
+					}
+				}
+			}()
+
+			_, err := testutils.Execute(t, command,
+				"--uuid", testutils.Uuid,
+				"--url", testutils.RootUrl,
+				"--username", "user",
+				"--password", "pass",
+				"--chain-home", t.TempDir(),
+				"--fee-granter", "feegranter",
+				"--binary", binary,
+				"--broadcast-retries", "0",
+				"--shutdown-grace-period", tc.grace,
+				"--notify-state-file", filepath.Join(t.TempDir(), "notify-state.json"),
+				"--notify-webhook-url", webhook.URL,
+			)
+			tc.check(t, err)
+			require.Equal(t, tc.notified, notified)
+		})
+	}
+}
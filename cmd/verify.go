@@ -22,7 +22,7 @@ var verifyCmd = &cobra.Command{
 			return err
 		}
 
-		s, err := store.LoadState(c.UUID)
+		s, err := store.LoadState(c.Neighborhood, c.UUID)
 		if err != nil {
 			slog.Warn("unable to load local state, continuing", "warning", err)
 		}
@@ -36,7 +36,7 @@ var verifyCmd = &cobra.Command{
 
 		r := CreateRestClient(cmd.Context(), c.Url, c.Neighborhood)
 
-		item, err := store.GetWorkItem(r, uuid.MustParse(c.UUID))
+		item, err := store.GetWorkItem(cmd.Context(), r, uuid.MustParse(c.UUID))
 		if err != nil {
 			return errors.WithMessage(err, "unable to get work item")
 		}
@@ -177,8 +177,8 @@ func TestMigrateOnChain(t *testing.T) {
 			if tc.err != "" {
 				require.ErrorContains(t, err, tc.err)
 
-				// Check the status of the local work item
-				item, err := store.LoadState(workItemPath)
+				// Check the status of the quarantined work item
+				item, err := store.LoadQuarantined(0, workItemPath)
 				require.NoError(t, err)
 				require.Equal(t, item.Status, store.FAILED)
 				require.Contains(t, *item.Error, tc.err)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"os/exec"
+	"text/template"
 
 	"github.com/google/uuid"
 
@@ -27,19 +28,19 @@ func (c Config) Print() {
 // Validate the Config making sure all required fields are present and valid
 func (c Config) Validate() error {
 	if c.Url == "" {
-		return fmt.Errorf("url is required")
+		return invalidf("url is required")
 	}
 
 	if c.UUID != "" {
 		_, err := uuid.Parse(c.UUID)
 		if err != nil {
-			return fmt.Errorf("could not parse UUID: %w", err)
+			return invalidf("could not parse UUID: %w", err)
 		}
 	}
 
 	_, err := url.Parse(c.Url)
 	if err != nil {
-		return fmt.Errorf("could not parse URL: %w", err)
+		return invalidf("could not parse URL: %w", err)
 	}
 
 	return nil
@@ -56,11 +57,11 @@ func (c AuthConfig) Print() {
 
 func (c AuthConfig) Validate() error {
 	if c.Username == "" {
-		return fmt.Errorf("username is required")
+		return invalidf("username is required")
 	}
 
 	if c.Password == "" {
-		return fmt.Errorf("password is required")
+		return invalidf("password is required")
 	}
 
 	return nil
@@ -71,77 +72,235 @@ type ClaimConfig struct {
 }
 
 type MigrateConfig struct {
-	ChainID          string                     // The destination chain ID
-	AddressPrefix    string                     // The destination address prefix
-	NodeAddress      string                     // The destination RPC node address
-	KeyringBackend   string                     // The destination chain keyring backend to use
-	BankAddress      string                     // The destination chain address of the bank account to send tokens from
-	ChainHome        string                     // The root directory of the destination chain configuration
-	TokenMap         map[string]utils.TokenInfo // Map of source token address to destination token info
-	WaitTxTimeout    uint                       // Number of seconds spent waiting for the transaction to be included in a block
-	WaitBlockTimeout uint                       // Number of seconds spent waiting for the block to be committed
-	Binary           string                     // Binary name of the destination blockchain
-	GasPrice         float64                    // Minimum gas price to use for transactions
-	GasAdjustment    float64                    // Gas adjustment to use for transactions
-	GasDenom         string                     // Gas denomination to use for transactions
-	FeeGranter       string                     // The address of the gas fee granter
+	ChainID            string                     // The destination chain ID
+	AddressPrefix      string                     // The destination address prefix
+	NodeAddress        string                     // The destination RPC node address
+	KeyringBackend     string                     // The destination chain keyring backend to use
+	KeyringPassphrase  string                     // The passphrase unlocking the keyring, written to the chain binary standard input (optional)
+	BankAddress        string                     // The destination chain address of the bank account to send tokens from
+	ChainHome          string                     // The root directory of the destination chain configuration
+	TokenMap           map[string]utils.TokenInfo // Map of source token address to destination token info
+	WaitTxTimeout      uint                       // Number of seconds spent waiting for the transaction to be included in a block
+	WaitBlockTimeout   uint                       // Number of seconds spent waiting for the block to be committed
+	Binary             string                     // Binary name of the destination blockchain
+	GasPrice           float64                    // Minimum gas price to use for transactions
+	GasAdjustment      float64                    // Gas adjustment to use for transactions
+	GasDenom           string                     // Gas denomination to use for transactions
+	FeeGranter         string                     // The address of the gas fee granter
+	ManyNodeAddress    string                     // The MANY node address used to cross-verify the MANY transaction (optional)
+	MaxFee             uint                       // Maximum fee of a single transaction, in gas denomination (0 = no limit)
+	DailyFeeBudget     uint                       // Maximum fees spent per UTC day, in gas denomination (0 = no limit)
+	BroadcastRetries   uint                       // Maximum number of broadcast retries on retryable errors
+	BroadcastRetryWait uint                       // Number of seconds to wait before the first broadcast retry, doubled on each retry
+	Destinations       map[string]Destination     // Named destinations the tokens can be routed to (optional)
+	Destination        string                     // Name of the destination of this config, empty for the top-level config
 }
 
 func (c MigrateConfig) Validate() error {
 	if c.ChainID == "" {
-		return fmt.Errorf("chain ID is required")
+		return invalidf("chain ID is required")
 	}
 
 	if c.AddressPrefix == "" {
-		return fmt.Errorf("address prefix is required")
+		return invalidf("address prefix is required")
 	}
 
 	if c.NodeAddress == "" {
-		return fmt.Errorf("node address is required")
+		return invalidf("node address is required")
 	}
 
 	if c.KeyringBackend == "" {
-		return fmt.Errorf("keyring backend is required")
+		return invalidf("keyring backend is required")
 	}
 
 	if c.BankAddress == "" {
-		return fmt.Errorf("bank address is required")
+		return invalidf("bank address is required")
 	}
 
 	if c.ChainHome == "" {
-		return fmt.Errorf("chain home is required")
+		return invalidf("chain home is required")
 	}
 
 	if c.WaitTxTimeout == 0 {
-		return fmt.Errorf("wait for tx timeout > 0 is required")
+		return invalidf("wait for tx timeout > 0 is required")
 	}
 
 	if c.WaitBlockTimeout == 0 {
-		return fmt.Errorf("wait for block timeout > 0 is required")
+		return invalidf("wait for block timeout > 0 is required")
 	}
 
 	if c.Binary == "" {
-		return fmt.Errorf("binary is required")
+		return invalidf("binary is required")
 	}
 
 	if c.GasPrice < 0 {
-		return fmt.Errorf("gas price must be >= 0")
+		return invalidf("gas price must be >= 0")
 	}
 
 	if c.GasAdjustment < 0 {
-		return fmt.Errorf("gas adjustment must be >= 0")
+		return invalidf("gas adjustment must be >= 0")
 	}
 
 	if c.GasDenom == "" {
-		return fmt.Errorf("gas denom is required")
+		return invalidf("gas denom is required")
 	}
 
 	if c.FeeGranter == "" {
-		return fmt.Errorf("fee granter is required")
+		return invalidf("fee granter is required")
+	}
+
+	if c.ManyNodeAddress != "" {
+		if _, err := url.Parse(c.ManyNodeAddress); err != nil {
+			return invalidf("could not parse MANY node address: %w", err)
+		}
 	}
 
 	if _, err := exec.LookPath(c.Binary); err != nil {
-		return fmt.Errorf("binary %s not found in PATH", c.Binary)
+		return invalidf("binary %s not found in PATH", c.Binary)
+	}
+
+	// The config of a destination holds no destination
+	if c.Destination == "" {
+		return c.validateDestinations()
+	}
+
+	return nil
+}
+
+type WhitelistConfig struct {
+	Mode      string // The whitelist mode (remote, file, combined)
+	CacheTTL  uint   // Number of seconds the remote whitelist results are cached
+	CacheFile string // The file used to persist the remote whitelist cache (optional)
+	File      string // The signed local allowlist file
+	PublicKey string // The hex encoded ed25519 public key used to verify the local allowlist file signature
+}
+
+func (c WhitelistConfig) Validate() error {
+	switch c.Mode {
+	case "remote":
+	case "file", "combined":
+		if c.File == "" {
+			return invalidf("whitelist file is required in %s mode", c.Mode)
+		}
+
+		if c.PublicKey == "" {
+			return invalidf("whitelist public key is required in %s mode", c.Mode)
+		}
+	default:
+		return invalidf("invalid whitelist mode: %s", c.Mode)
+	}
+
+	return nil
+}
+
+type DenylistConfig struct {
+	Files           []string // Local denylist files
+	Urls            []string // HTTP denylist endpoints
+	RefreshInterval uint     // Number of seconds between two fetches of the HTTP denylists
+}
+
+func (c DenylistConfig) Validate() error {
+	for _, u := range c.Urls {
+		if _, err := url.Parse(u); err != nil {
+			return invalidf("could not parse denylist URL: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type NotifyConfig struct {
+	SlackUrls            []string // Slack incoming webhook URLs
+	DiscordUrls          []string // Discord webhook URLs
+	WebhookUrls          []string // Generic JSON webhook URLs
+	Template             string   // Go template of the notification text (optional)
+	DedupWindow          uint     // Number of seconds during which an identical notification is not sent again
+	RateLimit            uint     // Maximum number of notifications sent per rate limit window (0 = no limit)
+	RateLimitWindow      uint     // Number of seconds of the rate limit window
+	AuthFailureThreshold uint     // Number of consecutive Talib authentication failures triggering a notification
+	StateFile            string   // The file holding the deduplication and rate limiting state, shared by every migrator process
+}
+
+func (c NotifyConfig) Validate() error {
+	for _, urls := range [][]string{c.SlackUrls, c.DiscordUrls, c.WebhookUrls} {
+		for _, u := range urls {
+			if _, err := url.ParseRequestURI(u); err != nil {
+				return invalidf("could not parse webhook URL: %w", err)
+			}
+		}
+	}
+
+	if c.Template != "" {
+		if _, err := template.New("notification").Parse(c.Template); err != nil {
+			return invalidf("could not parse notification template: %w", err)
+		}
+	}
+
+	if c.RateLimit > 0 && c.RateLimitWindow == 0 {
+		return invalidf("notification rate limit window > 0 is required")
+	}
+
+	if c.AuthFailureThreshold == 0 {
+		return invalidf("auth failure threshold > 0 is required")
+	}
+
+	if c.StateFile == "" {
+		return invalidf("notification state file is required")
+	}
+
+	return nil
+}
+
+type TracingConfig struct {
+	Exporter string // The span exporter (none, stdout, otlp)
+	Endpoint string // The OTLP/HTTP endpoint URL (optional, defaults to the OTEL_EXPORTER_OTLP_* environment variables)
+	Insecure bool   // Disable TLS when exporting to the OTLP endpoint
+}
+
+func (c TracingConfig) Validate() error {
+	switch c.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Endpoint != "" {
+			if _, err := url.ParseRequestURI(c.Endpoint); err != nil {
+				return invalidf("could not parse OTLP endpoint: %w", err)
+			}
+		}
+	default:
+		return invalidf("invalid trace exporter: %s", c.Exporter)
+	}
+
+	return nil
+}
+
+type LogConfig struct {
+	Level      string   // The log level (debug, info, warn, error)
+	Format     string   // The log format (json, text)
+	Outputs    []string // The log outputs: stdout, stderr or a file path
+	MaxSize    uint     // Maximum size in megabytes of a log file before it is rotated
+	MaxBackups uint     // Maximum number of rotated log files to keep (0 = all)
+	MaxAge     uint     // Maximum number of days to keep the rotated log files (0 = forever)
+	Compress   bool     // Compress the rotated log files
+	WorkerID   string   // The ID of the migrator process, added to every log line
+}
+
+func (c LogConfig) Validate() error {
+	if c.Format != "json" && c.Format != "text" {
+		return invalidf("invalid log format: %s", c.Format)
+	}
+
+	if len(c.Outputs) == 0 {
+		return invalidf("at least one log output is required")
+	}
+
+	for _, output := range c.Outputs {
+		if output == "" {
+			return invalidf("log output cannot be empty")
+		}
+	}
+
+	if c.MaxSize == 0 {
+		return invalidf("log max size > 0 is required")
 	}
 
 	return nil
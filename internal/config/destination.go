@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// destinationNameRegexp restricts the destination names to the characters safe in file names
+var destinationNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Destination is a named destination chain and bank account the tokens of the token map can be routed to.
+// Unset settings keep their top-level value.
+type Destination struct {
+	ChainID        string  `mapstructure:"chain-id"`        // The destination chain ID
+	AddressPrefix  string  `mapstructure:"address-prefix"`  // The destination address prefix
+	NodeAddress    string  `mapstructure:"node-address"`    // The destination RPC node address
+	Binary         string  `mapstructure:"binary"`          // Binary name of the destination blockchain
+	ChainHome      string  `mapstructure:"chain-home"`      // The root directory of the destination chain configuration
+	KeyringBackend string  `mapstructure:"keyring-backend"` // The destination chain keyring backend to use
+	BankAddress    string  `mapstructure:"bank-address"`    // The bank key to send tokens from
+	FeeGranter     string  `mapstructure:"fee-granter"`     // The address of the gas fee granter
+	GasPrice       float64 `mapstructure:"gas-price"`       // Minimum gas price to use for transactions
+	GasAdjustment  float64 `mapstructure:"gas-adjustment"`  // Gas adjustment to use for transactions
+	GasDenom       string  `mapstructure:"gas-denom"`       // Gas denomination to use for transactions
+}
+
+// ForDestination returns the config of the named destination, i.e., the top-level config overridden by the settings
+// of the destination. The empty name is the top-level destination.
+func (c MigrateConfig) ForDestination(name string) (MigrateConfig, error) {
+	if name == "" {
+		return c, nil
+	}
+
+	d, ok := c.Destinations[name]
+	if !ok {
+		return MigrateConfig{}, invalidf("unknown destination: %s", name)
+	}
+
+	for _, o := range []struct {
+		dest  *string
+		value string
+	}{
+		{&c.ChainID, d.ChainID},
+		{&c.AddressPrefix, d.AddressPrefix},
+		{&c.NodeAddress, d.NodeAddress},
+		{&c.Binary, d.Binary},
+		{&c.ChainHome, d.ChainHome},
+		{&c.KeyringBackend, d.KeyringBackend},
+		{&c.BankAddress, d.BankAddress},
+		{&c.FeeGranter, d.FeeGranter},
+		{&c.GasDenom, d.GasDenom},
+	} {
+		if o.value != "" {
+			*o.dest = o.value
+		}
+	}
+	if d.GasPrice != 0 {
+		c.GasPrice = d.GasPrice
+	}
+	if d.GasAdjustment != 0 {
+		c.GasAdjustment = d.GasAdjustment
+	}
+
+	c.Destination = name
+	c.Destinations = nil
+	return c, nil
+}
+
+// ForToken returns the config of the destination the token is routed to
+func (c MigrateConfig) ForToken(tokenInfo utils.TokenInfo) (MigrateConfig, error) {
+	return c.ForDestination(tokenInfo.Destination)
+}
+
+// ChainIDs returns the sorted chain IDs the tokens may be sent to, i.e., the top-level chain ID, the chain ID of every
+// destination, and the chain ID of the destinations the token maps of the neighborhood overrides route to.
+// The unknown destinations are ignored, they are refused by Validate.
+func (c MigrateConfig) ChainIDs(overrides NeighborhoodOverrides) []string {
+	chainIDs := []string{c.ChainID}
+	add := func(name string) {
+		if d, err := c.ForDestination(name); err == nil && !slices.Contains(chainIDs, d.ChainID) {
+			chainIDs = append(chainIDs, d.ChainID)
+		}
+	}
+
+	for name := range c.Destinations {
+		add(name)
+	}
+	for _, n := range overrides {
+		for _, info := range n.TokenMap {
+			add(info.Destination)
+		}
+	}
+
+	slices.Sort(chainIDs)
+	return chainIDs
+}
+
+// validateDestinations makes sure every destination is valid and every token is routed to a known destination
+func (c MigrateConfig) validateDestinations() error {
+	for name := range c.Destinations {
+		if !destinationNameRegexp.MatchString(name) {
+			return invalidf("invalid destination name: %s", name)
+		}
+
+		d, err := c.ForDestination(name)
+		if err != nil {
+			return err
+		}
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("destination %s: %w", name, err)
+		}
+	}
+
+	for token, info := range c.TokenMap {
+		if _, err := c.ForToken(info); err != nil {
+			return fmt.Errorf("token %s: %w", token, err)
+		}
+	}
+
+	return nil
+}
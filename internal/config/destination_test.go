@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+func newRoutedConfig() config.MigrateConfig {
+	return config.MigrateConfig{
+		ChainID:          "manifest-1",
+		AddressPrefix:    "manifest",
+		NodeAddress:      "http://localhost:26657",
+		KeyringBackend:   "test",
+		BankAddress:      "bank",
+		ChainHome:        "/tmp",
+		WaitTxTimeout:    15,
+		WaitBlockTimeout: 30,
+		Binary:           "sh",
+		GasPrice:         0.0011,
+		GasAdjustment:    1.4,
+		GasDenom:         "umfx",
+		FeeGranter:       "feegranter",
+		TokenMap: map[string]utils.TokenInfo{
+			"mfx":   {Denom: "umfx"},
+			"other": {Denom: "uother", Destination: "other"},
+		},
+		Destinations: map[string]config.Destination{
+			"other": {ChainID: "other-1", NodeAddress: "http://other:26657", BankAddress: "other-bank", GasPrice: 0.5, GasDenom: "uother"},
+		},
+	}
+}
+
+func TestMigrateConfig_ForToken(t *testing.T) {
+	c := newRoutedConfig()
+	require.NoError(t, c.Validate())
+
+	d, err := c.ForToken(c.TokenMap["mfx"])
+	require.NoError(t, err)
+	require.Equal(t, c, d)
+
+	d, err = c.ForToken(c.TokenMap["other"])
+	require.NoError(t, err)
+	require.Equal(t, "other", d.Destination)
+	require.Equal(t, "other-1", d.ChainID)
+	require.Equal(t, "http://other:26657", d.NodeAddress)
+	require.Equal(t, "other-bank", d.BankAddress)
+	require.Equal(t, 0.5, d.GasPrice)
+	require.Equal(t, "uother", d.GasDenom)
+	require.Nil(t, d.Destinations)
+	// The settings the destination does not set are the top-level ones
+	require.Equal(t, "feegranter", d.FeeGranter)
+	require.Equal(t, 1.4, d.GasAdjustment)
+	require.Equal(t, c.TokenMap, d.TokenMap)
+}
+
+func TestMigrateConfig_ValidateDestinations(t *testing.T) {
+	c := newRoutedConfig()
+	c.TokenMap["unknown"] = utils.TokenInfo{Denom: "ux", Destination: "missing"}
+	require.ErrorIs(t, c.Validate(), config.ErrInvalidConfig)
+	require.ErrorContains(t, c.Validate(), "token unknown: unknown destination: missing")
+
+	c = newRoutedConfig()
+	c.Destinations["other"] = config.Destination{Binary: "missing-binary"}
+	require.ErrorIs(t, c.Validate(), config.ErrInvalidConfig)
+	require.ErrorContains(t, c.Validate(), "destination other: binary missing-binary not found in PATH")
+
+	c = newRoutedConfig()
+	c.Destinations["../other"] = config.Destination{}
+	require.ErrorContains(t, c.Validate(), "invalid destination name: ../other")
+}
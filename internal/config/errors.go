@@ -0,0 +1,21 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// ErrInvalidConfig is returned when the configuration is missing or invalid.
+// It is an operator error, the work item is left untouched.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// invalidf returns a formatted error matching ErrInvalidConfig
+func invalidf(format string, a ...interface{}) error {
+	return utils.Mark(fmt.Errorf(format, a...), ErrInvalidConfig)
+}
+
+// ErrEnvironmentMismatch is returned when the chain ID and the Talib URL belong to different environments,
+// e.g., a testnet chain ID used with the mainnet Talib URL.
+var ErrEnvironmentMismatch = errors.New("environment mismatch")
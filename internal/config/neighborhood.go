@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// NeighborhoodConfig holds the settings of a neighborhood overriding the top-level settings.
+// Unset settings keep their top-level value.
+type NeighborhoodConfig struct {
+	TokenMap           map[string]utils.TokenInfo `mapstructure:"token-map"`            // Replaces the top-level token map
+	WhitelistMode      string                     `mapstructure:"whitelist-mode"`       // The whitelist mode (remote, file, combined)
+	WhitelistFile      string                     `mapstructure:"whitelist-file"`       // The signed local allowlist file
+	WhitelistPublicKey string                     `mapstructure:"whitelist-public-key"` // The allowlist file public key
+	WhitelistCacheFile string                     `mapstructure:"whitelist-cache-file"` // The remote whitelist cache file
+}
+
+// NeighborhoodOverrides maps the neighborhood IDs to their overrides
+type NeighborhoodOverrides map[uint64]NeighborhoodConfig
+
+// Apply overrides the migrate and whitelist settings with the settings of the neighborhood, if any.
+// The remote whitelist cache of a neighborhood other than 0 is kept apart from the top-level one unless its cache file
+// is overridden.
+func (o NeighborhoodOverrides) Apply(neighborhood uint64, m *MigrateConfig, w *WhitelistConfig) {
+	n := o[neighborhood]
+	if n.TokenMap != nil {
+		m.TokenMap = n.TokenMap
+	}
+	if n.WhitelistMode != "" {
+		w.Mode = n.WhitelistMode
+	}
+	if n.WhitelistFile != "" {
+		w.File = n.WhitelistFile
+	}
+	if n.WhitelistPublicKey != "" {
+		w.PublicKey = n.WhitelistPublicKey
+	}
+	switch {
+	case n.WhitelistCacheFile != "":
+		w.CacheFile = n.WhitelistCacheFile
+	case w.CacheFile != "" && neighborhood != 0:
+		ext := filepath.Ext(w.CacheFile)
+		w.CacheFile = fmt.Sprintf("%s.neighborhood-%d%s", strings.TrimSuffix(w.CacheFile, ext), neighborhood, ext)
+	}
+}
+
+// ValidateNeighborhoods makes sure every neighborhood is processed once
+func ValidateNeighborhoods(neighborhoods []uint64) error {
+	seen := make(map[uint64]bool, len(neighborhoods))
+	for _, n := range neighborhoods {
+		if seen[n] {
+			return invalidf("duplicate neighborhood: %d", n)
+		}
+		seen[n] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// Environments are the environments a profile can target
+var Environments = []string{"mainnet", "testnet", "local"}
+
+// ProfileKeys are the settings a profile can bundle
+var ProfileKeys = []string{
+	"url",
+	"neighborhood",
+	"neighborhoods",
+	"chain-id",
+	"address-prefix",
+	"node-address",
+	"bank-address",
+	"keyring-backend",
+	"chain-home",
+	"many-node-address",
+	"token-map",
+	"destinations",
+}
+
+// Profile is a named set of settings targeting an environment
+type Profile struct {
+	Environment string // The environment targeted by the profile, defaults to the profile name
+	Url         string // The Talib URL
+	ChainID     string `mapstructure:"chain-id"` // The destination chain ID
+}
+
+// Profiles maps the profile names to their profile
+type Profiles map[string]Profile
+
+// environment returns the environment of the named profile
+func (p Profiles) environment(name string) string {
+	if env := p[name].Environment; env != "" {
+		return env
+	}
+	return name
+}
+
+func (p Profiles) Validate() error {
+	for name := range p {
+		if env := p.environment(name); !slices.Contains(Environments, env) {
+			return invalidf("invalid environment of profile %s: %s (%s)", name, env, strings.Join(Environments, "|"))
+		}
+	}
+
+	return nil
+}
+
+// CheckEnvironment refuses chain IDs and a Talib URL belonging to profiles of different environments.
+// Every effective chain ID is checked, e.g., the top-level one and the one of each destination.
+// When a profile is selected, the URL and the chain IDs must belong to its environment, if they belong to any profile.
+func (p Profiles) CheckEnvironment(selected, url string, chainIDs ...string) error {
+	urlEnvs := p.environments(func(profile Profile) bool { return profile.Url != "" && normalizeUrl(profile.Url) == normalizeUrl(url) })
+
+	if selected != "" {
+		env := p.environment(selected)
+		if len(urlEnvs) > 0 && !slices.Contains(urlEnvs, env) {
+			return mismatchf("Talib URL %s belongs to the %s environment, not to the %s environment of profile %s", url, strings.Join(urlEnvs, "|"), env, selected)
+		}
+	}
+
+	for _, chainID := range chainIDs {
+		chainEnvs := p.environments(func(profile Profile) bool { return profile.ChainID != "" && profile.ChainID == chainID })
+
+		if selected != "" {
+			env := p.environment(selected)
+			if len(chainEnvs) > 0 && !slices.Contains(chainEnvs, env) {
+				return mismatchf("chain ID %s belongs to the %s environment, not to the %s environment of profile %s", chainID, strings.Join(chainEnvs, "|"), env, selected)
+			}
+		}
+
+		if len(urlEnvs) > 0 && len(chainEnvs) > 0 && !slices.ContainsFunc(urlEnvs, func(env string) bool { return slices.Contains(chainEnvs, env) }) {
+			return mismatchf("chain ID %s (%s) and Talib URL %s (%s) belong to different environments", chainID, strings.Join(chainEnvs, "|"), url, strings.Join(urlEnvs, "|"))
+		}
+	}
+
+	return nil
+}
+
+// environments returns the sorted environments of the profiles matching the predicate
+func (p Profiles) environments(match func(Profile) bool) []string {
+	var envs []string
+	for name, profile := range p {
+		if env := p.environment(name); match(profile) && !slices.Contains(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+	slices.Sort(envs)
+	return envs
+}
+
+func normalizeUrl(url string) string {
+	return strings.TrimSuffix(strings.ToLower(url), "/")
+}
+
+func mismatchf(format string, a ...interface{}) error {
+	return utils.Mark(fmt.Errorf(format, a...), ErrEnvironmentMismatch)
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"reflect"
+)
+
+// ReloadableSettings are the migration settings safe to change while the migrator runs
+var ReloadableSettings = []string{
+	"token-map",
+	"gas-price",
+	"gas-adjustment",
+	"max-fee",
+	"daily-fee-budget",
+	"broadcast-retries",
+	"broadcast-retry-wait",
+}
+
+// Reload returns the config updated with the reloadable settings of `next` and the names of the changed settings.
+// The other settings are kept; `restartRequired` is true if any of them changed.
+func (c MigrateConfig) Reload(next MigrateConfig) (reloaded MigrateConfig, changed []string, restartRequired bool) {
+	reloaded = c
+	settings := []struct {
+		key     string
+		changed bool
+		apply   func(*MigrateConfig, MigrateConfig)
+	}{
+		{"token-map", !reflect.DeepEqual(c.TokenMap, next.TokenMap), func(c *MigrateConfig, n MigrateConfig) { c.TokenMap = n.TokenMap }},
+		{"gas-price", c.GasPrice != next.GasPrice, func(c *MigrateConfig, n MigrateConfig) { c.GasPrice = n.GasPrice }},
+		{"gas-adjustment", c.GasAdjustment != next.GasAdjustment, func(c *MigrateConfig, n MigrateConfig) { c.GasAdjustment = n.GasAdjustment }},
+		{"max-fee", c.MaxFee != next.MaxFee, func(c *MigrateConfig, n MigrateConfig) { c.MaxFee = n.MaxFee }},
+		{"daily-fee-budget", c.DailyFeeBudget != next.DailyFeeBudget, func(c *MigrateConfig, n MigrateConfig) { c.DailyFeeBudget = n.DailyFeeBudget }},
+		{"broadcast-retries", c.BroadcastRetries != next.BroadcastRetries, func(c *MigrateConfig, n MigrateConfig) { c.BroadcastRetries = n.BroadcastRetries }},
+		{"broadcast-retry-wait", c.BroadcastRetryWait != next.BroadcastRetryWait, func(c *MigrateConfig, n MigrateConfig) { c.BroadcastRetryWait = n.BroadcastRetryWait }},
+	}
+
+	// `unsafe` is `next` with the current reloadable settings, it differs from the current config if any other
+	// setting changed
+	unsafe := next
+	for _, s := range settings {
+		s.apply(&unsafe, c)
+		if s.changed {
+			s.apply(&reloaded, next)
+			changed = append(changed, s.key)
+		}
+	}
+
+	return reloaded, changed, !reflect.DeepEqual(unsafe, c)
+}
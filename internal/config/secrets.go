@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// secretsHTTPTimeout bounds the time spent fetching a secret from the HTTP secrets endpoint
+const secretsHTTPTimeout = 10 * time.Second
+
+// SecretProvider resolves the secrets of a reference scheme
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// FileSecretProvider reads the secret from a file, e.g., a Docker or Kubernetes secret.
+// The trailing newline, if any, is removed.
+type FileSecretProvider struct{}
+
+func (FileSecretProvider) Resolve(_ context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+	if err != nil {
+		return "", invalidf("could not read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider reads the secret from an environment variable
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Resolve(_ context.Context, ref string) (string, error) {
+	name := strings.TrimPrefix(ref, "env:")
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", invalidf("secret environment variable %s not set", name)
+	}
+	return value, nil
+}
+
+// HTTPSecretProvider fetches the secret from an HTTP secrets endpoint.
+// The response is either the secret, as plain text, or a JSON document of the form `{"value": "..."}`.
+type HTTPSecretProvider struct {
+	r     *resty.Client
+	token string // Bearer token authenticating the requests (optional)
+}
+
+func NewHTTPSecretProvider(r *resty.Client, token string) *HTTPSecretProvider {
+	return &HTTPSecretProvider{r: r, token: token}
+}
+
+func (p *HTTPSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretsHTTPTimeout)
+	defer cancel()
+
+	req := p.r.R().SetContext(ctx)
+	if p.token != "" {
+		req.SetAuthToken(p.token)
+	}
+
+	resp, err := req.Get(ref)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch secret: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return "", fmt.Errorf("could not fetch secret: response status code: %d", resp.StatusCode())
+	}
+
+	if !strings.Contains(resp.Header().Get("Content-Type"), "json") {
+		return strings.TrimRight(resp.String(), "\r\n"), nil
+	}
+
+	var secret struct {
+		Value *string `json:"value"`
+	}
+	if err := p.r.JSONUnmarshal(resp.Body(), &secret); err != nil {
+		return "", fmt.Errorf("could not parse secret: %w", err)
+	}
+	if secret.Value == nil {
+		return "", fmt.Errorf("could not parse secret: no value")
+	}
+	return *secret.Value, nil
+}
+
+type SecretsConfig struct {
+	HttpTokenFile string // The file holding the bearer token of the HTTP secrets endpoint (optional)
+}
+
+// Secrets resolves the secret references: `file:PATH`, `env:NAME` or an `http://` or `https://` URL
+type Secrets struct {
+	providers map[string]SecretProvider
+}
+
+// NewSecrets returns the secrets resolver of every supported scheme
+func NewSecrets(c SecretsConfig) (*Secrets, error) {
+	var token string
+	if c.HttpTokenFile != "" {
+		var err error
+		if token, err = (FileSecretProvider{}).Resolve(context.Background(), c.HttpTokenFile); err != nil {
+			return nil, err
+		}
+	}
+
+	httpProvider := NewHTTPSecretProvider(resty.New(), token)
+	return &Secrets{providers: map[string]SecretProvider{
+		"file":  FileSecretProvider{},
+		"env":   EnvSecretProvider{},
+		"http":  httpProvider,
+		"https": httpProvider,
+	}}, nil
+}
+
+// Resolve returns the secret referenced by `ref`
+func (s *Secrets) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, _, found := strings.Cut(ref, ":")
+	provider, ok := s.providers[scheme]
+	if !found || !ok {
+		return "", invalidf("invalid secret reference, expected file:PATH, env:NAME or an http(s) URL")
+	}
+	return provider.Resolve(ctx, ref)
+}
@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+// newSecretsServer returns a secrets endpoint stub serving the secrets of the requests bearing the token
+func newSecretsServer(t *testing.T, token string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/plain":
+			_, _ = w.Write([]byte("plain-secret\n"))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"value":"json-secret"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSecrets_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("some-token\n"), 0600))
+	secretFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-secret\r\n"), 0600))
+	t.Setenv("MFX_TEST_SECRET", "env-secret")
+
+	s := newSecretsServer(t, "some-token")
+	secrets, err := config.NewSecrets(config.SecretsConfig{HttpTokenFile: tokenFile})
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		ref    string
+		secret string
+		err    string
+	}{
+		{name: "file", ref: "file:" + secretFile, secret: "file-secret"},
+		{name: "env", ref: "env:MFX_TEST_SECRET", secret: "env-secret"},
+		{name: "http_plain", ref: s.URL + "/plain", secret: "plain-secret"},
+		{name: "http_json", ref: s.URL + "/json", secret: "json-secret"},
+		{name: "missing_file", ref: "file:" + filepath.Join(dir, "missing"), err: "could not read secret file"},
+		{name: "missing_env", ref: "env:MFX_TEST_MISSING", err: "secret environment variable MFX_TEST_MISSING not set"},
+		{name: "http_not_found", ref: s.URL + "/missing", err: "response status code: 404"},
+		{name: "invalid_reference", ref: "hunter2", err: "invalid secret reference"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			secret, err := secrets.Resolve(context.Background(), tc.ref)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				require.NotContains(t, err.Error(), "hunter2")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.secret, secret)
+		})
+	}
+
+	// Without token, the requests are not authorized
+	secrets, err = config.NewSecrets(config.SecretsConfig{})
+	require.NoError(t, err)
+	_, err = secrets.Resolve(context.Background(), s.URL+"/plain")
+	require.ErrorContains(t, err, "response status code: 401")
+}
@@ -0,0 +1,110 @@
+package denylist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// Source is a denylist source
+//
+// A non-nil error means the source could not be loaded. Such errors are transient and the screening can be retried.
+type Source interface {
+	Name() string
+	Contains(ctx context.Context, address string) (bool, error)
+}
+
+// ErrUnavailable is returned when a denylist source could not be loaded. The screening can be retried.
+var ErrUnavailable = errors.New("denylist unavailable")
+
+// MatchError is returned when an address matches a denylist source
+type MatchError struct {
+	Address string
+	Source  string
+}
+
+func (e *MatchError) Error() string {
+	return fmt.Sprintf("address %s matched denylist %s", e.Address, e.Source)
+}
+
+// Screener checks addresses against every configured denylist source
+type Screener struct {
+	sources []Source
+}
+
+func NewScreener(sources ...Source) *Screener {
+	return &Screener{sources: sources}
+}
+
+// NewScreenerFromConfig creates a screener using the denylist sources described by the config
+func NewScreenerFromConfig(c config.DenylistConfig) *Screener {
+	var sources []Source
+	for _, path := range c.Files {
+		sources = append(sources, NewFileSource(path))
+	}
+
+	refresh := time.Duration(c.RefreshInterval) * time.Second
+	for _, url := range c.Urls {
+		sources = append(sources, NewHTTPSource(resty.New(), url, refresh))
+	}
+
+	return NewScreener(sources...)
+}
+
+// Screen checks the addresses against every denylist source.
+// It returns a MatchError on the first match.
+// Addresses are compared case-insensitively as bech32 also accepts all-uppercase addresses.
+func (s *Screener) Screen(ctx context.Context, addresses ...string) error {
+	for _, source := range s.sources {
+		for _, address := range addresses {
+			found, err := source.Contains(ctx, strings.ToLower(address))
+			if err != nil {
+				return utils.Mark(fmt.Errorf("unable to screen address using denylist %s: %w", source.Name(), err), ErrUnavailable)
+			}
+
+			if found {
+				return &MatchError{Address: address, Source: source.Name()}
+			}
+		}
+	}
+
+	return nil
+}
+
+// parse parses a denylist.
+//
+// A denylist is either a JSON array of addresses or a list of addresses, one per line.
+// Empty lines and lines starting with `#` are ignored. The addresses are lowercased.
+func parse(data []byte) (map[string]struct{}, error) {
+	entries := map[string]struct{}{}
+
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, "[") {
+		var addresses []string
+		if err := json.Unmarshal([]byte(content), &addresses); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal denylist: %w", err)
+		}
+		for _, address := range addresses {
+			entries[strings.ToLower(strings.TrimSpace(address))] = struct{}{}
+		}
+		return entries, nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries[strings.ToLower(line)] = struct{}{}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,95 @@
+package denylist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/denylist"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# blocked addresses\nmaa\n"), 0600))
+
+	screener := denylist.NewScreener(denylist.NewFileSource(path))
+	require.NoError(t, screener.Screen(context.Background(), testutils.ManifestAddress, testutils.ManyFrom))
+
+	// The file is reloaded when it changes
+	require.NoError(t, os.WriteFile(path, []byte("maa\n"+testutils.ManyFrom+"\n"), 0600))
+	var matchErr *denylist.MatchError
+	require.ErrorAs(t, screener.Screen(context.Background(), testutils.ManifestAddress, testutils.ManyFrom), &matchErr)
+	require.Equal(t, testutils.ManyFrom, matchErr.Address)
+	require.Equal(t, path, matchErr.Source)
+
+	// Addresses are compared case-insensitively
+	require.NoError(t, os.WriteFile(path, []byte(strings.ToUpper(testutils.ManifestAddress)+"\n"), 0600))
+	upper := strings.ToUpper(testutils.ManifestAddress)
+	require.ErrorAs(t, screener.Screen(context.Background(), upper), &matchErr)
+	require.Equal(t, upper, matchErr.Address)
+	require.True(t, isMatch(screener.Screen(context.Background(), testutils.ManifestAddress)))
+
+	require.NoError(t, os.Remove(path))
+	err := screener.Screen(context.Background(), testutils.ManifestAddress)
+	require.ErrorContains(t, err, "unable to screen address")
+	require.ErrorIs(t, err, denylist.ErrUnavailable)
+	require.False(t, isMatch(err))
+}
+
+func TestHTTPSource(t *testing.T) {
+	var calls atomic.Int32
+	var body atomic.Value
+	body.Store(`["maa"]`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		etag := `"` + body.Load().(string) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer server.Close()
+
+	t.Run("cached", func(t *testing.T) {
+		screener := denylist.NewScreener(denylist.NewHTTPSource(resty.New(), server.URL, time.Hour))
+		require.NoError(t, screener.Screen(context.Background(), testutils.ManifestAddress))
+		require.NoError(t, screener.Screen(context.Background(), testutils.ManifestAddress))
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("refreshed", func(t *testing.T) {
+		calls.Store(0)
+		screener := denylist.NewScreener(denylist.NewHTTPSource(resty.New(), server.URL, 0))
+		require.NoError(t, screener.Screen(context.Background(), testutils.ManifestAddress))
+		require.NoError(t, screener.Screen(context.Background(), testutils.ManifestAddress)) // Not modified
+		body.Store(`["` + testutils.ManifestAddress + `"]`)
+		require.True(t, isMatch(screener.Screen(context.Background(), testutils.ManifestAddress)))
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		notFound := httptest.NewServer(http.NotFoundHandler())
+		defer notFound.Close()
+		screener := denylist.NewScreener(denylist.NewHTTPSource(resty.New(), notFound.URL, 0))
+		err := screener.Screen(context.Background(), testutils.ManifestAddress)
+		require.ErrorContains(t, err, "response status code: 404")
+		require.False(t, isMatch(err))
+	})
+}
+
+func isMatch(err error) bool {
+	_, ok := err.(*denylist.MatchError)
+	return ok
+}
@@ -0,0 +1,70 @@
+package denylist
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSource is a denylist stored in a local file.
+// The file is reloaded whenever its modification time changes.
+type FileSource struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	entries map[string]struct{}
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (s *FileSource) Name() string {
+	return s.path
+}
+
+func (s *FileSource) Contains(_ context.Context, address string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+
+	_, ok := s.entries[address]
+	return ok, nil
+}
+
+// reload reloads the denylist file if it changed since the last load
+func (s *FileSource) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat denylist file: %w", err)
+	}
+
+	if s.entries != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read denylist file: %w", err)
+	}
+
+	entries, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("denylist file loaded", "path", s.path, "entries", len(entries))
+	s.entries = entries
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return nil
+}
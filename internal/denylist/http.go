@@ -0,0 +1,86 @@
+package denylist
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// HTTPSource is a denylist served by an HTTP endpoint.
+// The denylist is fetched again once `refresh` has elapsed since the last fetch.
+type HTTPSource struct {
+	r       *resty.Client
+	url     string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	etag      string
+	entries   map[string]struct{}
+}
+
+func NewHTTPSource(r *resty.Client, url string, refresh time.Duration) *HTTPSource {
+	return &HTTPSource{r: r, url: url, refresh: refresh}
+}
+
+func (s *HTTPSource) Name() string {
+	return s.url
+}
+
+func (s *HTTPSource) Contains(ctx context.Context, address string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil || time.Since(s.fetchedAt) >= s.refresh {
+		if err := s.fetch(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	_, ok := s.entries[address]
+	return ok, nil
+}
+
+// fetch fetches the denylist from the HTTP endpoint
+func (s *HTTPSource) fetch(ctx context.Context) error {
+	req := s.r.R().SetContext(ctx)
+	if s.etag != "" && s.entries != nil {
+		req.SetHeader("If-None-Match", s.etag)
+	}
+
+	resp, err := req.Get(s.url)
+	if err != nil {
+		return errors.WithMessage(err, "error getting denylist")
+	}
+
+	if resp == nil {
+		return fmt.Errorf("no response returned when getting denylist")
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusNotModified:
+		s.fetchedAt = time.Now()
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("response status code: %d", resp.StatusCode())
+	}
+
+	entries, err := parse(resp.Body())
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("denylist fetched", "url", s.url, "entries", len(entries))
+	s.entries = entries
+	s.etag = resp.Header().Get("ETag")
+	s.fetchedAt = time.Now()
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+const (
+	WorkerKey   = "worker"
+	WorkItemKey = "uuid"
+	// NeighborhoodKey is the attribute holding the neighborhood ID of the work item
+	NeighborhoodKey = "neighborhood"
+	// AuditKey is the attribute naming the audit event of a log record, e.g., `config-reload`
+	AuditKey = "audit"
+)
+
+var (
+	ValidLevels = map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	ValidLevelsStr = strings.Join(utils.GetKeys(ValidLevels), "|")
+)
+
+// Setup installs the default logger.
+// Secrets are redacted from every record and every line carries the worker ID.
+// It returns the function closing the log files.
+func Setup(c config.LogConfig) (func() error, error) {
+	level, exists := ValidLevels[c.Level]
+	if !exists {
+		return nil, fmt.Errorf("invalid log level: %s. Valid log levels are: %s", c.Level, ValidLevelsStr)
+	}
+
+	w, closeFn := newWriter(c)
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	switch c.Format {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		_ = closeFn()
+		return nil, fmt.Errorf("invalid log format: %s", c.Format)
+	}
+
+	logger := slog.New(handler)
+	if c.WorkerID != "" {
+		logger = logger.With(WorkerKey, c.WorkerID)
+	}
+	slog.SetDefault(logger)
+
+	return closeFn, nil
+}
+
+// WithWorkItem sets the default logger to a child logger carrying the work item neighborhood and UUID.
+// It returns the function restoring the previous default logger.
+func WithWorkItem(neighborhood uint64, uuid string) func() {
+	previous := slog.Default()
+	slog.SetDefault(previous.With(NeighborhoodKey, neighborhood, WorkItemKey, uuid))
+	return func() { slog.SetDefault(previous) }
+}
+
+// DefaultWorkerID returns an ID identifying the migrator process, i.e., the host name and the process ID
+func DefaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// newWriter returns the writer of every configured output.
+// `stdout` and `stderr` are the standard streams, any other output is a file rotated when it reaches its maximum size.
+func newWriter(c config.LogConfig) (io.Writer, func() error) {
+	var writers []io.Writer
+	var files []*lumberjack.Logger
+	for _, output := range c.Outputs {
+		switch output {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		default:
+			file := &lumberjack.Logger{
+				Filename:   output,
+				MaxSize:    int(c.MaxSize),
+				MaxBackups: int(c.MaxBackups),
+				MaxAge:     int(c.MaxAge),
+				Compress:   c.Compress,
+			}
+			writers = append(writers, file)
+			files = append(files, file)
+		}
+	}
+
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+
+	closeFn := func() error {
+		var errs []error
+		for _, file := range files {
+			errs = append(errs, file.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	return io.MultiWriter(writers...), closeFn
+}
@@ -0,0 +1,119 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/logging"
+)
+
+const mnemonic = "abandon ability able about above absent absorb abstract absurd abuse access accident"
+
+// setupFileLogger installs a default logger writing to a temporary file and returns the file path
+func setupFileLogger(t *testing.T, format string) string {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	file := filepath.Join(t.TempDir(), "migrator.log")
+	closeFn, err := logging.Setup(config.LogConfig{
+		Level:    "debug",
+		Format:   format,
+		Outputs:  []string{file},
+		MaxSize:  1,
+		WorkerID: "worker-1",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = closeFn() })
+	return file
+}
+
+func readLines(t *testing.T, file string) []map[string]any {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	var lines []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var l map[string]any
+		require.NoError(t, json.Unmarshal(line, &l))
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func TestLogging_Redaction(t *testing.T) {
+	file := setupFileLogger(t, "json")
+
+	slog.Debug("args", "auth-c", config.AuthConfig{Username: "user", Password: "hunter2"})
+	slog.Debug("setting auth token", "token", "some-token")
+	slog.Info("request", "header", "Authorization: Bearer abc.def-ghi", "jwt", "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig")
+	slog.Info("keys", "keys", map[string]string{"keyring-passphrase": "pass", "denom": "umfx"})
+	slog.Error("failure", "error", errors.New("invalid mnemonic: "+mnemonic))
+
+	lines := readLines(t, file)
+	require.Len(t, lines, 5)
+	require.Equal(t, map[string]any{"Username": "user", "Password": logging.Redacted}, lines[0]["auth-c"])
+	require.Equal(t, logging.Redacted, lines[1]["token"])
+	require.Equal(t, "Authorization: Bearer "+logging.Redacted, lines[2]["header"])
+	require.Equal(t, logging.Redacted, lines[2]["jwt"])
+	require.Equal(t, map[string]any{"keyring-passphrase": logging.Redacted, "denom": "umfx"}, lines[3]["keys"])
+	require.Equal(t, "invalid mnemonic: "+logging.Redacted, lines[4]["error"])
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	for _, secret := range []string{"hunter2", "some-token", "abc.def-ghi", "eyJ", "pass\"", "abandon"} {
+		require.NotContains(t, string(data), secret)
+	}
+}
+
+func TestLogging_WorkItem(t *testing.T) {
+	file := setupFileLogger(t, "json")
+
+	slog.Info("before")
+	restore := logging.WithWorkItem(2, "some-uuid")
+	slog.Info("during")
+	restore()
+	slog.Info("after")
+
+	lines := readLines(t, file)
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		require.Equal(t, "worker-1", line[logging.WorkerKey])
+	}
+	require.NotContains(t, lines[0], logging.WorkItemKey)
+	require.Equal(t, "some-uuid", lines[1][logging.WorkItemKey])
+	require.Equal(t, float64(2), lines[1][logging.NeighborhoodKey])
+	require.NotContains(t, lines[2], logging.WorkItemKey)
+}
+
+func TestLogging_Text(t *testing.T) {
+	file := setupFileLogger(t, "text")
+
+	slog.Info("hello", "password", "hunter2")
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	line := string(data)
+	require.True(t, strings.HasPrefix(line, "time="))
+	require.Contains(t, line, "msg=hello")
+	require.Contains(t, line, "password="+logging.Redacted)
+	require.Contains(t, line, "worker=worker-1")
+}
+
+func TestLogging_Invalid(t *testing.T) {
+	_, err := logging.Setup(config.LogConfig{Level: "trace", Format: "json"})
+	require.ErrorContains(t, err, "invalid log level: trace")
+
+	_, err = logging.Setup(config.LogConfig{Level: "info", Format: "xml"})
+	require.ErrorContains(t, err, "invalid log format: xml")
+
+	require.ErrorIs(t, config.LogConfig{Level: "info", Format: "xml"}.Validate(), config.ErrInvalidConfig)
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the redacted values
+const Redacted = "[REDACTED]"
+
+// maxRedactDepth bounds the depth of the structures inspected for secrets
+const maxRedactDepth = 5
+
+// sensitiveKeySuffixes are the suffixes of the normalized attribute and field names holding secrets,
+// e.g., `password`, `AccessToken` or `keyring-passphrase`
+var sensitiveKeySuffixes = []string{
+	"password",
+	"passwd",
+	"secret",
+	"mnemonic",
+	"passphrase",
+	"authorization",
+	"token",
+	"apikey",
+	"privatekey",
+	"statekey",
+}
+
+var (
+	bearerRegexp = regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/=-]+`)
+	jwtRegexp    = regexp.MustCompile(`eyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]*`)
+	// A BIP-39 mnemonic is 12 to 24 lowercase words
+	mnemonicRegexp = regexp.MustCompile(`\b(?:[a-z]{3,8} ){11,23}[a-z]{3,8}\b`)
+)
+
+// IsSensitiveKey returns true if the attribute or field name denotes a secret
+func IsSensitiveKey(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", "_", "", ".", "").Replace(key))
+	for _, suffix := range sensitiveKeySuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString redacts the bearer tokens, JWTs and mnemonics found in the string
+func RedactString(s string) string {
+	s = bearerRegexp.ReplaceAllString(s, "${1}"+Redacted)
+	s = jwtRegexp.ReplaceAllString(s, Redacted)
+	return mnemonicRegexp.ReplaceAllString(s, Redacted)
+}
+
+// redactAttr redacts the secrets of a log attribute.
+// Attributes with a sensitive name are redacted, structures and maps are inspected field by field and strings are
+// scanned for tokens and mnemonics.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	a.Value = redactValue(a.Value, 0)
+	return a
+}
+
+func redactValue(v slog.Value, depth int) slog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(RedactString(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			redacted[i] = redactAttr(nil, a)
+		}
+		return slog.GroupValue(redacted...)
+	case slog.KindAny:
+		return redactAny(v.Any(), depth)
+	default:
+		return v
+	}
+}
+
+// redactAny redacts an arbitrary value.
+// Errors are redacted as strings, structures and maps with string keys are converted to groups.
+// Values with their own text or JSON representation are kept as is.
+func redactAny(value any, depth int) slog.Value {
+	switch value := value.(type) {
+	case nil:
+		return slog.AnyValue(nil)
+	case error:
+		return slog.StringValue(RedactString(value.Error()))
+	case fmt.Stringer, encoding.TextMarshaler, json.Marshaler:
+		return slog.AnyValue(value)
+	}
+
+	if depth >= maxRedactDepth {
+		return slog.AnyValue(value)
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		var attrs []slog.Attr
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			attrs = append(attrs, redactField(field.Name, rv.Field(i), depth))
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return slog.AnyValue(value)
+		}
+		var attrs []slog.Attr
+		iter := rv.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, redactField(iter.Key().String(), iter.Value(), depth))
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.String:
+		return slog.StringValue(RedactString(rv.String()))
+	default:
+		return slog.AnyValue(rv.Interface())
+	}
+}
+
+func redactField(name string, v reflect.Value, depth int) slog.Attr {
+	if IsSensitiveKey(name) {
+		return slog.String(name, Redacted)
+	}
+	return slog.Attr{Key: name, Value: redactAny(v.Interface(), depth+1)}
+}
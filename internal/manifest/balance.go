@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+// balanceResponse is the output of `q bank balances --denom`
+type balanceResponse struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+// QueryBalance queries the bank account balance of the given denomination
+func QueryBalance(ctx context.Context, c config.MigrateConfig, denom string) (*big.Int, error) {
+	ctx, cancel := withTimeout(ctx, c.WaitTxTimeout)
+	defer cancel()
+	address, err := resolveBankAddress(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := executeCommand(ctx, c.Binary, "q", "bank", "balances", address, "--denom", denom, "--node", c.NodeAddress, "--home", c.ChainHome, "--output", OutputFormat)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to query bank balance")
+	}
+
+	var res balanceResponse
+	if err = unmarshalOutput(o, &res); err != nil {
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(res.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse bank balance: %s", res.Amount)
+	}
+
+	return balance, nil
+}
+
+// Preflight checks the destination of the config before the migration starts, i.e., the bank key resolves, the chain
+// answers and the bank balance covers the amount of tokens to send.
+// It returns ErrChainUnavailable or ErrInsufficientBalance, the migration can be retried.
+func Preflight(ctx context.Context, c config.MigrateConfig, tokenInfo utils.TokenInfo, amount *big.Int) error {
+	balance, err := QueryBalance(ctx, c, tokenInfo.Denom)
+	if err != nil {
+		return utils.Mark(errors.WithMessagef(err, "preflight of destination %s failed", destinationName(c)), ErrChainUnavailable)
+	}
+
+	if balance.Cmp(amount) < 0 {
+		return errors.WithMessagef(ErrInsufficientBalance, "destination %s holds %s%s, %s%s required", destinationName(c), balance, tokenInfo.Denom, amount, tokenInfo.Denom)
+	}
+
+	return nil
+}
+
+// destinationName returns the printable name of the destination of the config
+func destinationName(c config.MigrateConfig) string {
+	if c.Destination == "" {
+		return "default"
+	}
+	return c.Destination
+}
+
+// resolveBankAddress returns the address of the bank key
+func resolveBankAddress(ctx context.Context, c config.MigrateConfig) (string, error) {
+	o, err := executeCommandWithInput(ctx, keyringInput(c), c.Binary, "keys", "show", c.BankAddress, "-a", "--keyring-backend", c.KeyringBackend, "--home", c.ChainHome)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to get bank address")
+	}
+	return strings.TrimSpace(string(o)), nil
+}
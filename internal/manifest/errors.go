@@ -0,0 +1,153 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SdkCodespace is the Cosmos SDK error codespace
+const SdkCodespace = "sdk"
+
+// Cosmos SDK error codes, see `cosmos-sdk/types/errors/errors.go`
+const (
+	ErrTxDecodeCode          = 2
+	ErrInvalidSequenceCode   = 3
+	ErrUnauthorizedCode      = 4
+	ErrInsufficientFundsCode = 5
+	ErrInvalidAddressCode    = 7
+	ErrInvalidCoinsCode      = 10
+	ErrOutOfGasCode          = 11
+	ErrInsufficientFeeCode   = 13
+	ErrTxInMempoolCacheCode  = 19
+	ErrMempoolIsFullCode     = 20
+	ErrTxTooLargeCode        = 21
+	ErrInvalidChainIDCode    = 28
+	ErrTxTimeoutHeightCode   = 30
+	ErrWrongSequenceCode     = 32
+	ErrInvalidGasLimitCode   = 41
+)
+
+// sdkErrorNames maps the Cosmos SDK error codes to a human-readable reason
+var sdkErrorNames = map[int]string{
+	ErrTxDecodeCode:          "tx parse error",
+	ErrInvalidSequenceCode:   "invalid sequence",
+	ErrUnauthorizedCode:      "unauthorized",
+	ErrInsufficientFundsCode: "insufficient funds",
+	ErrInvalidAddressCode:    "invalid address",
+	ErrInvalidCoinsCode:      "invalid coins",
+	ErrOutOfGasCode:          "out of gas",
+	ErrInsufficientFeeCode:   "insufficient fee",
+	ErrTxInMempoolCacheCode:  "tx already in mempool",
+	ErrMempoolIsFullCode:     "mempool is full",
+	ErrTxTooLargeCode:        "tx too large",
+	ErrInvalidChainIDCode:    "invalid chain-id",
+	ErrTxTimeoutHeightCode:   "tx timeout height",
+	ErrWrongSequenceCode:     "incorrect account sequence",
+	ErrInvalidGasLimitCode:   "invalid gas limit",
+}
+
+// retryableSdkErrors are the Cosmos SDK errors returned by CheckTx that are safe to retry.
+// A transaction rejected by CheckTx never entered the mempool, i.e., it provably did not land.
+var retryableSdkErrors = map[int]bool{
+	ErrMempoolIsFullCode: true,
+	ErrWrongSequenceCode: true,
+}
+
+// ErrChainUnavailable is returned when the chain could not be queried before broadcasting, e.g., the node is down.
+// No transaction was broadcast and the migration can be retried.
+var ErrChainUnavailable = errors.New("chain unavailable")
+
+// ErrInsufficientBalance is returned when the bank account cannot cover the migration before broadcasting.
+// No transaction was broadcast and the migration can be retried once the bank account is funded.
+var ErrInsufficientBalance = errors.New("insufficient bank balance")
+
+// ErrUnknownOutcome is returned when it is not possible to prove whether a broadcast transaction landed or not.
+// The migration must not be retried before an operator checks the chain.
+var ErrUnknownOutcome = errors.New("transaction outcome unknown")
+
+// UnknownOutcomeError is returned when a transaction was broadcast but its inclusion in a block could not be confirmed,
+// e.g., waiting for the transaction timed out. It matches ErrUnknownOutcome.
+type UnknownOutcomeError struct {
+	TxHash string
+	Err    error
+}
+
+func newUnknownOutcomeError(hash string, err error) *UnknownOutcomeError {
+	return &UnknownOutcomeError{TxHash: hash, Err: err}
+}
+
+func (e *UnknownOutcomeError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrUnknownOutcome, e.TxHash, e.Err)
+}
+
+func (e *UnknownOutcomeError) Unwrap() []error {
+	return []error{ErrUnknownOutcome, e.Err}
+}
+
+// TxError is a transaction rejected by the chain
+type TxError struct {
+	TxHash    string
+	Codespace string
+	Code      int
+	RawLog    string
+	CheckTx   bool // True if the transaction was rejected before entering the mempool
+}
+
+func newTxError(tx *CosmosTx, checkTx bool) *TxError {
+	return &TxError{TxHash: tx.TxHash, Codespace: tx.Codespace, Code: tx.Code, RawLog: tx.RawLog, CheckTx: checkTx}
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("failed to execute transaction %s: %s (%s/%d): %s", e.TxHash, e.Reason(), e.Codespace, e.Code, e.RawLog)
+}
+
+// Reason returns a human-readable reason of the error
+func (e *TxError) Reason() string {
+	if isSdkCodespace(e.Codespace) {
+		if name, ok := sdkErrorNames[e.Code]; ok {
+			return name
+		}
+	}
+	return "unknown error"
+}
+
+// Retryable returns true if the transaction provably did not land and can be broadcast again
+func (e *TxError) Retryable() bool {
+	return e.CheckTx && isRetryableTx(&CosmosTx{Codespace: e.Codespace, Code: e.Code})
+}
+
+// IsRetryable returns true if the migration can be safely retried later, i.e., no tokens were sent
+func IsRetryable(err error) bool {
+	// A previous attempt may have landed, whatever the last attempt was rejected with
+	if errors.Is(err, ErrUnknownOutcome) {
+		return false
+	}
+
+	if errors.Is(err, ErrFeeBudgetExceeded) || errors.Is(err, ErrMaxFeeExceeded) || errors.Is(err, ErrFeeLedger) ||
+		errors.Is(err, ErrChainUnavailable) || errors.Is(err, ErrInsufficientBalance) {
+		return true
+	}
+
+	var txErr *TxError
+	return errors.As(err, &txErr) && txErr.Retryable()
+}
+
+func isSdkCodespace(codespace string) bool {
+	return codespace == "" || codespace == SdkCodespace
+}
+
+// isRetryableTx returns true if the transaction was rejected with an error that is safe to retry
+func isRetryableTx(tx *CosmosTx) bool {
+	return isSdkCodespace(tx.Codespace) && retryableSdkErrors[tx.Code]
+}
+
+// isTxInMempool returns true if an identical transaction is already in the mempool
+func isTxInMempool(tx *CosmosTx) bool {
+	return isSdkCodespace(tx.Codespace) && tx.Code == ErrTxInMempoolCacheCode
+}
+
+// isSequenceMismatch returns true if the transaction was rejected because of an account sequence mismatch
+func isSequenceMismatch(tx *CosmosTx) bool {
+	return isSdkCodespace(tx.Codespace) && tx.Code == ErrWrongSequenceCode
+}
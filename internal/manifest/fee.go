@@ -0,0 +1,203 @@
+package manifest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+const (
+	// FeeLedgerFile is the append-only ledger of the fees spent by the migrator, one JSON entry per line
+	FeeLedgerFile = "fee-ledger.jsonl"
+	// FeeLedgerLockFile serializes the budget check and the fee record of the broadcasts between migrator processes.
+	// Each named destination has its own lock, see feeLedgerLockFile.
+	FeeLedgerLockFile = "fee-ledger.lock"
+)
+
+// ErrFeeBudgetExceeded is returned when broadcasting the transaction would exceed the daily fee budget.
+// The migration can be retried once the budget is replenished.
+var ErrFeeBudgetExceeded = errors.New("daily fee budget exceeded")
+
+// ErrMaxFeeExceeded is returned when the estimated fee exceeds the per-transaction cap.
+// The migration can be retried, e.g., once the cap is raised or the gas price drops.
+var ErrMaxFeeExceeded = errors.New("maximum fee exceeded")
+
+// ErrFeeLedger is returned when the fee ledger cannot be read or locked.
+// No transaction was broadcast and the migration can be retried.
+var ErrFeeLedger = errors.New("fee ledger unavailable")
+
+var gasEstimateRegexp = regexp.MustCompile(`gas estimate: (\d+)`)
+
+// FeeEstimate is the gas limit and fee used to broadcast a transaction
+type FeeEstimate struct {
+	GasLimit uint64
+	Fee      uint64
+	Denom    string
+}
+
+func (f FeeEstimate) String() string {
+	return fmt.Sprintf("%d%s", f.Fee, f.Denom)
+}
+
+// FeeLedgerEntry is a fee spent by the migrator
+type FeeLedgerEntry struct {
+	Date        time.Time `json:"date"`
+	UUID        uuid.UUID `json:"uuid"`
+	TxHash      string    `json:"txhash"`
+	Fee         uint64    `json:"fee"`
+	Denom       string    `json:"denom"`
+	Destination string    `json:"destination,omitempty"`
+}
+
+// estimateFee simulates the transaction and computes the expected fee.
+// The token fixed gas limit is used if the simulation fails.
+func estimateFee(ctx context.Context, migrateConfig config.MigrateConfig, tokenInfo utils.TokenInfo, simulate []string) (*FeeEstimate, error) {
+	ctx, cancel := withTimeout(ctx, migrateConfig.WaitTxTimeout)
+	defer cancel()
+	gasLimit, err := simulateGas(ctx, keyringInput(migrateConfig), migrateConfig.Binary, simulate)
+	if err != nil {
+		if tokenInfo.GasLimit == 0 {
+			return nil, errors.WithMessage(err, "failed to simulate transaction and no fixed gas limit configured")
+		}
+		slog.Warn("Simulation failed, using fixed gas limit", "error", err, "gasLimit", tokenInfo.GasLimit)
+		gasLimit = tokenInfo.GasLimit
+	}
+
+	fee := uint64(math.Ceil(float64(gasLimit) * migrateConfig.GasPrice))
+	return &FeeEstimate{GasLimit: gasLimit, Fee: fee, Denom: migrateConfig.GasDenom}, nil
+}
+
+// simulateGas simulates the transaction and returns the adjusted gas estimate
+func simulateGas(ctx context.Context, input string, binary string, simulate []string) (uint64, error) {
+	o, err := executeCommandCombined(ctx, input, binary, simulate...)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to simulate transaction")
+	}
+
+	match := gasEstimateRegexp.FindSubmatch(o)
+	if match == nil {
+		return 0, fmt.Errorf("no gas estimate found in simulation output: %s", string(o))
+	}
+
+	gas, err := strconv.ParseUint(string(match[1]), 10, 64)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to parse gas estimate")
+	}
+
+	return gas, nil
+}
+
+// checkFee refuses fees above the per-transaction cap or the daily fee budget.
+// Each destination has its own daily fee budget. The fee ledger lock of the destination must be held.
+func checkFee(migrateConfig config.MigrateConfig, estimate *FeeEstimate) error {
+	if migrateConfig.MaxFee > 0 && estimate.Fee > uint64(migrateConfig.MaxFee) {
+		return errors.WithMessagef(ErrMaxFeeExceeded, "estimated fee %s exceeds the maximum fee %d%s", estimate, migrateConfig.MaxFee, estimate.Denom)
+	}
+
+	if migrateConfig.DailyFeeBudget > 0 {
+		spent, err := dailyFees(time.Now().UTC(), migrateConfig.Destination, estimate.Denom)
+		if err != nil {
+			return err
+		}
+
+		if spent+estimate.Fee > uint64(migrateConfig.DailyFeeBudget) {
+			return errors.WithMessagef(ErrFeeBudgetExceeded, "spent %d%s, estimated fee %s, budget %d%s", spent, estimate.Denom, estimate, migrateConfig.DailyFeeBudget, estimate.Denom)
+		}
+	}
+
+	return nil
+}
+
+// dailyFees returns the total fees spent by the destination during the given UTC day.
+// Malformed entries, e.g., a line truncated by a crash, are skipped.
+func dailyFees(day time.Time, destination string, denom string) (uint64, error) {
+	file, err := os.Open(FeeLedgerFile)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, utils.Mark(fmt.Errorf("failed to open fee ledger: %w", err), ErrFeeLedger)
+	}
+	defer file.Close()
+
+	var total uint64
+	year, month, d := day.Date()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry FeeLedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("Skipping malformed fee ledger entry", "line", line, "warning", err)
+			continue
+		}
+
+		eYear, eMonth, eDay := entry.Date.UTC().Date()
+		if eYear == year && eMonth == month && eDay == d && entry.Denom == denom && entry.Destination == destination {
+			total += entry.Fee
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, utils.Mark(fmt.Errorf("failed to read fee ledger: %w", err), ErrFeeLedger)
+	}
+
+	return total, nil
+}
+
+// recordFee appends the fee to the fee ledger. The fee ledger lock of the destination must be held.
+func recordFee(entry FeeLedgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fee ledger entry: %w", err)
+	}
+
+	file, err := os.OpenFile(FeeLedgerFile, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open fee ledger: %w", err)
+	}
+	defer file.Close()
+
+	// Terminate the last entry if it was truncated by a crash, so the new entry is not corrupted
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write fee ledger: %w", err)
+	}
+
+	return nil
+}
+
+// feeLedgerLockFile returns the fee ledger lock file of the destination of the config
+func feeLedgerLockFile(c config.MigrateConfig) string {
+	if c.Destination == "" {
+		return FeeLedgerLockFile
+	}
+	return fmt.Sprintf("fee-ledger-%s.lock", c.Destination)
+}
+
+// lockFeeLedger acquires the exclusive fee ledger lock of the destination and returns the function releasing it.
+// The lock is held from the budget check to the fee record, so concurrent migrations cannot both pass the check.
+func lockFeeLedger(c config.MigrateConfig) (func(), error) {
+	unlock, err := lockFile(feeLedgerLockFile(c))
+	if err != nil {
+		return nil, utils.Mark(errors.WithMessage(err, "failed to lock fee ledger"), ErrFeeLedger)
+	}
+	return unlock, nil
+}
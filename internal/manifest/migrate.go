@@ -1,33 +1,57 @@
 package manifest
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"math/big"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/liftedinit/mfx-migrator/internal/config"
 
 	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
 )
 
 const (
 	OutputFormat = "json"
+
+	// commandWaitDelay bounds the time spent waiting for the output of a killed command
+	commandWaitDelay = 5 * time.Second
 )
 
 var (
-	gas = []string{"--gas", "auto"}
-	yes = []string{"--yes"}
+	gasAuto = []string{"--gas", "auto"}
+	dryRun  = []string{"--dry-run"}
+	yes     = []string{"--yes"}
 )
 
 type CosmosTx struct {
-	TxHash string `json:"txhash"`
-	Code   int    `json:"code"`
-	RawLog string `json:"raw_log"`
+	TxHash    string `json:"txhash"`
+	Codespace string `json:"codespace"`
+	Code      int    `json:"code"`
+	RawLog    string `json:"raw_log"`
+	GasWanted string `json:"gas_wanted"`
+	GasUsed   string `json:"gas_used"`
+}
+
+// MigrateResult is the result of a successful migration
+type MigrateResult struct {
+	TxHash    string
+	BlockTime *time.Time // Time of the block including the transaction, nil if it could not be fetched
+	GasUsed   uint64
+	Fee       FeeEstimate
 }
 
 type EventQueryTxFor struct {
@@ -41,12 +65,25 @@ type BlockHeader struct {
 }
 
 // executeCommand executes the provided command and returns the output.
-func executeCommand(name string, arg ...string) ([]byte, error) {
-	cmd := exec.Command(name, arg...)
+// The command and its children are killed when the context is done.
+func executeCommand(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	return executeCommandWithInput(ctx, "", name, arg...)
+}
+
+// executeCommandWithInput executes the provided command, writing the input to its standard input, and returns the
+// output.
+func executeCommandWithInput(ctx context.Context, input string, name string, arg ...string) (_ []byte, err error) {
+	ctx, span := startCommandSpan(ctx, "executeCommand", name, arg)
+	defer func() { tracing.End(span, err) }()
+
+	cmd := newCommand(ctx, input, name, arg...)
 	slog.Debug("Executing command", "command", cmd.String())
 	output, err := cmd.Output()
 	slog.Debug("Command output", "output", string(output))
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
 		var exitErr *exec.ExitError
 		var resErr error
 		if errors.As(err, &exitErr) {
@@ -59,6 +96,70 @@ func executeCommand(name string, arg ...string) ([]byte, error) {
 	return output, nil
 }
 
+// executeCommandCombined executes the provided command, writing the input to its standard input, and returns the
+// combined standard output and standard error.
+// The command and its children are killed when the context is done.
+func executeCommandCombined(ctx context.Context, input string, name string, arg ...string) (_ []byte, err error) {
+	ctx, span := startCommandSpan(ctx, "executeCommandCombined", name, arg)
+	defer func() { tracing.End(span, err) }()
+
+	cmd := newCommand(ctx, input, name, arg...)
+	slog.Debug("Executing command", "command", cmd.String())
+	output, err := cmd.CombinedOutput()
+	slog.Debug("Command output", "output", string(output))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
+		return nil, errors.WithMessage(err, fmt.Sprintf("failed to execute command: %s", string(output)))
+	}
+	return output, nil
+}
+
+// keyringInput returns the standard input unlocking the keyring, i.e., the keyring passphrase, if any
+func keyringInput(c config.MigrateConfig) string {
+	if c.KeyringPassphrase == "" {
+		return ""
+	}
+	return c.KeyringPassphrase + "\n"
+}
+
+// startCommandSpan starts the span of a chain command
+func startCommandSpan(ctx context.Context, spanName string, name string, arg []string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, spanName,
+		attribute.String("process.executable.name", name),
+		attribute.StringSlice("process.command_args", arg),
+	)
+}
+
+// newCommand creates a command running in its own process group, reading the input, if any, from its standard input.
+// The whole process group is killed when the context is done, so a hung command cannot outlive its deadline.
+func newCommand(ctx context.Context, input string, name string, arg ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, arg...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// withTimeout returns a child context cancelled after the given number of seconds
+func withTimeout(ctx context.Context, seconds uint) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+}
+
+// contextError describes why a command was killed
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.WithMessage(err, "command timed out")
+	}
+	return errors.WithMessage(err, "command cancelled")
+}
+
 // unmarshalOutput unmarshals the provided JSON output into the provided destination.
 func unmarshalOutput(output []byte, dest interface{}) error {
 	if err := json.Unmarshal(output, dest); err != nil {
@@ -68,42 +169,113 @@ func unmarshalOutput(output []byte, dest interface{}) error {
 }
 
 // Migrate migrates the given amount of tokens to the specified address.
-func Migrate(item *store.WorkItem, migrateConfig config.MigrateConfig, denom string, amount *big.Int) (*CosmosTx, *time.Time, error) {
+//
+// The transaction is simulated first to estimate the fee. The transaction is not broadcast if the fee exceeds the
+// per-transaction cap or the daily fee budget.
+//
+// Every chain command runs under a deadline. Waiting for the transaction and the block are bounded by the wait
+// timeouts, the other commands by the transaction timeout. Once the transaction is broadcast, a failure to confirm it
+// is returned as an UnknownOutcomeError. Once it is confirmed, the migration succeeded even if the block time could not
+// be fetched.
+func Migrate(ctx context.Context, item *store.WorkItem, migrateConfig config.MigrateConfig, tokenInfo utils.TokenInfo, amount *big.Int) (*MigrateResult, error) {
 	node := []string{"--node", migrateConfig.NodeAddress}
 	chainId := []string{"--chain-id", migrateConfig.ChainID}
 	keyringBackend := []string{"--keyring-backend", migrateConfig.KeyringBackend}
 	home := []string{"--home", migrateConfig.ChainHome}
 	from := []string{"--from", migrateConfig.BankAddress}
 	gasAdjustment := []string{"--gas-adjustment", fmt.Sprintf("%f", migrateConfig.GasAdjustment)}
-	gasPrice := []string{"--gas-prices", fmt.Sprintf("%f%s", migrateConfig.GasPrice, migrateConfig.GasDenom)}
 	feeGranter := []string{"--fee-granter", migrateConfig.FeeGranter}
 	output := []string{"--output", OutputFormat}
 
 	// Send the tokens to the manifest address
-	txSend := []string{"tx", "bank", "send", migrateConfig.BankAddress, item.ManifestAddress, amount.String() + denom}
+	txSend := []string{"tx", "bank", "send", migrateConfig.BankAddress, item.ManifestAddress, amount.String() + tokenInfo.Denom}
 	txSend = append(txSend, node...)
 	txSend = append(txSend, chainId...)
 	txSend = append(txSend, keyringBackend...)
 	txSend = append(txSend, home...)
 	txSend = append(txSend, from...)
-	txSend = append(txSend, gas...)
-	txSend = append(txSend, gasAdjustment...)
-	txSend = append(txSend, gasPrice...)
 	txSend = append(txSend, feeGranter...)
+
+	// Simulate the transaction and compute the expected fee
+	simulate := append(append(append([]string{}, txSend...), gasAuto...), gasAdjustment...)
+	simulate = append(simulate, dryRun...)
+	fee, err := estimateFee(ctx, migrateConfig, tokenInfo, simulate)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Estimated fee", "gasLimit", fee.GasLimit, "fee", fee.String())
+
+	// Hold the fee ledger lock from the budget check to the fee record
+	unlockLedger, err := lockFeeLedger(migrateConfig)
+	if err != nil {
+		return nil, err
+	}
+	releaseLedger := sync.OnceFunc(unlockLedger)
+	defer releaseLedger()
+
+	if err = checkFee(migrateConfig, fee); err != nil {
+		return nil, err
+	}
+
+	txSend = append(txSend, "--gas", strconv.FormatUint(fee.GasLimit, 10))
+	txSend = append(txSend, "--fees", fee.String())
 	txSend = append(txSend, output...)
 	txSend = append(txSend, yes...)
-	o, err := executeCommand(migrateConfig.Binary, txSend...)
+
+	// Broadcast the transaction using the next bank account sequence number
+	var signed uint64 // Sequence the transaction was last signed with
+	tx, err := NewSequenceManager(migrateConfig).Broadcast(ctx, func(ctx context.Context, accountNumber, sequence uint64) (*CosmosTx, error) {
+		signed = sequence
+		args := append([]string{}, txSend...)
+		args = append(args, "--account-number", strconv.FormatUint(accountNumber, 10))
+		args = append(args, "--sequence", strconv.FormatUint(sequence, 10))
+		ctx, cancel := withTimeout(ctx, migrateConfig.WaitTxTimeout)
+		defer cancel()
+		o, err := executeCommandWithInput(ctx, keyringInput(migrateConfig), migrateConfig.Binary, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		// Unmarshal the transaction response
+		var tx CosmosTx
+		if err = unmarshalOutput(o, &tx); err != nil {
+			return nil, err
+		}
+		return &tx, nil
+	})
 	if err != nil {
-		return nil, nil, err
+		if tx != nil {
+			// The transaction was included in a block but failed, the fee was charged
+			if err := recordFee(FeeLedgerEntry{Date: time.Now().UTC(), UUID: item.UUID, TxHash: tx.TxHash, Fee: fee.Fee, Denom: fee.Denom, Destination: migrateConfig.Destination}); err != nil {
+				slog.Error("Unable to record fee", "error", err, "hash", tx.TxHash, "fee", fee.String())
+			}
+		}
+		if errors.Is(err, ErrUnknownOutcome) {
+			// Checkpoint the signed sequence so an operator can later prove the transaction can no longer land
+			var outcomeErr *UnknownOutcomeError
+			var hash string
+			if errors.As(err, &outcomeErr) {
+				hash = outcomeErr.TxHash
+			}
+			if err := store.SetBroadcast(item, migrateConfig.Destination, hash, signed); err != nil {
+				slog.Error("Unable to save the transaction hash", "error", err, "hash", hash)
+			}
+		}
+		return nil, err
+	}
+	if tx.Code != 0 {
+		return nil, newTxError(tx, true)
 	}
 
-	// Unmarshal the transaction response
-	var tx CosmosTx
-	if err = unmarshalOutput(o, &tx); err != nil {
-		return nil, nil, err
+	// The transaction passed the mempool checks, the fee will be charged
+	if err = recordFee(FeeLedgerEntry{Date: time.Now().UTC(), UUID: item.UUID, TxHash: tx.TxHash, Fee: fee.Fee, Denom: fee.Denom, Destination: migrateConfig.Destination}); err != nil {
+		slog.Error("Unable to record fee", "error", err, "hash", tx.TxHash, "fee", fee.String())
 	}
-	if tx.Code != 0 {
-		return nil, nil, errors.Errorf("failed to execute transaction: %s", tx.RawLog)
+	releaseLedger()
+
+	// Checkpoint the transaction hash before waiting for it, so it is never lost, e.g., if the migrator is killed
+	if err = store.SetBroadcast(item, migrateConfig.Destination, tx.TxHash, signed); err != nil {
+		slog.Error("Unable to save the transaction hash", "error", err, "hash", tx.TxHash)
 	}
 
 	// Wait for the transaction to be included in a block
@@ -111,39 +283,73 @@ func Migrate(item *store.WorkItem, migrateConfig config.MigrateConfig, denom str
 	qWaitTx = append(qWaitTx, node...)
 	qWaitTx = append(qWaitTx, home...)
 	qWaitTx = append(qWaitTx, output...)
-	o, err = executeCommand(migrateConfig.Binary, qWaitTx...)
+	waitCtx, cancel := withTimeout(ctx, migrateConfig.WaitTxTimeout)
+	defer cancel()
+	o, err := executeCommand(waitCtx, migrateConfig.Binary, qWaitTx...)
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to wait for transaction")
+		return nil, newUnknownOutcomeError(tx.TxHash, errors.WithMessage(err, "failed to wait for transaction"))
 	}
 
 	var txWait CosmosTx
 	if err = unmarshalOutput(o, &txWait); err != nil {
-		return nil, nil, err
+		return nil, newUnknownOutcomeError(tx.TxHash, err)
 	}
 	if txWait.Code != 0 {
-		return nil, nil, errors.Errorf("failed to execute transaction: %s", txWait.RawLog)
+		return nil, newTxError(&txWait, false)
 	}
 
 	var res EventQueryTxFor
 	if err = unmarshalOutput(o, &res); err != nil {
-		return nil, nil, err
+		return nil, newUnknownOutcomeError(tx.TxHash, err)
+	}
+
+	gasUsed, err := strconv.ParseUint(txWait.GasUsed, 10, 64)
+	if err != nil {
+		slog.Warn("Unable to parse gas used", "error", err, "gasUsed", txWait.GasUsed)
+	}
+
+	// The transaction is confirmed, only the block time lookup is retried
+	blockTime, err := fetchBlockTime(ctx, migrateConfig, res.Height)
+	if err != nil {
+		slog.Error("Unable to fetch the block time, completing without it", "error", err, "hash", tx.TxHash, "height", res.Height)
 	}
 
-	// Fetch the block header for the transaction to get the block time
-	qBlock := []string{"q", "block", "--type", "height", res.Height}
-	qBlock = append(qBlock, node...)
-	qBlock = append(qBlock, home...)
-	qBlock = append(qBlock, output...)
-	o, err = executeCommand(migrateConfig.Binary, qBlock...)
+	return &MigrateResult{
+		TxHash:    tx.TxHash,
+		BlockTime: blockTime,
+		GasUsed:   gasUsed,
+		Fee:       *fee,
+	}, nil
+}
+
+// fetchBlockTime fetches the time of the block at the given height, retrying with backoff
+func fetchBlockTime(ctx context.Context, c config.MigrateConfig, height string) (*time.Time, error) {
+	policy := newRetryPolicy(c)
+	for attempt := uint(0); ; attempt++ {
+		blockTime, err := queryBlockTime(ctx, c, height)
+		if err == nil {
+			return blockTime, nil
+		}
+		if !policy.backoff(ctx, attempt, err.Error()) {
+			return nil, err
+		}
+	}
+}
+
+// queryBlockTime queries the time of the block at the given height
+func queryBlockTime(ctx context.Context, c config.MigrateConfig, height string) (*time.Time, error) {
+	ctx, cancel := withTimeout(ctx, c.WaitBlockTimeout)
+	defer cancel()
+	o, err := executeCommand(ctx, c.Binary, "q", "block", "--type", "height", height, "--node", c.NodeAddress, "--home", c.ChainHome, "--output", OutputFormat)
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to fetch block")
+		return nil, errors.WithMessage(err, "failed to fetch block")
 	}
 
 	var block BlockHeader
 	if err = unmarshalOutput(o, &block); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	blockTime := block.Header.Time.UTC().Truncate(time.Millisecond)
-	return &tx, &blockTime, nil
+	return &blockTime, nil
 }
@@ -0,0 +1,431 @@
+package manifest_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/manifest"
+	"github.com/liftedinit/mfx-migrator/internal/store"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func newMigrateConfig(binary string) config.MigrateConfig {
+	return config.MigrateConfig{
+		ChainID:          "manifest-1",
+		AddressPrefix:    "manifest",
+		NodeAddress:      "http://localhost:26657",
+		KeyringBackend:   "test",
+		BankAddress:      "bank",
+		ChainHome:        "/tmp",
+		WaitTxTimeout:    15,
+		WaitBlockTimeout: 30,
+		Binary:           binary,
+		GasPrice:         0.0011,
+		GasAdjustment:    1.4,
+		GasDenom:         "umfx",
+		FeeGranter:       "feegranter",
+		BroadcastRetries: 3,
+	}
+}
+
+// sendCalls returns the arguments of every broadcast `tx bank send`
+func sendCalls(t *testing.T, binary string) []string {
+	var calls []string
+	for _, call := range testutils.FakeChainCalls(t, binary) {
+		if strings.HasPrefix(call, "tx bank send") && !strings.Contains(call, "--dry-run") {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// sendCall returns the arguments of the first broadcast `tx bank send`
+func sendCall(t *testing.T, binary string) string {
+	if calls := sendCalls(t, binary); len(calls) > 0 {
+		return calls[0]
+	}
+	return ""
+}
+
+func TestMigrate_Fees(t *testing.T) {
+	item := &store.WorkItem{Status: store.MIGRATING, UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	amount := big.NewInt(1000)
+	tokenInfo := utils.TokenInfo{Denom: "umfx"}
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+
+		result, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+		require.Equal(t, testutils.FakeTxHash, result.TxHash)
+		require.Equal(t, uint64(81234), result.GasUsed)
+		require.Equal(t, "110umfx", result.Fee.String())
+		require.Contains(t, sendCall(t, binary), "--gas 100000 --fees 110umfx")
+
+		// The transaction hash is checkpointed as soon as the transaction is broadcast
+		state, err := store.LoadState(0, testutils.Uuid)
+		require.NoError(t, err)
+		require.Equal(t, testutils.FakeTxHash, state.Broadcast.TxHash)
+	})
+
+	t.Run("max_fee", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+		c := newMigrateConfig(binary)
+		c.MaxFee = 100
+
+		_, err := manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+		require.ErrorContains(t, err, "estimated fee 110umfx exceeds the maximum fee 100umfx")
+		require.ErrorIs(t, err, manifest.ErrMaxFeeExceeded)
+		require.True(t, manifest.IsRetryable(err))
+		require.Empty(t, sendCall(t, binary))
+	})
+
+	t.Run("daily_budget", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+		c := newMigrateConfig(binary)
+		c.DailyFeeBudget = 200
+
+		_, err := manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+		require.NoError(t, err)
+
+		_, err = manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrFeeBudgetExceeded)
+	})
+
+	t.Run("malformed_ledger", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+		c := newMigrateConfig(binary)
+		c.DailyFeeBudget = 200
+
+		// A line truncated by a crash is skipped
+		require.NoError(t, os.WriteFile(manifest.FeeLedgerFile, []byte(`{"date":"`+time.Now().UTC().Format(time.RFC3339)+`","fee":50,"denom":"umfx"}`+"\n"+`{"date":`), 0600))
+		_, err := manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+		require.NoError(t, err)
+
+		_, err = manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrFeeBudgetExceeded)
+	})
+
+	t.Run("fixed_gas_limit", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.GasEstimate = ""
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.ErrorContains(t, err, "no fixed gas limit configured")
+
+		result, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), utils.TokenInfo{Denom: "umfx", GasLimit: 50000}, amount)
+		require.NoError(t, err)
+		require.Equal(t, "55umfx", result.Fee.String())
+		require.Contains(t, sendCall(t, binary), "--gas 50000 --fees 55umfx")
+	})
+}
+
+func TestMigrate_Sequence(t *testing.T) {
+	item := &store.WorkItem{UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	amount := big.NewInt(1000)
+	tokenInfo := utils.TokenInfo{Denom: "umfx"}
+
+	t.Run("back_to_back", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, testutils.DefaultFakeChain)
+
+		for i := 0; i < 3; i++ {
+			_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+			require.NoError(t, err)
+		}
+
+		var sequences []string
+		for _, call := range sendCalls(t, binary) {
+			sequences = append(sequences, call[strings.Index(call, "--account-number"):])
+		}
+
+		accountQueries := 0
+		for _, call := range testutils.FakeChainCalls(t, binary) {
+			if strings.HasPrefix(call, "q auth account") {
+				accountQueries++
+			}
+		}
+		require.Equal(t, 1, accountQueries)
+		require.Equal(t, []string{
+			"--account-number 7 --sequence 5",
+			"--account-number 7 --sequence 6",
+			"--account-number 7 --sequence 7",
+		}, sequences)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{
+			testutils.FakeTxOutput(32, "account sequence mismatch, expected 9, got 5: incorrect account sequence"),
+			testutils.FakeTxOutput(0, ""),
+		}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+
+		calls := sendCalls(t, binary)
+		require.Len(t, calls, 2)
+		require.True(t, strings.HasSuffix(calls[0], "--sequence 5"))
+		require.True(t, strings.HasSuffix(calls[1], "--sequence 9"))
+	})
+}
+
+func TestMigrate_Retry(t *testing.T) {
+	item := &store.WorkItem{UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	amount := big.NewInt(1000)
+	tokenInfo := utils.TokenInfo{Denom: "umfx"}
+
+	t.Run("mempool_full", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{
+			testutils.FakeTxOutput(20, "mempool is full"),
+			testutils.FakeTxOutput(0, ""),
+		}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+
+		calls := sendCalls(t, binary)
+		require.Len(t, calls, 2)
+		require.Equal(t, calls[0], calls[1])
+	})
+
+	t.Run("mempool_full_exhausted", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{testutils.FakeTxOutput(20, "mempool is full")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.True(t, manifest.IsRetryable(err))
+		require.Len(t, sendCalls(t, binary), 4)
+	})
+
+	t.Run("insufficient_funds", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{testutils.FakeTxOutput(5, "spendable balance 0umfx is smaller than 1000umfx: insufficient funds")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		var txErr *manifest.TxError
+		require.ErrorAs(t, err, &txErr)
+		require.Equal(t, "insufficient funds", txErr.Reason())
+		require.False(t, manifest.IsRetryable(err))
+		require.Len(t, sendCalls(t, binary), 1)
+	})
+
+	t.Run("command_failure", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(19, "tx already exists in cache")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+
+		calls := sendCalls(t, binary)
+		require.Len(t, calls, 2)
+		require.Equal(t, calls[0], calls[1])
+	})
+
+	t.Run("command_failure_exhausted", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{""}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+		require.False(t, manifest.IsRetryable(err))
+	})
+
+	t.Run("command_failure_mempool_full_exhausted", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(20, "mempool is full")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		// The first attempt may still land, the candidate hash is kept
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+		require.False(t, manifest.IsRetryable(err))
+		var outcomeErr *manifest.UnknownOutcomeError
+		require.ErrorAs(t, err, &outcomeErr)
+		require.Equal(t, testutils.FakeTxHash, outcomeErr.TxHash)
+		require.Len(t, sendCalls(t, binary), 4)
+	})
+
+	t.Run("command_failure_rejected", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(5, "insufficient funds")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+		var outcomeErr *manifest.UnknownOutcomeError
+		require.ErrorAs(t, err, &outcomeErr)
+		require.Equal(t, testutils.FakeTxHash, outcomeErr.TxHash)
+	})
+
+	t.Run("command_failure_landed_failed", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(32, "account sequence mismatch, expected 6, got 5: incorrect account sequence")}
+		chain.TxOutputs = []string{testutils.FakeTxOutput(11, "out of gas")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		// The transaction failed in a block, not in the mempool checks
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		var txErr *manifest.TxError
+		require.ErrorAs(t, err, &txErr)
+		require.False(t, txErr.CheckTx)
+		require.NotErrorIs(t, err, manifest.ErrUnknownOutcome)
+		require.FileExists(t, manifest.FeeLedgerFile)
+	})
+
+	t.Run("command_failure_landed", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(32, "account sequence mismatch, expected 6, got 5: incorrect account sequence")}
+		chain.TxOutputs = chain.WaitOutputs
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+		require.Len(t, sendCalls(t, binary), 2)
+	})
+
+	t.Run("block_time_retried", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.BlockOutputs = append([]string{""}, chain.BlockOutputs...)
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		result, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+		require.NotNil(t, result.BlockTime)
+		require.Len(t, sendCalls(t, binary), 1)
+	})
+
+	t.Run("block_time_unavailable", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.BlockOutputs = nil
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		// The transaction is confirmed, the migration succeeds without the block time
+		result, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.NoError(t, err)
+		require.Equal(t, testutils.FakeTxHash, result.TxHash)
+		require.Nil(t, result.BlockTime)
+	})
+
+	t.Run("command_failure_not_found", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		chain := testutils.DefaultFakeChain
+		chain.SendOutputs = []string{"", testutils.FakeTxOutput(32, "account sequence mismatch, expected 6, got 5: incorrect account sequence")}
+		binary := testutils.NewFakeChainBinary(t, chain)
+
+		_, err := manifest.Migrate(context.Background(), item, newMigrateConfig(binary), tokenInfo, amount)
+		require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+		require.Len(t, sendCalls(t, binary), 2)
+	})
+}
+
+func TestMigrate_Timeout(t *testing.T) {
+	item := &store.WorkItem{UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	amount := big.NewInt(1000)
+	tokenInfo := utils.TokenInfo{Denom: "umfx"}
+
+	require.NoError(t, os.Chdir(t.TempDir()))
+	chain := testutils.DefaultFakeChain
+	chain.WaitDelay = "30"
+	binary := testutils.NewFakeChainBinary(t, chain)
+	c := newMigrateConfig(binary)
+	c.WaitTxTimeout = 1
+
+	start := time.Now()
+	_, err := manifest.Migrate(context.Background(), item, c, tokenInfo, amount)
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+	require.False(t, manifest.IsRetryable(err))
+
+	var outcomeErr *manifest.UnknownOutcomeError
+	require.ErrorAs(t, err, &outcomeErr)
+	require.Equal(t, testutils.FakeTxHash, outcomeErr.TxHash)
+	require.ErrorContains(t, err, "command timed out")
+}
+
+func TestMigrate_Cancel(t *testing.T) {
+	item := &store.WorkItem{UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	amount := big.NewInt(1000)
+	tokenInfo := utils.TokenInfo{Denom: "umfx"}
+
+	require.NoError(t, os.Chdir(t.TempDir()))
+	chain := testutils.DefaultFakeChain
+	chain.WaitDelay = "30"
+	binary := testutils.NewFakeChainBinary(t, chain)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := manifest.Migrate(ctx, item, newMigrateConfig(binary), tokenInfo, amount)
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.ErrorIs(t, err, manifest.ErrUnknownOutcome)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "command cancelled")
+}
+
+func TestMigrate_KeyringPassphrase(t *testing.T) {
+	item := &store.WorkItem{UUID: uuid.MustParse(testutils.Uuid), ManifestAddress: testutils.ManifestAddress}
+	chain := testutils.DefaultFakeChain
+	chain.Passphrase = "s3cr3t"
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, chain)
+		c := newMigrateConfig(binary)
+		c.KeyringBackend = "file"
+		c.KeyringPassphrase = "s3cr3t"
+
+		result, err := manifest.Migrate(context.Background(), item, c, utils.TokenInfo{Denom: "umfx"}, big.NewInt(1000))
+		require.NoError(t, err)
+		require.Equal(t, testutils.FakeTxHash, result.TxHash)
+		for _, call := range testutils.FakeChainCalls(t, binary) {
+			require.NotContains(t, call, "s3cr3t") // The passphrase is not a command argument
+		}
+	})
+
+	t.Run("wrong_passphrase", func(t *testing.T) {
+		require.NoError(t, os.Chdir(t.TempDir()))
+		binary := testutils.NewFakeChainBinary(t, chain)
+		c := newMigrateConfig(binary)
+		c.KeyringBackend = "file"
+
+		_, err := manifest.Migrate(context.Background(), item, c, utils.TokenInfo{Denom: "umfx", GasLimit: 100000}, big.NewInt(1000))
+		require.ErrorContains(t, err, "integrity check failed")
+		require.Empty(t, sendCalls(t, binary))
+	})
+}
@@ -0,0 +1,41 @@
+package manifest
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+const maxRetryWait = 2 * time.Minute
+
+// retryPolicy is an exponential backoff retry policy
+type retryPolicy struct {
+	maxRetries uint
+	wait       time.Duration
+}
+
+func newRetryPolicy(c config.MigrateConfig) retryPolicy {
+	return retryPolicy{maxRetries: c.BroadcastRetries, wait: time.Duration(c.BroadcastRetryWait) * time.Second}
+}
+
+// backoff waits before the next attempt. It returns false if no retry is left or the context is done.
+func (p retryPolicy) backoff(ctx context.Context, attempt uint, reason string) bool {
+	if attempt >= p.maxRetries {
+		return false
+	}
+
+	wait := p.wait << attempt
+	if wait > maxRetryWait || wait < 0 {
+		wait = maxRetryWait
+	}
+
+	slog.Warn("Retrying chain command", "attempt", attempt+1, "maxRetries", p.maxRetries, "wait", wait, "reason", reason)
+	select {
+	case <-time.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,329 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+const (
+	// SequenceFile holds the bank account number and next sequence number, shared by every migrator process.
+	// Each named destination has its own file, see sequenceFiles.
+	SequenceFile = "bank-sequence.state"
+	// SequenceLockFile serializes the access to SequenceFile and the broadcasts between migrator processes
+	SequenceLockFile = "bank-sequence.lock"
+
+	maxSequenceRetries = 3
+)
+
+var expectedSequenceRegexp = regexp.MustCompile(`account sequence mismatch, expected (\d+)`)
+
+// accountState is the bank account state owned by the sequence manager
+type accountState struct {
+	Address       string `json:"address"`
+	AccountNumber uint64 `json:"account_number"`
+	Sequence      uint64 `json:"sequence"`
+}
+
+// accountResponse is the output of `q auth account`.
+// Recent Cosmos SDK versions wrap the account in `account.value`.
+type accountResponse struct {
+	Account struct {
+		Value struct {
+			AccountNumber string `json:"account_number"`
+			Sequence      string `json:"sequence"`
+		} `json:"value"`
+	} `json:"account"`
+	AccountNumber string `json:"account_number"`
+	Sequence      string `json:"sequence"`
+}
+
+// BroadcastFunc broadcasts a transaction signed with the given account and sequence numbers
+type BroadcastFunc func(ctx context.Context, accountNumber, sequence uint64) (*CosmosTx, error)
+
+// SequenceManager owns the bank account sequence number.
+//
+// Sequence numbers are handed out locally so several transactions can be broadcast back-to-back without waiting for
+// each block. The state is shared between migrator processes using a lock file. The sequence is resynced from the
+// chain on mismatch and the broadcast is retried, which is safe because a transaction with a wrong sequence is
+// rejected before entering the mempool, unless a previous attempt of the same transaction may have landed.
+type SequenceManager struct {
+	config    config.MigrateConfig
+	stateFile string
+	lockFile  string
+}
+
+func NewSequenceManager(c config.MigrateConfig) *SequenceManager {
+	stateFile, lockFile := sequenceFiles(c)
+	return &SequenceManager{config: c, stateFile: stateFile, lockFile: lockFile}
+}
+
+// sequenceFiles returns the sequence state and lock files of the destination of the config.
+// The bank accounts of different destinations have independent sequences.
+func sequenceFiles(c config.MigrateConfig) (stateFile, lockFile string) {
+	if c.Destination == "" {
+		return SequenceFile, SequenceLockFile
+	}
+	return fmt.Sprintf("bank-sequence-%s.state", c.Destination), fmt.Sprintf("bank-sequence-%s.lock", c.Destination)
+}
+
+// Broadcast broadcasts the transaction using the next sequence number of the bank account.
+//
+// The broadcast is retried with backoff when the command fails or the transaction is rejected with a retryable error.
+// Retrying is safe because the exact same transaction is broadcast again, i.e., same sequence and same hash.
+// The sequence is only changed when the transaction provably did not land.
+// Once an attempt may have reached the mempool, every exit but a success returns an ErrUnknownOutcome error carrying
+// the candidate transaction hash. A transaction found included in a block but failed is returned with its TxError.
+func (m *SequenceManager) Broadcast(ctx context.Context, broadcast BroadcastFunc) (*CosmosTx, error) {
+	unlock, err := lockSequence(m.lockFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := newRetryPolicy(m.config)
+	var attempt, resyncs uint
+	ambiguous := false   // True if a previous attempt may have reached the mempool
+	var candidate string // Hash of the transaction, known once the node responded
+	for {
+		slog.Debug("Broadcasting transaction", "accountNumber", state.AccountNumber, "sequence", state.Sequence)
+		tx, err := broadcast(ctx, state.AccountNumber, state.Sequence)
+		if tx != nil && tx.TxHash != "" {
+			candidate = tx.TxHash
+		}
+		switch {
+		case err != nil:
+			// The command failed, e.g., the node is unreachable. The transaction may or may not have been broadcast.
+			ambiguous = true
+			if !policy.backoff(ctx, attempt, err.Error()) {
+				return nil, unknownOutcome(candidate, err)
+			}
+			attempt++
+		case tx.Code == 0 || isTxInMempool(tx):
+			// The transaction entered the mempool, the sequence is consumed
+			m.consume(state, state.Sequence+1)
+			return &CosmosTx{TxHash: tx.TxHash}, nil
+		case isSequenceMismatch(tx):
+			expected, ok := parseExpectedSequence(tx.RawLog)
+			if ambiguous {
+				// A previous attempt may have been included in a block, look it up.
+				// Not finding it is not a proof it did not land, e.g., the node indexer may lag behind.
+				landed, err := QueryTx(ctx, m.config, tx.TxHash)
+				if err != nil {
+					return nil, unknownOutcome(candidate, err)
+				}
+				if landed == nil {
+					return nil, unknownOutcome(candidate, fmt.Errorf("account sequence %d consumed by an unknown transaction", state.Sequence))
+				}
+				if !ok {
+					expected = state.Sequence + 1
+				}
+				m.consume(state, expected)
+				if landed.Code != 0 {
+					// The transaction was included in a block but failed, the fee was charged
+					return &landed.CosmosTx, newTxError(&landed.CosmosTx, false)
+				}
+				return &landed.CosmosTx, nil
+			}
+
+			if resyncs >= maxSequenceRetries {
+				return tx, nil
+			}
+			resyncs++
+
+			slog.Warn("Account sequence mismatch, resyncing", "sequence", state.Sequence, "rawLog", tx.RawLog)
+			if ok {
+				state.Sequence = expected
+			} else if state, err = m.sync(ctx); err != nil {
+				return nil, err
+			}
+			if err := saveAccountState(m.stateFile, state); err != nil {
+				slog.Error("Unable to save bank account sequence", "error", err)
+			}
+		case isRetryableTx(tx):
+			if !policy.backoff(ctx, attempt, tx.RawLog) {
+				if ambiguous {
+					return nil, unknownOutcome(candidate, newTxError(tx, true))
+				}
+				return tx, nil
+			}
+			attempt++
+		case ambiguous:
+			// A previous attempt may still land even though this one was rejected
+			return nil, unknownOutcome(candidate, newTxError(tx, true))
+		default:
+			return tx, nil
+		}
+	}
+}
+
+// QueryAccountSequence queries the next sequence number of the bank account from the chain
+func QueryAccountSequence(ctx context.Context, c config.MigrateConfig) (uint64, error) {
+	state, err := NewSequenceManager(c).sync(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return state.Sequence, nil
+}
+
+// unknownOutcome returns the error of an ambiguous broadcast, carrying the candidate transaction hash if known
+func unknownOutcome(hash string, err error) error {
+	if hash == "" {
+		return errors.WithMessage(ErrUnknownOutcome, err.Error())
+	}
+	return newUnknownOutcomeError(hash, err)
+}
+
+// consume records the sequence as consumed
+func (m *SequenceManager) consume(state *accountState, next uint64) {
+	state.Sequence = next
+	if err := saveAccountState(m.stateFile, state); err != nil {
+		slog.Error("Unable to save bank account sequence", "error", err)
+	}
+}
+
+// load loads the bank account state, syncing it from the chain when missing or stale
+func (m *SequenceManager) load(ctx context.Context) (*accountState, error) {
+	state, err := loadAccountState(m.stateFile)
+	if err != nil {
+		slog.Warn("Unable to load bank account sequence, resyncing", "warning", err)
+	}
+
+	if state != nil && state.Address == m.config.BankAddress {
+		return state, nil
+	}
+
+	state, err = m.sync(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveAccountState(m.stateFile, state); err != nil {
+		slog.Error("Unable to save bank account sequence", "error", err)
+	}
+
+	return state, nil
+}
+
+// sync queries the bank account number and sequence from the chain
+func (m *SequenceManager) sync(ctx context.Context) (*accountState, error) {
+	c := m.config
+	ctx, cancel := withTimeout(ctx, c.WaitTxTimeout)
+	defer cancel()
+	address, err := resolveBankAddress(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := executeCommand(ctx, c.Binary, "q", "auth", "account", address, "--node", c.NodeAddress, "--home", c.ChainHome, "--output", OutputFormat)
+	if err != nil {
+		return nil, utils.Mark(errors.WithMessage(err, "failed to query bank account"), ErrChainUnavailable)
+	}
+
+	var res accountResponse
+	if err = unmarshalOutput(o, &res); err != nil {
+		return nil, err
+	}
+
+	accountNumber, sequence := res.Account.Value.AccountNumber, res.Account.Value.Sequence
+	if accountNumber == "" {
+		accountNumber, sequence = res.AccountNumber, res.Sequence
+	}
+
+	state := &accountState{Address: c.BankAddress}
+	if state.AccountNumber, err = strconv.ParseUint(accountNumber, 10, 64); err != nil {
+		return nil, errors.WithMessage(err, "failed to parse account number")
+	}
+	if sequence != "" {
+		if state.Sequence, err = strconv.ParseUint(sequence, 10, 64); err != nil {
+			return nil, errors.WithMessage(err, "failed to parse account sequence")
+		}
+	}
+
+	slog.Info("Bank account sequence synced", "address", address, "accountNumber", state.AccountNumber, "sequence", state.Sequence)
+	return state, nil
+}
+
+// parseExpectedSequence parses the expected sequence from an account sequence mismatch error
+func parseExpectedSequence(rawLog string) (uint64, bool) {
+	match := expectedSequenceRegexp.FindStringSubmatch(rawLog)
+	if match == nil {
+		return 0, false
+	}
+
+	sequence, err := strconv.ParseUint(match[1], 10, 64)
+	return sequence, err == nil
+}
+
+// lockSequence acquires the exclusive sequence lock and returns the function releasing it
+func lockSequence(path string) (func(), error) {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to lock sequence")
+	}
+	return unlock, nil
+}
+
+// lockFile acquires an exclusive lock on the file, shared between migrator processes, and returns the function
+// releasing it
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock lock file: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func loadAccountState(path string) (*accountState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sequence file: %w", err)
+	}
+
+	var state accountState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sequence file: %w", err)
+	}
+
+	return &state, nil
+}
+
+func saveAccountState(path string, state *accountState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sequence file: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sequence file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+// LandedTx is a transaction included in a block, i.e., the output of `q tx`
+type LandedTx struct {
+	CosmosTx
+	Height    string    `json:"height"`
+	Timestamp time.Time `json:"timestamp"`
+	Tx        struct {
+		Body struct {
+			Messages []struct {
+				ToAddress string `json:"to_address"`
+			} `json:"messages"`
+		} `json:"body"`
+	} `json:"tx"`
+}
+
+// Recipients returns the recipients of the bank send messages of the transaction
+func (tx *LandedTx) Recipients() []string {
+	var recipients []string
+	for _, msg := range tx.Tx.Body.Messages {
+		if msg.ToAddress != "" {
+			recipients = append(recipients, msg.ToAddress)
+		}
+	}
+	return recipients
+}
+
+// QueryTx looks up a transaction included in a block by hash. It returns nil if the transaction is not found.
+// Not finding a transaction is not a proof it will never land, e.g., the node indexer may lag behind.
+func QueryTx(ctx context.Context, c config.MigrateConfig, hash string) (*LandedTx, error) {
+	ctx, cancel := withTimeout(ctx, c.WaitTxTimeout)
+	defer cancel()
+	o, err := executeCommand(ctx, c.Binary, "q", "tx", hash, "--node", c.NodeAddress, "--home", c.ChainHome, "--output", OutputFormat)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, errors.WithMessage(err, "failed to query transaction")
+	}
+
+	var tx LandedTx
+	if err = unmarshalOutput(o, &tx); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
@@ -0,0 +1,51 @@
+package many
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+var (
+	// ErrUnavailable is returned when the MANY transaction cannot be retrieved because Talib or the MANY node is
+	// unreachable or temporarily unavailable. The request can be retried.
+	ErrUnavailable = errors.New("MANY transaction source unavailable")
+	// ErrTxNotFound is returned when the MANY transaction does not exist
+	ErrTxNotFound = errors.New("MANY transaction not found")
+	// ErrUnsupportedTx is returned when the MANY transaction is not a supported token transfer
+	ErrUnsupportedTx = errors.New("unsupported MANY transaction")
+	// ErrInvalidTx is returned when the MANY transaction does not describe a valid migration
+	ErrInvalidTx = errors.New("invalid MANY transaction")
+)
+
+// checkResponse classifies the error and response status code of a request to Talib or the MANY node
+func checkResponse(response *resty.Response, err error) error {
+	if err != nil {
+		return utils.Mark(err, ErrUnavailable)
+	}
+
+	if response == nil {
+		return utils.Mark(fmt.Errorf("no response returned"), ErrUnavailable)
+	}
+
+	statusCode := response.StatusCode()
+	switch {
+	case statusCode == http.StatusOK:
+		return nil
+	case utils.IsTemporaryStatus(statusCode):
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrUnavailable)
+	case statusCode == http.StatusNotFound:
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrTxNotFound)
+	default:
+		return fmt.Errorf("response status code: %d", statusCode)
+	}
+}
+
+// invalidTxf returns a formatted error matching ErrInvalidTx
+func invalidTxf(format string, a ...interface{}) error {
+	return utils.Mark(fmt.Errorf(format, a...), ErrInvalidTx)
+}
@@ -0,0 +1,100 @@
+package many
+
+import (
+	"encoding/base32"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// IdentityTag is the CBOR tag used by the MANY protocol to mark an identity (address)
+const IdentityTag = 10000
+
+var identityEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Identity is the binary representation of a MANY address
+type Identity []byte
+
+// String returns the textual representation of the identity, e.g., `maffbahksdwaqeenayy2gxke32hgb7aq4ao4wt745lsfs6wijp`
+//
+// The textual form is `m` followed by the base32 encoding of the identity bytes and the first two characters of the
+// base32 encoding of the CRC-16/ARC checksum of the identity bytes. The anonymous identity is a special case (`maa`).
+func (i Identity) String() string {
+	if len(i) == 0 || (len(i) == 1 && i[0] == 0) {
+		return "maa"
+	}
+
+	crc := crc16(i)
+	checksum := identityEncoding.EncodeToString([]byte{byte(crc >> 8), byte(crc)})
+	return "m" + strings.ToLower(identityEncoding.EncodeToString(i)+checksum[:2])
+}
+
+// ParseIdentity parses the textual representation of a MANY identity
+func ParseIdentity(s string) (Identity, error) {
+	if s == "maa" {
+		return Identity{0}, nil
+	}
+
+	if len(s) < 4 || s[0] != 'm' {
+		return nil, fmt.Errorf("invalid MANY identity: %s", s)
+	}
+
+	body := s[1 : len(s)-2]
+	bytes, err := identityEncoding.DecodeString(strings.ToUpper(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MANY identity %s: %w", s, err)
+	}
+
+	identity := Identity(bytes)
+	if identity.String() != s {
+		return nil, fmt.Errorf("invalid MANY identity checksum: %s", s)
+	}
+
+	return identity, nil
+}
+
+// MarshalCBOR encodes the identity as a tagged CBOR byte string
+func (i Identity) MarshalCBOR() ([]byte, error) {
+	bytes := []byte(i)
+	if len(bytes) == 0 {
+		bytes = []byte{0}
+	}
+	return cbor.Marshal(cbor.Tag{Number: IdentityTag, Content: bytes})
+}
+
+// UnmarshalCBOR decodes a tagged CBOR byte string into an identity
+func (i *Identity) UnmarshalCBOR(data []byte) error {
+	var tag cbor.RawTag
+	if err := cbor.Unmarshal(data, &tag); err != nil {
+		return fmt.Errorf("invalid MANY identity: %w", err)
+	}
+
+	if tag.Number != IdentityTag {
+		return fmt.Errorf("invalid MANY identity tag: %d", tag.Number)
+	}
+
+	var bytes []byte
+	if err := cbor.Unmarshal(tag.Content, &bytes); err != nil {
+		return fmt.Errorf("invalid MANY identity content: %w", err)
+	}
+
+	*i = bytes
+	return nil
+}
+
+// crc16 computes the CRC-16/ARC checksum of the given data
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,308 @@
+package many
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	CoseSign1Tag       = 18
+	RequestMessageTag  = 10001
+	ResponseMessageTag = 10002
+
+	BlockchainRequestMethod = "blockchain.request"
+	LedgerSendMethod        = "ledger.send"
+	MultisigSubmitMethod    = "account.multisigSubmitTransaction"
+
+	CborContentType = "application/cbor"
+)
+
+// coseSign1 is a COSE_Sign1 envelope (RFC 8152). Requests to the node are anonymous, i.e., unsigned.
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// requestMessage is a MANY protocol request message
+type requestMessage struct {
+	Version   uint64    `cbor:"0,keyasint,omitempty"`
+	From      Identity  `cbor:"1,keyasint,omitempty"`
+	To        Identity  `cbor:"2,keyasint,omitempty"`
+	Method    string    `cbor:"3,keyasint"`
+	Data      []byte    `cbor:"4,keyasint,omitempty"`
+	Timestamp time.Time `cbor:"5,keyasint,omitempty"`
+}
+
+// responseMessage is a MANY protocol response message
+//
+// `Data` is either a byte string holding the CBOR encoded result or a map holding a MANY error
+type responseMessage struct {
+	Version   uint64          `cbor:"0,keyasint,omitempty"`
+	From      Identity        `cbor:"1,keyasint,omitempty"`
+	To        Identity        `cbor:"2,keyasint,omitempty"`
+	Data      cbor.RawMessage `cbor:"4,keyasint,omitempty"`
+	Timestamp time.Time       `cbor:"5,keyasint,omitempty"`
+}
+
+// manyError is a MANY protocol error
+type manyError struct {
+	Code      int64             `cbor:"0,keyasint"`
+	Message   string            `cbor:"1,keyasint,omitempty"`
+	Arguments map[string]string `cbor:"2,keyasint,omitempty"`
+}
+
+// requestArgs are the arguments of the `blockchain.request` endpoint.
+// The transaction is queried by hash.
+type requestArgs struct {
+	Query map[int][]byte `cbor:"0,keyasint"`
+}
+
+// requestReturns is the result of the `blockchain.request` endpoint, i.e., the original COSE_Sign1 request envelope
+type requestReturns struct {
+	Request []byte `cbor:"0,keyasint"`
+}
+
+// sendArgs are the arguments of the `ledger.send` endpoint
+type sendArgs struct {
+	From   Identity      `cbor:"0,keyasint,omitempty"`
+	To     Identity      `cbor:"1,keyasint"`
+	Amount big.Int       `cbor:"2,keyasint"`
+	Symbol Identity      `cbor:"3,keyasint"`
+	Memo   []interface{} `cbor:"4,keyasint,omitempty"`
+}
+
+// multisigSubmitArgs are the arguments of the `account.multisigSubmitTransaction` endpoint.
+//
+// The transaction is a single entry map of the endpoint name to the endpoint arguments.
+type multisigSubmitArgs struct {
+	Account     Identity                   `cbor:"0,keyasint"`
+	Transaction map[string]cbor.RawMessage `cbor:"1,keyasint"`
+}
+
+var (
+	encMode, _ = cbor.EncOptions{Time: cbor.TimeUnix, TimeTag: cbor.EncTagRequired}.EncMode()
+	decMode, _ = cbor.DecOptions{}.DecMode()
+)
+
+// NodeClient queries a MANY node directly using the MANY protocol (CBOR/COSE over HTTP).
+// It is used to cross-verify the transaction data returned by Talib.
+type NodeClient struct {
+	r   *resty.Client
+	url string
+}
+
+// NewNodeClient creates a new MANY node client using the given resty client and node URL.
+// The base URL of the resty client is left untouched, the client can be shared.
+func NewNodeClient(r *resty.Client, url string) *NodeClient {
+	return &NodeClient{r: r, url: url}
+}
+
+// GetTxInfo retrieves the arguments of the MANY transaction with the given hash from the MANY node
+func (c *NodeClient) GetTxInfo(ctx context.Context, hash string) (*Arguments, error) {
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid MANY tx hash: %s", hash)
+	}
+
+	var returns requestReturns
+	if err := c.call(ctx, BlockchainRequestMethod, requestArgs{Query: map[int][]byte{0: hashBytes}}, &returns); err != nil {
+		return nil, errors.WithMessage(err, "error getting MANY tx from node")
+	}
+
+	request, err := decodeRequestEnvelope(returns.Request)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error decoding MANY tx request")
+	}
+
+	return requestToArguments(request)
+}
+
+// call sends an anonymous request to the MANY node and decodes the result into `result`
+func (c *NodeClient) call(ctx context.Context, method string, args interface{}, result interface{}) error {
+	data, err := encMode.Marshal(args)
+	if err != nil {
+		return errors.WithMessage(err, "error encoding request arguments")
+	}
+
+	body, err := encodeRequestEnvelope(requestMessage{
+		Version:   1,
+		Method:    method,
+		Data:      data,
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.r.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", CborContentType).
+		SetBody(body).
+		Post(c.url)
+	if err := checkResponse(resp, err); err != nil {
+		return errors.WithMessage(err, "error sending request to MANY node")
+	}
+
+	response, err := decodeResponseEnvelope(resp.Body())
+	if err != nil {
+		return err
+	}
+
+	var resultBytes []byte
+	if err := decMode.Unmarshal(response.Data, &resultBytes); err != nil {
+		var mErr manyError
+		if err := decMode.Unmarshal(response.Data, &mErr); err != nil {
+			return errors.WithMessage(err, "error decoding MANY response data")
+		}
+		return fmt.Errorf("MANY node error %d: %s", mErr.Code, mErr.Message)
+	}
+
+	if err := decMode.Unmarshal(resultBytes, result); err != nil {
+		return errors.WithMessagef(err, "error decoding %s result", method)
+	}
+
+	return nil
+}
+
+// encodeRequestEnvelope wraps the request message in an unsigned COSE_Sign1 envelope
+func encodeRequestEnvelope(msg requestMessage) ([]byte, error) {
+	payload, err := encMode.Marshal(cbor.Tag{Number: RequestMessageTag, Content: msg})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error encoding request message")
+	}
+
+	return encodeEnvelope(payload)
+}
+
+// encodeEnvelope wraps the payload in an unsigned COSE_Sign1 envelope
+func encodeEnvelope(payload []byte) ([]byte, error) {
+	protected, err := encMode.Marshal(map[int]interface{}{})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error encoding protected header")
+	}
+
+	envelope, err := encMode.Marshal(cbor.Tag{Number: CoseSign1Tag, Content: coseSign1{
+		Protected:   protected,
+		Unprotected: map[int]interface{}{},
+		Payload:     payload,
+		Signature:   []byte{},
+	}})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error encoding COSE envelope")
+	}
+
+	return envelope, nil
+}
+
+// decodeEnvelope extracts the tagged payload of a COSE_Sign1 envelope
+func decodeEnvelope(data []byte, payloadTag uint64) ([]byte, error) {
+	var envelope coseSign1
+	if err := decMode.Unmarshal(data, &envelope); err != nil {
+		return nil, errors.WithMessage(err, "error decoding COSE envelope")
+	}
+
+	var payload cbor.RawTag
+	if err := decMode.Unmarshal(envelope.Payload, &payload); err != nil {
+		return nil, errors.WithMessage(err, "error decoding COSE payload")
+	}
+
+	if payload.Number != payloadTag {
+		return nil, fmt.Errorf("invalid MANY message tag: %d", payload.Number)
+	}
+
+	return payload.Content, nil
+}
+
+// decodeRequestEnvelope decodes a COSE_Sign1 envelope holding a MANY request message
+func decodeRequestEnvelope(data []byte) (*requestMessage, error) {
+	content, err := decodeEnvelope(data, RequestMessageTag)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg requestMessage
+	if err := decMode.Unmarshal(content, &msg); err != nil {
+		return nil, errors.WithMessage(err, "error decoding request message")
+	}
+
+	return &msg, nil
+}
+
+// decodeResponseEnvelope decodes a COSE_Sign1 envelope holding a MANY response message
+func decodeResponseEnvelope(data []byte) (*responseMessage, error) {
+	content, err := decodeEnvelope(data, ResponseMessageTag)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg responseMessage
+	if err := decMode.Unmarshal(content, &msg); err != nil {
+		return nil, errors.WithMessage(err, "error decoding response message")
+	}
+
+	return &msg, nil
+}
+
+// requestToArguments converts a MANY request message into the transaction arguments
+func requestToArguments(msg *requestMessage) (*Arguments, error) {
+	switch msg.Method {
+	case LedgerSendMethod:
+		return sendArgsToArguments(msg.Data, msg.From)
+	case MultisigSubmitMethod:
+		var args multisigSubmitArgs
+		if err := decMode.Unmarshal(msg.Data, &args); err != nil {
+			return nil, errors.WithMessage(err, "error decoding multisigSubmitTransaction tx arguments")
+		}
+
+		data, ok := args.Transaction[LedgerSendMethod]
+		if !ok || len(args.Transaction) != 1 {
+			return nil, fmt.Errorf("unsupported MANY multisig transaction")
+		}
+		return sendArgsToArguments(data, args.Account)
+	default:
+		return nil, fmt.Errorf("unsupported MANY tx method: %s", msg.Method)
+	}
+}
+
+// sendArgsToArguments converts the `ledger.send` arguments into the transaction arguments.
+// The sender defaults to `from` when it is not part of the arguments.
+func sendArgsToArguments(data []byte, from Identity) (*Arguments, error) {
+	var args sendArgs
+	if err := decMode.Unmarshal(data, &args); err != nil {
+		return nil, errors.WithMessage(err, "error decoding ledger.send tx arguments")
+	}
+
+	if len(args.From) == 0 {
+		args.From = from
+	}
+
+	memo := make([]string, 0, len(args.Memo))
+	for _, m := range args.Memo {
+		switch v := m.(type) {
+		case string:
+			memo = append(memo, v)
+		case []byte:
+			memo = append(memo, hex.EncodeToString(v))
+		default:
+			return nil, fmt.Errorf("invalid MANY memo entry: %v", m)
+		}
+	}
+
+	return &Arguments{
+		From:   args.From.String(),
+		To:     args.To.String(),
+		Amount: args.Amount.String(),
+		Symbol: args.Symbol.String(),
+		Memo:   memo,
+	}, nil
+}
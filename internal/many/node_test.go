@@ -0,0 +1,63 @@
+package many_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/many"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestIdentity(t *testing.T) {
+	for _, addr := range []string{"maa", many.IllegalAddr, testutils.ManyFrom, testutils.ManySymbolAddress} {
+		t.Run(addr, func(t *testing.T) {
+			identity, err := many.ParseIdentity(addr)
+			require.NoError(t, err)
+			require.Equal(t, addr, identity.String())
+		})
+	}
+
+	_, err := many.ParseIdentity("maffbahksdwaqeenayy2gxke32hgb7aq4ao4wt745lsfs6wijq")
+	require.ErrorContains(t, err, "invalid MANY identity checksum")
+}
+
+func TestNodeClient_GetTxInfo(t *testing.T) {
+	args := many.Arguments{
+		From:   testutils.ManyFrom,
+		To:     many.IllegalAddr,
+		Amount: "123456789012345678901234567890",
+		Symbol: testutils.ManySymbolAddress,
+		Memo:   []string{testutils.Uuid, testutils.ManifestAddress},
+	}
+	server := testutils.NewManyNodeServer(t, testutils.ManyHash, args)
+
+	tests := []struct {
+		desc string
+		hash string
+		err  string
+	}{
+		{"success", testutils.ManyHash, ""},
+		{"not_found", "00" + testutils.ManyHash[2:], "transaction not found"},
+		{"invalid_hash", "zz", "invalid MANY tx hash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			// The node client shares the resty client without changing its base URL
+			r := resty.New().SetBaseURL("http://talib")
+			client := many.NewNodeClient(r, server.URL)
+			nodeArgs, err := client.GetTxInfo(context.Background(), tt.hash)
+			require.Equal(t, "http://talib", r.BaseURL)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, args.Equal(*nodeArgs), "expected %v, got %v", args, nodeArgs)
+		})
+	}
+}
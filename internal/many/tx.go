@@ -1,6 +1,7 @@
 package many
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -8,6 +9,8 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
 )
 
 type Arguments struct {
@@ -31,11 +34,11 @@ type TxInfo struct {
 	Arguments json.RawMessage `json:"argument"`
 }
 
-func GetTxInfo(r *resty.Client, hash string) (*Arguments, error) {
-	req := r.R().SetPathParam("thash", hash).SetResult(&TxInfo{})
+func GetTxInfo(ctx context.Context, r *resty.Client, hash string) (*Arguments, error) {
+	req := r.R().SetContext(ctx).SetPathParam("thash", hash).SetResult(&TxInfo{})
 	resp, err := req.Get("neighborhoods/{neighborhood}/transactions/{thash}")
-	if err != nil {
-		return nil, errors.WithMessage(err, "error unmarshalling MANY tx info")
+	if err := checkResponse(resp, err); err != nil {
+		return nil, errors.WithMessage(err, "error getting MANY tx info")
 	}
 
 	txInfo := resp.Result().(*TxInfo)
@@ -57,41 +60,41 @@ func GetTxInfo(r *resty.Client, hash string) (*Arguments, error) {
 		}
 		return &args.Transaction.Arguments, nil
 	default:
-		return nil, fmt.Errorf("unsupported MANY tx method: %s", txInfo.Method)
+		return nil, utils.Mark(fmt.Errorf("unsupported MANY tx method: %s", txInfo.Method), ErrUnsupportedTx)
 	}
 }
 
 func CheckTxInfo(txArgs *Arguments, itemUUID uuid.UUID, manifestAddr string) error {
 	// Check the MANY transaction `To` address
 	if txArgs.To != IllegalAddr {
-		return fmt.Errorf("invalid MANY tx `to` address: %s", txArgs.To)
+		return invalidTxf("invalid MANY tx `to` address: %s", txArgs.To)
 	}
 
 	// Check the MANY transaction `Memo`
 	if len(txArgs.Memo) != 2 {
-		return fmt.Errorf("invalid MANY Memo length: %d", len(txArgs.Memo))
+		return invalidTxf("invalid MANY Memo length: %d", len(txArgs.Memo))
 	}
 
 	// Check the MANY transaction UUID
 	txUUID, err := uuid.Parse(txArgs.Memo[0])
 	if err != nil {
-		return errors.WithMessagef(err, "invalid MANY tx UUID: %s", txArgs.Memo[0])
+		return invalidTxf("invalid MANY tx UUID: %s: %w", txArgs.Memo[0], err)
 	}
 
 	// Check the Manifest destination address
 	if txArgs.Memo[1] != manifestAddr {
-		return fmt.Errorf("invalid manifest destination address: %s", txArgs.Memo[1])
+		return invalidTxf("invalid manifest destination address: %s", txArgs.Memo[1])
 	}
 
 	// Check the MANY transaction UUID matches the work item UUID
 	if txUUID != itemUUID {
-		return fmt.Errorf("MANY tx UUID does not match work item UUID: %s, %s", txUUID, itemUUID)
+		return invalidTxf("MANY tx UUID does not match work item UUID: %s, %s", txUUID, itemUUID)
 	}
 
 	amount := new(big.Int)
 	bigAmount, ok := amount.SetString(txArgs.Amount, 10)
 	if !ok {
-		return fmt.Errorf("invalid MANY tx amount: %s", txArgs.Amount)
+		return invalidTxf("invalid MANY tx amount: %s", txArgs.Amount)
 	}
 
 	// The MANY chain supports 9 decimal places
@@ -99,8 +102,25 @@ func CheckTxInfo(txArgs *Arguments, itemUUID uuid.UUID, manifestAddr string) err
 	// We're doing a 1:10 conversion
 	// Check the amount is not dust that would be lost in the conversion
 	if bigAmount.Cmp(big.NewInt(100)) < 0 {
-		return fmt.Errorf("amount must be greater than 0.000000099: %s", txArgs.Amount)
+		return invalidTxf("amount must be greater than 0.000000099: %s", txArgs.Amount)
 	}
 
 	return nil
 }
+
+// Equal returns true if the Arguments are equal to the other Arguments
+func (a Arguments) Equal(other Arguments) bool {
+	if len(a.Memo) != len(other.Memo) {
+		return false
+	}
+	for i := range a.Memo {
+		if a.Memo[i] != other.Memo[i] {
+			return false
+		}
+	}
+
+	return a.From == other.From &&
+		a.To == other.To &&
+		a.Amount == other.Amount &&
+		a.Symbol == other.Symbol
+}
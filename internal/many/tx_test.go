@@ -0,0 +1,63 @@
+package many_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/many"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestGetTxInfo_Errors(t *testing.T) {
+	r := resty.New().SetBaseURL(testutils.RootUrl).SetPathParam("neighborhood", "0")
+	httpmock.ActivateNonDefault(r.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	tests := []struct {
+		desc   string
+		status int
+		err    error
+	}{
+		{"unavailable", http.StatusServiceUnavailable, many.ErrUnavailable},
+		{"rate_limited", http.StatusTooManyRequests, many.ErrUnavailable},
+		{"not_found", http.StatusNotFound, many.ErrTxNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			httpmock.RegisterResponder("GET", "=~^"+testutils.DefaultTransactionUrl, httpmock.NewStringResponder(tt.status, ""))
+			_, err := many.GetTxInfo(context.Background(), r, testutils.ManyHash)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestCheckTxInfo_Invalid(t *testing.T) {
+	itemUUID := uuid.MustParse(testutils.Uuid)
+	valid := many.Arguments{
+		From:   testutils.ManyFrom,
+		To:     many.IllegalAddr,
+		Amount: "100",
+		Symbol: testutils.ManySymbolAddress,
+		Memo:   []string{testutils.Uuid, testutils.ManifestAddress},
+	}
+	require.NoError(t, many.CheckTxInfo(&valid, itemUUID, testutils.ManifestAddress))
+
+	dust := valid
+	dust.Amount = "99"
+	wrongUUID := valid
+	wrongUUID.Memo = []string{uuid.NewString(), testutils.ManifestAddress}
+	badMemo := valid
+	badMemo.Memo = []string{testutils.Uuid}
+
+	for _, args := range []many.Arguments{dust, wrongUUID, badMemo} {
+		err := many.CheckTxInfo(&args, itemUUID, testutils.ManifestAddress)
+		require.ErrorIs(t, err, many.ErrInvalidTx)
+	}
+}
@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"text/template"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+// DefaultTemplate is the default Go template of the notification text
+const DefaultTemplate = `[mfx-migrator] {{.Title}}{{if .UUID}} ({{.UUID}}){{end}}: {{.Message}}`
+
+const webhookTimeout = 10 * time.Second
+
+// EventKind is the kind of event triggering a notification
+type EventKind string
+
+const (
+	WorkItemFailed        EventKind = "failed"
+	WorkItemHeld          EventKind = "held"
+	UnknownOutcome        EventKind = "unknown-outcome"
+	LowBalance            EventKind = "low-balance"
+	AuthFailures          EventKind = "auth-failures"
+	CircuitBreakerTripped EventKind = "circuit-breaker"
+)
+
+var eventTitles = map[EventKind]string{
+	WorkItemFailed:        "Work item failed",
+	WorkItemHeld:          "Work item held",
+	UnknownOutcome:        "Transaction outcome unknown",
+	LowBalance:            "Low bank balance",
+	AuthFailures:          "Repeated Talib authentication failures",
+	CircuitBreakerTripped: "Circuit breaker tripped",
+}
+
+// criticalKinds are the kinds of the events exempt from the rate limit, as an operator must review the work item
+// before it is migrated again. They are still deduplicated.
+var criticalKinds = map[EventKind]bool{
+	WorkItemHeld:   true,
+	UnknownOutcome: true,
+}
+
+// Event is an event an operator must be notified of
+type Event struct {
+	Kind         EventKind         `json:"kind"`
+	Key          string            `json:"-"` // Deduplication key, defaults to the kind, the neighborhood and the UUID
+	Neighborhood uint64            `json:"neighborhood,omitempty"`
+	UUID         string            `json:"uuid,omitempty"`
+	Message      string            `json:"message"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	Date         time.Time         `json:"date"`
+}
+
+// Title returns a human-readable title of the event
+func (e Event) Title() string {
+	if title, ok := eventTitles[e.Kind]; ok {
+		return title
+	}
+	return string(e.Kind)
+}
+
+func (e Event) dedupKey() string {
+	if e.Key != "" {
+		return e.Key
+	}
+	return fmt.Sprintf("%s:%d:%s", e.Kind, e.Neighborhood, e.UUID)
+}
+
+// Dispatcher sends notifications to the configured webhooks.
+//
+// Identical notifications are deduplicated and the number of notifications is rate limited, so an outage affecting
+// every work item does not flood the channels. The critical notifications are never rate limited. The state is shared between migrator processes using a lock file.
+// A nil Dispatcher, or one without webhooks, sends nothing.
+type Dispatcher struct {
+	config   config.NotifyConfig
+	webhooks []webhook
+	template *template.Template
+	client   *resty.Client
+	now      func() time.Time
+}
+
+func NewDispatcher(c config.NotifyConfig) (*Dispatcher, error) {
+	text := c.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template: %w", err)
+	}
+
+	var webhooks []webhook
+	for _, u := range c.SlackUrls {
+		webhooks = append(webhooks, webhook{url: u, format: slackFormat})
+	}
+	for _, u := range c.DiscordUrls {
+		webhooks = append(webhooks, webhook{url: u, format: discordFormat})
+	}
+	for _, u := range c.WebhookUrls {
+		webhooks = append(webhooks, webhook{url: u, format: jsonFormat})
+	}
+
+	return &Dispatcher{
+		config:   c,
+		webhooks: webhooks,
+		template: tmpl,
+		client:   resty.New().SetTimeout(webhookTimeout),
+		now:      time.Now,
+	}, nil
+}
+
+func (d *Dispatcher) enabled() bool {
+	return d != nil && len(d.webhooks) > 0
+}
+
+// Notify sends the event to every webhook, unless it is a duplicate or the rate limit is reached.
+// An event no webhook received is forgotten, so it is not suppressed as a duplicate when it is notified again.
+// Failures are logged, a notification never fails the caller.
+func (d *Dispatcher) Notify(ctx context.Context, e Event) {
+	if !d.enabled() {
+		return
+	}
+
+	if e.Date.IsZero() {
+		e.Date = d.now().UTC()
+	}
+
+	var text bytes.Buffer
+	if err := d.template.Execute(&text, e); err != nil {
+		slog.Error("Unable to render notification", "error", err)
+		return
+	}
+
+	allowed, sentAt, err := d.allow(e)
+	if err != nil {
+		slog.Error("Unable to check notification rate limit", "error", err)
+		return
+	}
+	if !allowed {
+		slog.Debug("Notification suppressed", "kind", e.Kind, "key", e.dedupKey())
+		return
+	}
+
+	delivered := false
+	for _, w := range d.webhooks {
+		if err := w.send(ctx, d.client, e, text.String()); err != nil {
+			slog.Error("Unable to send notification", "kind", e.Kind, "format", w.format, "error", err)
+			continue
+		}
+		delivered = true
+	}
+
+	if !delivered {
+		if err := d.forget(e, sentAt); err != nil {
+			slog.Error("Unable to forget undelivered notification", "error", err)
+		}
+	}
+}
+
+// RecordAuthResult counts the consecutive Talib authentication failures and notifies when the threshold is reached.
+// A successful authentication resets the count. Only the rejected credentials must be recorded as failures, not the
+// network errors.
+func (d *Dispatcher) RecordAuthResult(ctx context.Context, authErr error) {
+	if !d.enabled() {
+		return
+	}
+
+	var failures uint
+	err := d.withState(func(s *state) {
+		if authErr == nil {
+			delete(s.Counters, string(AuthFailures))
+			return
+		}
+		s.Counters[string(AuthFailures)]++
+		failures = s.Counters[string(AuthFailures)]
+	})
+	if err != nil {
+		slog.Error("Unable to record authentication result", "error", err)
+		return
+	}
+
+	if authErr != nil && failures >= d.config.AuthFailureThreshold {
+		d.Notify(ctx, Event{
+			Kind:    AuthFailures,
+			Message: fmt.Sprintf("%d consecutive failures, last error: %s", failures, authErr),
+			Fields:  map[string]string{"failures": fmt.Sprint(failures)},
+		})
+	}
+}
+
+// allow returns true if the event is neither a duplicate nor over the rate limit, and records it with the returned date.
+// The critical events neither count toward nor are subject to the rate limit.
+func (d *Dispatcher) allow(e Event) (bool, time.Time, error) {
+	allowed := false
+	now := d.now()
+	err := d.withState(func(s *state) {
+		dedupWindow := time.Duration(d.config.DedupWindow) * time.Second
+		rateWindow := time.Duration(d.config.RateLimitWindow) * time.Second
+
+		for key, last := range s.Sent {
+			if now.Sub(last) >= dedupWindow {
+				delete(s.Sent, key)
+			}
+		}
+		window := s.Window[:0]
+		for _, sent := range s.Window {
+			if now.Sub(sent) < rateWindow {
+				window = append(window, sent)
+			}
+		}
+		s.Window = window
+
+		if _, ok := s.Sent[e.dedupKey()]; ok {
+			return
+		}
+		limited := d.config.RateLimit > 0 && !criticalKinds[e.Kind]
+		if limited && uint(len(s.Window)) >= d.config.RateLimit {
+			slog.Warn("Notification rate limit reached", "kind", e.Kind, "limit", d.config.RateLimit)
+			return
+		}
+
+		s.Sent[e.dedupKey()] = now
+		if limited {
+			s.Window = append(s.Window, now)
+		}
+		allowed = true
+	})
+	return allowed, now, err
+}
+
+// forget removes the record of the event sent at the given date, e.g., once no webhook received it
+func (d *Dispatcher) forget(e Event, sentAt time.Time) error {
+	return d.withState(func(s *state) {
+		if last, ok := s.Sent[e.dedupKey()]; ok && last.Equal(sentAt) {
+			delete(s.Sent, e.dedupKey())
+		}
+		for i, sent := range s.Window {
+			if sent.Equal(sentAt) {
+				s.Window = append(s.Window[:i], s.Window[i+1:]...)
+				break
+			}
+		}
+	})
+}
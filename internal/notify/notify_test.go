@@ -0,0 +1,207 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/notify"
+)
+
+// webhookServer records the payloads received per path
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads map[string][]map[string]any
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	s := &webhookServer{payloads: map[string][]map[string]any{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal(data, &payload))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.payloads[r.URL.Path] = append(s.payloads[r.URL.Path], payload)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) received(path string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.payloads[path]
+}
+
+func newNotifyConfig(t *testing.T, s *webhookServer) config.NotifyConfig {
+	return config.NotifyConfig{
+		SlackUrls:            []string{s.URL + "/slack"},
+		DiscordUrls:          []string{s.URL + "/discord"},
+		WebhookUrls:          []string{s.URL + "/json"},
+		DedupWindow:          3600,
+		RateLimit:            10,
+		RateLimitWindow:      3600,
+		AuthFailureThreshold: 3,
+		StateFile:            filepath.Join(t.TempDir(), "notify-state.json"),
+	}
+}
+
+func TestNotify_Formats(t *testing.T) {
+	s := newWebhookServer(t)
+	d, err := notify.NewDispatcher(newNotifyConfig(t, s))
+	require.NoError(t, err)
+
+	d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemFailed, UUID: "some-uuid", Message: "boom", Fields: map[string]string{"foo": "bar"}})
+
+	text := "[mfx-migrator] Work item failed (some-uuid): boom"
+	require.Equal(t, []map[string]any{{"text": text}}, s.received("/slack"))
+	require.Equal(t, []map[string]any{{"content": text}}, s.received("/discord"))
+
+	payloads := s.received("/json")
+	require.Len(t, payloads, 1)
+	require.Equal(t, "failed", payloads[0]["kind"])
+	require.Equal(t, "Work item failed", payloads[0]["title"])
+	require.Equal(t, "some-uuid", payloads[0]["uuid"])
+	require.Equal(t, "boom", payloads[0]["message"])
+	require.Equal(t, text, payloads[0]["text"])
+	require.Equal(t, map[string]any{"foo": "bar"}, payloads[0]["fields"])
+	require.NotEmpty(t, payloads[0]["date"])
+}
+
+func TestNotify_Template(t *testing.T) {
+	s := newWebhookServer(t)
+	c := newNotifyConfig(t, s)
+	c.Template = `{{.Kind}} {{index .Fields "txHash"}}`
+	d, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+
+	d.Notify(context.Background(), notify.Event{Kind: notify.UnknownOutcome, UUID: "some-uuid", Fields: map[string]string{"txHash": "HASH"}})
+	require.Equal(t, []map[string]any{{"text": "unknown-outcome HASH"}}, s.received("/slack"))
+}
+
+func TestNotify_Dedup(t *testing.T) {
+	s := newWebhookServer(t)
+	c := newNotifyConfig(t, s)
+	d, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	d.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-1", Message: "boom"})
+	d.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-1", Message: "boom again"})
+	d.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-2", Message: "boom"})
+	d.Notify(ctx, notify.Event{Kind: notify.CircuitBreakerTripped, Key: "breaker", UUID: "uuid-3"})
+	d.Notify(ctx, notify.Event{Kind: notify.CircuitBreakerTripped, Key: "breaker", UUID: "uuid-4"})
+	require.Len(t, s.received("/json"), 3)
+
+	// The state is shared with other dispatchers using the same state file
+	other, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+	other.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-1", Message: "boom"})
+	require.Len(t, s.received("/json"), 3)
+
+	// A work item of another neighborhood sharing the UUID is not a duplicate
+	other.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, Neighborhood: 2, UUID: "uuid-1", Message: "boom"})
+	require.Len(t, s.received("/json"), 4)
+
+	// Without deduplication window, every notification is sent
+	c.DedupWindow = 0
+	c.StateFile = filepath.Join(t.TempDir(), "notify-state.json")
+	d, err = notify.NewDispatcher(c)
+	require.NoError(t, err)
+	d.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-1", Message: "boom"})
+	d.Notify(ctx, notify.Event{Kind: notify.WorkItemFailed, UUID: "uuid-1", Message: "boom"})
+	require.Len(t, s.received("/json"), 6)
+}
+
+func TestNotify_RateLimit(t *testing.T) {
+	s := newWebhookServer(t)
+	c := newNotifyConfig(t, s)
+	c.RateLimit = 2
+	d, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+
+	for _, uuid := range []string{"uuid-1", "uuid-2", "uuid-3", "uuid-4"} {
+		d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemFailed, UUID: uuid})
+	}
+	require.Len(t, s.received("/json"), 2)
+
+	// The critical notifications are never rate limited, but still deduplicated
+	d.Notify(context.Background(), notify.Event{Kind: notify.UnknownOutcome, UUID: "uuid-5"})
+	d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemHeld, UUID: "uuid-6"})
+	d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemHeld, UUID: "uuid-6"})
+	require.Len(t, s.received("/json"), 4)
+}
+
+func TestNotify_Undelivered(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	var delivered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		delivered.Add(1)
+	}))
+	t.Cleanup(server.Close)
+
+	c := newNotifyConfig(t, newWebhookServer(t))
+	c.SlackUrls, c.DiscordUrls, c.WebhookUrls = nil, nil, []string{server.URL}
+	d, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+
+	// An undelivered notification is not recorded, so it is not suppressed as a duplicate
+	d.Notify(context.Background(), notify.Event{Kind: notify.UnknownOutcome, UUID: "uuid-1"})
+	require.Zero(t, delivered.Load())
+	down.Store(false)
+	d.Notify(context.Background(), notify.Event{Kind: notify.UnknownOutcome, UUID: "uuid-1"})
+	d.Notify(context.Background(), notify.Event{Kind: notify.UnknownOutcome, UUID: "uuid-1"})
+	require.Equal(t, int32(1), delivered.Load())
+}
+
+func TestNotify_AuthFailures(t *testing.T) {
+	s := newWebhookServer(t)
+	d, err := notify.NewDispatcher(newNotifyConfig(t, s))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	authErr := errors.New("response status code: 401")
+	d.RecordAuthResult(ctx, authErr)
+	d.RecordAuthResult(ctx, authErr)
+	d.RecordAuthResult(ctx, nil) // A success resets the count
+	d.RecordAuthResult(ctx, authErr)
+	d.RecordAuthResult(ctx, authErr)
+	require.Empty(t, s.received("/json"))
+
+	d.RecordAuthResult(ctx, authErr)
+	payloads := s.received("/json")
+	require.Len(t, payloads, 1)
+	require.Equal(t, "auth-failures", payloads[0]["kind"])
+	require.Contains(t, payloads[0]["message"], "3 consecutive failures")
+}
+
+func TestNotify_Disabled(t *testing.T) {
+	var d *notify.Dispatcher
+	d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemFailed})
+	d.RecordAuthResult(context.Background(), errors.New("boom"))
+
+	c := config.NotifyConfig{AuthFailureThreshold: 1, StateFile: filepath.Join(t.TempDir(), "notify-state.json")}
+	d, err := notify.NewDispatcher(c)
+	require.NoError(t, err)
+	d.Notify(context.Background(), notify.Event{Kind: notify.WorkItemFailed})
+	require.NoFileExists(t, c.StateFile)
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// state is the deduplication and rate limiting state, shared by every migrator process
+type state struct {
+	Sent     map[string]time.Time `json:"sent"`     // Date of the last notification per deduplication key
+	Window   []time.Time          `json:"window"`   // Dates of the notifications sent during the rate limit window
+	Counters map[string]uint      `json:"counters"` // Event counters, e.g., consecutive authentication failures
+}
+
+// withState loads the state under an exclusive lock, applies `fn` and saves the state
+func (d *Dispatcher) withState(fn func(s *state)) error {
+	lock, err := os.OpenFile(d.config.StateFile+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification lock file: %w", err)
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock notification lock file: %w", err)
+	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	s := state{Sent: map[string]time.Time{}, Counters: map[string]uint{}}
+	data, err := os.ReadFile(d.config.StateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read notification state file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("failed to unmarshal notification state file: %w", err)
+		}
+		if s.Sent == nil {
+			s.Sent = map[string]time.Time{}
+		}
+		if s.Counters == nil {
+			s.Counters = map[string]uint{}
+		}
+	}
+
+	fn(&s)
+
+	data, err = json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification state file: %w", err)
+	}
+	if err := os.WriteFile(d.config.StateFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write notification state file: %w", err)
+	}
+
+	return nil
+}
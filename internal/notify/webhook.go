@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// discordMaxContent is the maximum length of a Discord message
+const discordMaxContent = 2000
+
+type format string
+
+const (
+	slackFormat   format = "slack"
+	discordFormat format = "discord"
+	jsonFormat    format = "json"
+)
+
+// webhook is a webhook endpoint and the payload format it expects
+type webhook struct {
+	url    string
+	format format
+}
+
+// jsonPayload is the payload of the generic JSON webhooks
+type jsonPayload struct {
+	Event
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+func (w webhook) payload(e Event, text string) any {
+	switch w.format {
+	case slackFormat:
+		return map[string]string{"text": text}
+	case discordFormat:
+		if len(text) > discordMaxContent {
+			text = text[:discordMaxContent-3] + "..."
+		}
+		return map[string]string{"content": text}
+	default:
+		return jsonPayload{Event: e, Title: e.Title(), Text: text}
+	}
+}
+
+func (w webhook) send(ctx context.Context, client *resty.Client, e Event, text string) error {
+	response, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(w.payload(e, text)).
+		Post(w.url)
+	if err != nil {
+		return err
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("webhook response status code: %d", response.StatusCode())
+	}
+
+	return nil
+}
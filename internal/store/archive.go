@@ -0,0 +1,41 @@
+package store
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// CompletedDir is the directory holding the local state of the completed work items, e.g., the gas used and the fee
+// paid by the migration transaction, which are not stored remotely.
+// The work items are laid out by neighborhood as in the state directory.
+var CompletedDir = "completed"
+
+// CompletedPath returns the path of the archived state file of the completed work item of the given neighborhood
+func CompletedPath(neighborhood uint64, uuid string) string {
+	return filepath.Join(CompletedDir, StatePath(neighborhood, uuid))
+}
+
+// ArchiveWorkItem moves the local state of the completed work item to the completed directory
+func ArchiveWorkItem(item WorkItem) error {
+	slog.Info("archiving completed work item", "neighborhood", item.Neighborhood, "uuid", item.UUID)
+	if item.Status != COMPLETED {
+		return errors.WithMessagef(ErrInvalidStatus, "archiving %s work item %s", item.Status, item.UUID)
+	}
+	if err := saveStateFile(CompletedPath(item.Neighborhood, item.UUID.String()), &item); err != nil {
+		return errors.WithMessage(err, "unable to archive work item")
+	}
+
+	if err := os.Remove(StatePath(item.Neighborhood, item.UUID.String())); err != nil && !os.IsNotExist(err) {
+		return errors.WithMessage(err, "unable to remove local state file")
+	}
+
+	return nil
+}
+
+// LoadCompleted loads the archived state of the completed work item of the given neighborhood
+func LoadCompleted(neighborhood uint64, uuid string) (*WorkItem, error) {
+	return loadStateFile(CompletedPath(neighborhood, uuid), neighborhood, uuid)
+}
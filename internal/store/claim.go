@@ -1,24 +1,35 @@
 package store
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
 )
 
-// ClaimWorkItemFromQueue retrieves a work item from the remote database work queue.
-func ClaimWorkItemFromQueue(r *resty.Client) ([]*WorkItem, error) {
+// ClaimWorkItemFromQueue retrieves a work item from the remote database work queue of the neighborhood.
+// The REST client must target the same neighborhood.
+func ClaimWorkItemFromQueue(ctx context.Context, r *resty.Client, neighborhood uint64) (_ []*WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "store.ClaimWorkItemFromQueue", tracing.Neighborhood(neighborhood))
+	defer func() { tracing.End(span, err) }()
+
 	// 1. Claim work items
-	items, err := claimWorkItems(r)
+	items, err := claimWorkItems(ctx, r)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error claiming work items")
 	}
+	for _, item := range items {
+		span.SetAttributes(tracing.WorkItem(item.UUID.String()))
+	}
 
 	// 2. Save the work item states
 	for _, item := range items {
+		item.Neighborhood = neighborhood
 		if err := SaveState(item); err != nil {
 			return nil, err
 		}
@@ -27,12 +38,18 @@ func ClaimWorkItemFromQueue(r *resty.Client) ([]*WorkItem, error) {
 	return items, nil
 }
 
-func ClaimWorkItemFromUUID(r *resty.Client, uuid uuid.UUID, force bool) (*WorkItem, error) {
-	item, err := claimWorkItem(r, uuid, force)
+// ClaimWorkItemFromUUID claims the given work item of the neighborhood from the remote database.
+// The REST client must target the same neighborhood.
+func ClaimWorkItemFromUUID(ctx context.Context, r *resty.Client, neighborhood uint64, uuid uuid.UUID, force bool) (_ *WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "store.ClaimWorkItemFromUUID", tracing.Neighborhood(neighborhood), tracing.WorkItem(uuid.String()))
+	defer func() { tracing.End(span, err) }()
+
+	item, err := claimWorkItem(ctx, r, uuid, force)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error claiming work item")
 	}
 
+	item.Neighborhood = neighborhood
 	if err := SaveState(item); err != nil {
 		return nil, err
 	}
@@ -40,51 +57,33 @@ func ClaimWorkItemFromUUID(r *resty.Client, uuid uuid.UUID, force bool) (*WorkIt
 	return item, nil
 }
 
-func claimWorkItems(r *resty.Client) ([]*WorkItem, error) {
-	req := r.R().SetResult(&[]*WorkItem{})
+func claimWorkItems(ctx context.Context, r *resty.Client) ([]*WorkItem, error) {
+	req := r.R().SetContext(ctx).SetResult(&[]*WorkItem{})
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/claim/")
-	if err != nil {
+	if err := checkResponse(response, err); err != nil {
 		return nil, errors.WithMessage(err, "error claiming work items")
 	}
 
-	if response == nil {
-		return nil, fmt.Errorf("no response returned when claiming work items")
-	}
-
-	statusCode := response.StatusCode()
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
-	}
-
 	claimResponse := response.Result().(*[]*WorkItem)
 	if claimResponse == nil {
-		return nil, fmt.Errorf("error unmarshalling claim response")
+		return nil, utils.Mark(fmt.Errorf("error unmarshalling claim response"), ErrUnexpectedResponse)
 	}
 
 	return *claimResponse, nil
 }
 
-func claimWorkItem(r *resty.Client, itemUUID uuid.UUID, force bool) (*WorkItem, error) {
-	req := r.R().SetResult(&WorkItem{}).
+func claimWorkItem(ctx context.Context, r *resty.Client, itemUUID uuid.UUID, force bool) (*WorkItem, error) {
+	req := r.R().SetContext(ctx).SetResult(&WorkItem{}).
 		SetPathParam("uuid", itemUUID.String()).
 		SetQueryParam("force", fmt.Sprintf("%t", force))
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/claim/{uuid}")
-	if err != nil {
-		return nil, errors.WithMessage(err, "error claiming work item")
-	}
-
-	if response == nil {
-		return nil, fmt.Errorf("no response returned when claiming work item: %s", itemUUID)
-	}
-
-	statusCode := response.StatusCode()
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
+	if err := checkResponse(response, err); err != nil {
+		return nil, errors.WithMessagef(err, "error claiming work item: %s", itemUUID)
 	}
 
 	item := response.Result().(*WorkItem)
 	if item == nil {
-		return nil, fmt.Errorf("error unmarshalling claim response")
+		return nil, utils.Mark(fmt.Errorf("error unmarshalling claim response"), ErrUnexpectedResponse)
 	}
 
 	return item, nil
@@ -1,6 +1,8 @@
 package store_test
 
 import (
+	"context"
+	"net/http"
 	"net/url"
 	"os"
 	"testing"
@@ -35,7 +37,7 @@ func TestStore_Claim(t *testing.T) {
 		{"success_queue", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.MigrationClaimResponder(1, store.CLAIMED)},
 		}, func() {
-			items, err := store.ClaimWorkItemFromQueue(rClient)
+			items, err := store.ClaimWorkItemFromQueue(context.Background(), rClient, 1)
 			require.NotEmpty(t, items)
 			require.NotEqual(t, uuid.Nil, items[0].UUID)
 			require.NoError(t, err)
@@ -45,7 +47,7 @@ func TestStore_Claim(t *testing.T) {
 		{"no_item_queue", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.MigrationClaimResponder(0, store.CLAIMED)},
 		}, func() {
-			item, err := store.ClaimWorkItemFromQueue(rClient)
+			item, err := store.ClaimWorkItemFromQueue(context.Background(), rClient, 1)
 			require.NoError(t, err) // no work items available
 			require.Empty(t, item)
 		}},
@@ -54,7 +56,7 @@ func TestStore_Claim(t *testing.T) {
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.MigrationClaimOneResponder(store.CLAIMED)},
 		}, func() {
 			myUUID := uuid.MustParse("5aa19d2a-4bdf-4687-a850-1804756b3f1f")
-			item, err := store.ClaimWorkItemFromUUID(rClient, myUUID, false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, 1, myUUID, false)
 			require.NoError(t, err)
 			require.NotNil(t, item)
 			require.Equal(t, myUUID, item.UUID)
@@ -63,26 +65,28 @@ func TestStore_Claim(t *testing.T) {
 		{"failure_uuid_not_found", []testutils.HttpResponder{
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.NotFoundResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromUUID(rClient, uuid.New(), false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, 1, uuid.New(), false)
 			require.Error(t, err) // work item not found
 			require.ErrorContains(t, err, "error claiming work item")
 			require.ErrorContains(t, err, "status code: 404")
+			require.ErrorIs(t, err, store.ErrNotFound)
 			require.Nil(t, item)
 		}},
 		// Fail to claim a work item by UUID (response is not a work item)
 		{"invalid_work_item", []testutils.HttpResponder{
 			{Method: "PUT", Url: "=~^" + testutils.ClaimUuidUrl, Responder: testutils.GarbageResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromUUID(rClient, uuid.New(), false)
+			item, err := store.ClaimWorkItemFromUUID(context.Background(), rClient, 1, uuid.New(), false)
 			require.Error(t, err)
 			require.ErrorContains(t, err, "cannot unmarshal")
+			require.ErrorIs(t, err, store.ErrUnexpectedResponse)
 			require.Nil(t, item)
 		}},
 		// Fail to claim a work item from the queue (work item list is invalid)
 		{"invalid_work_items", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.GarbageResponder},
 		}, func() {
-			item, err := store.ClaimWorkItemFromQueue(rClient)
+			item, err := store.ClaimWorkItemFromQueue(context.Background(), rClient, 1)
 			require.Error(t, err)
 			require.ErrorContains(t, err, "cannot unmarshal")
 			require.Nil(t, item)
@@ -91,11 +95,19 @@ func TestStore_Claim(t *testing.T) {
 		{"invalid_all_work_items_url", []testutils.HttpResponder{
 			{Method: "PUT", Url: testutils.ClaimUrl, Responder: testutils.NotFoundResponder},
 		}, func() {
-			_, err := store.ClaimWorkItemFromQueue(rClient)
+			_, err := store.ClaimWorkItemFromQueue(context.Background(), rClient, 1)
 			require.Error(t, err) // unable to list work items
 			require.ErrorContains(t, err, "error claiming work items")
 			require.ErrorContains(t, err, "status code: 404")
 		}},
+		// Fail to claim a work item from the queue (remote database temporarily unavailable)
+		{"unavailable_queue", []testutils.HttpResponder{
+			{Method: "PUT", Url: testutils.ClaimUrl, Responder: httpmock.NewStringResponder(http.StatusServiceUnavailable, "")},
+		}, func() {
+			_, err := store.ClaimWorkItemFromQueue(context.Background(), rClient, 1)
+			require.ErrorContains(t, err, "status code: 503")
+			require.ErrorIs(t, err, store.ErrUnavailable)
+		}},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StateKeyEnv is the environment variable holding the state file keys when no key file is configured
+const StateKeyEnv = "MFX_MIGRATOR_STATE_KEY"
+
+const stateKeySize = 32 // AES-256
+
+var (
+	// ErrStateIntegrity is returned when a state file cannot be authenticated, e.g., it was tampered with or encrypted
+	// with an unknown key
+	ErrStateIntegrity = errors.New("state file integrity check failed")
+	// ErrInvalidStateKey is returned when a state key is malformed
+	ErrInvalidStateKey = errors.New("invalid state key")
+)
+
+// StateEncryption encrypts and authenticates the state files when set. Nil disables encryption.
+var StateEncryption *StateKeys
+
+// SealPlaintextState accepts the plaintext state files when StateEncryption is set, and seals them on load, e.g., to
+// encrypt the files written before a key was configured. When disabled, such files are refused as they are not
+// authenticated.
+var SealPlaintextState = false
+
+// StateKeys is a set of AES-256-GCM keys used to encrypt the state files.
+// The first key encrypts, every key decrypts, which allows rotating keys without re-encrypting the files at once.
+type StateKeys struct {
+	keys []stateKey
+}
+
+type stateKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// LoadStateKeys loads the state keys from the given file, or from StateKeyEnv when no file is given.
+// It returns nil if no key is configured.
+func LoadStateKeys(file string) (*StateKeys, error) {
+	encoded := os.Getenv(StateKeyEnv)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state key file: %w", err)
+		}
+		encoded = string(data)
+	}
+
+	if strings.TrimSpace(encoded) == "" {
+		return nil, nil
+	}
+
+	return ParseStateKeys(encoded)
+}
+
+// ParseStateKeys parses hex or base64 encoded 32 bytes keys separated by whitespace or commas.
+// The first key is the current key.
+func ParseStateKeys(encoded string) (*StateKeys, error) {
+	fields := strings.FieldsFunc(encoded, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return nil, errors.WithMessage(ErrInvalidStateKey, "no key")
+	}
+
+	keys := &StateKeys{}
+	for i, field := range fields {
+		raw, err := decodeStateKey(field)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrInvalidStateKey, "key %d: %s", i, err)
+		}
+
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrInvalidStateKey, "key %d: %s", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrInvalidStateKey, "key %d: %s", i, err)
+		}
+
+		sum := sha256.Sum256(raw)
+		keys.keys = append(keys.keys, stateKey{id: hex.EncodeToString(sum[:4]), aead: aead})
+	}
+
+	return keys, nil
+}
+
+func decodeStateKey(s string) ([]byte, error) {
+	if raw, err := hex.DecodeString(s); err == nil && len(raw) == stateKeySize {
+		return raw, nil
+	}
+	if raw, err := base64.StdEncoding.DecodeString(s); err == nil && len(raw) == stateKeySize {
+		return raw, nil
+	}
+	return nil, fmt.Errorf("expected %d bytes, hex or base64 encoded", stateKeySize)
+}
+
+// seal encrypts the envelope item with the current key.
+// The version, the key ID, the neighborhood and the UUID are authenticated so a file cannot be downgraded or swapped
+// with another, including the work item sharing its UUID in another neighborhood.
+func (k *StateKeys) seal(envelope *stateEnvelope, neighborhood uint64, uuid string) error {
+	key := k.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	envelope.KeyID = key.id
+	envelope.Nonce = nonce
+	envelope.Ciphertext = key.aead.Seal(nil, nonce, envelope.Item, stateAdditionalData(envelope, neighborhood, uuid))
+	envelope.Item = nil
+
+	return nil
+}
+
+// open authenticates and decrypts the envelope item.
+// A plaintext envelope is refused unless SealPlaintextState is enabled. It returns true if the file must be sealed
+// again, i.e., it is stored in plaintext.
+func (k *StateKeys) open(envelope *stateEnvelope, neighborhood uint64, uuid string) (bool, error) {
+	if envelope.Ciphertext == nil {
+		if !SealPlaintextState {
+			return false, errors.WithMessage(ErrStateIntegrity, "state file is not encrypted")
+		}
+		slog.Warn("Accepting plaintext state file, it is encrypted on load", "neighborhood", neighborhood, "uuid", uuid)
+		return true, nil
+	}
+
+	for _, key := range k.keys {
+		if key.id != envelope.KeyID {
+			continue
+		}
+		if len(envelope.Nonce) != key.aead.NonceSize() {
+			return false, errors.WithMessage(ErrStateIntegrity, "invalid nonce")
+		}
+
+		item, err := key.aead.Open(nil, envelope.Nonce, envelope.Ciphertext, stateAdditionalData(envelope, neighborhood, uuid))
+		if err != nil {
+			return false, errors.WithMessage(ErrStateIntegrity, "authentication failed")
+		}
+		envelope.Item = item
+		return false, nil
+	}
+
+	return false, errors.WithMessagef(ErrStateIntegrity, "unknown key %q", envelope.KeyID)
+}
+
+func stateAdditionalData(envelope *stateEnvelope, neighborhood uint64, uuid string) []byte {
+	return []byte(fmt.Sprintf("%d:%s:%d:%s", envelope.Version, envelope.KeyID, neighborhood, uuid))
+}
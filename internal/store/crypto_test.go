@@ -0,0 +1,214 @@
+package store_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/store"
+)
+
+const (
+	stateKey1 = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	stateKey2 = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+func setStateKeys(t *testing.T, encoded string) {
+	t.Helper()
+	keys, err := store.ParseStateKeys(encoded)
+	require.NoError(t, err)
+	store.StateEncryption = keys
+	t.Cleanup(func() { store.StateEncryption = nil })
+}
+
+func newEncryptedState(t *testing.T) (*store.WorkItem, string) {
+	t.Helper()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New(), ManifestAddress: "manifest1destination"}
+	require.NoError(t, store.SaveState(item))
+
+	return item, item.UUID.String() + ".json"
+}
+
+func TestStateEncryption(t *testing.T) {
+	setStateKeys(t, stateKey1)
+	item, path := newEncryptedState(t)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "manifest1destination")
+
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.True(t, item.Equal(*otherItem))
+}
+
+func TestStateEncryption_Rotation(t *testing.T) {
+	setStateKeys(t, stateKey1)
+	item, path := newEncryptedState(t)
+	old, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// The new key is current, the old key still decrypts
+	setStateKeys(t, stateKey2+"\n"+stateKey1)
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.True(t, item.Equal(*otherItem))
+
+	// Saving re-encrypts with the new key
+	require.NoError(t, store.SaveState(otherItem))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotEqual(t, old, data)
+
+	setStateKeys(t, stateKey2)
+	_, err = store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+
+	setStateKeys(t, stateKey1)
+	_, err = store.LoadState(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrStateIntegrity)
+	require.ErrorContains(t, err, "unknown key")
+}
+
+func TestStateEncryption_Integrity(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, envelope map[string]any) map[string]any
+		err    string
+	}{
+		{"ciphertext", func(t *testing.T, envelope map[string]any) map[string]any {
+			ciphertext, err := base64.StdEncoding.DecodeString(envelope["ciphertext"].(string))
+			require.NoError(t, err)
+			ciphertext[0] ^= 1
+			envelope["ciphertext"] = base64.StdEncoding.EncodeToString(ciphertext)
+			return envelope
+		}, "authentication failed"},
+		{"version", func(t *testing.T, envelope map[string]any) map[string]any {
+			envelope["version"] = 0
+			return envelope
+		}, "authentication failed"},
+		{"nonce", func(t *testing.T, envelope map[string]any) map[string]any {
+			envelope["nonce"] = "AAAA"
+			return envelope
+		}, "invalid nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setStateKeys(t, stateKey1)
+			item, path := newEncryptedState(t)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var envelope map[string]any
+			require.NoError(t, json.Unmarshal(data, &envelope))
+			data, err = json.Marshal(tt.tamper(t, envelope))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, data, 0600))
+
+			_, err = store.LoadState(0, item.UUID.String())
+			require.ErrorIs(t, err, store.ErrStateIntegrity)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestStateEncryption_Swap(t *testing.T) {
+	setStateKeys(t, stateKey1)
+	item, path := newEncryptedState(t)
+
+	// A valid file of another work item is refused
+	other := uuid.New()
+	require.NoError(t, os.Rename(path, other.String()+".json"))
+	_, err := store.LoadState(0, other.String())
+	require.ErrorIs(t, err, store.ErrStateIntegrity)
+
+	require.NoError(t, os.Rename(other.String()+".json", path))
+	_, err = store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+}
+
+func TestStateEncryption_Neighborhood(t *testing.T) {
+	setStateKeys(t, stateKey1)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New(), Neighborhood: 1}
+	require.NoError(t, store.SaveState(item))
+
+	// A valid file of the work item sharing the UUID in another neighborhood is refused
+	require.NoError(t, os.MkdirAll(filepath.Dir(store.StatePath(2, item.UUID.String())), 0700))
+	require.NoError(t, os.Rename(store.StatePath(1, item.UUID.String()), store.StatePath(2, item.UUID.String())))
+	_, err := store.LoadState(2, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrStateIntegrity)
+}
+
+func TestStateEncryption_Plaintext(t *testing.T) {
+	item, path := newEncryptedState(t)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "manifest1destination")
+
+	// A plaintext file is not authenticated, it is refused once a key is configured
+	setStateKeys(t, stateKey1)
+	_, err = store.LoadState(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrStateIntegrity)
+	require.ErrorContains(t, err, "not encrypted")
+
+	// It is accepted and sealed on explicit opt-in only
+	store.SealPlaintextState = true
+	t.Cleanup(func() { store.SealPlaintextState = false })
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.True(t, item.Equal(*otherItem))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "manifest1destination")
+	store.SealPlaintextState = false
+	_, err = store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+}
+
+func TestStateEncryption_NoKey(t *testing.T) {
+	setStateKeys(t, stateKey1)
+	item, _ := newEncryptedState(t)
+
+	store.StateEncryption = nil
+	_, err := store.LoadState(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrStateIntegrity)
+	require.ErrorContains(t, err, "no state key is configured")
+}
+
+func TestLoadStateKeys(t *testing.T) {
+	t.Setenv(store.StateKeyEnv, "")
+	keys, err := store.LoadStateKeys("")
+	require.NoError(t, err)
+	require.Nil(t, keys)
+
+	t.Setenv(store.StateKeyEnv, stateKey1)
+	keys, err = store.LoadStateKeys("")
+	require.NoError(t, err)
+	require.NotNil(t, keys)
+
+	path := t.TempDir() + "/keys"
+	require.NoError(t, os.WriteFile(path, []byte("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=, "+stateKey2+"\n"), 0600))
+	keys, err = store.LoadStateKeys(path)
+	require.NoError(t, err)
+	require.NotNil(t, keys)
+
+	for _, encoded := range []string{",", "00", strings.Repeat("zz", 32)} {
+		_, err = store.ParseStateKeys(encoded)
+		require.ErrorIs(t, err, store.ErrInvalidStateKey, encoded)
+	}
+}
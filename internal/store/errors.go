@@ -1,7 +1,58 @@
 package store
 
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
 const (
 	ErrorGettingWorkItems = "could not get all work items"
 	ErrorGettingWorkItem  = "could not get work item"
 	ErrorClaimingWorkItem = "could not claim work item"
 )
+
+var (
+	// ErrUnavailable is returned when the remote database cannot be reached or is temporarily unavailable, e.g., HTTP 503.
+	// The request can be retried.
+	ErrUnavailable = errors.New("remote database unavailable")
+	// ErrUnauthorized is returned when the remote database rejects the credentials or the token
+	ErrUnauthorized = errors.New("remote database unauthorized")
+	// ErrNotFound is returned when the remote work item does not exist
+	ErrNotFound = errors.New("remote work item not found")
+	// ErrUnexpectedResponse is returned when the remote database rejects the request or returns an unexpected response
+	ErrUnexpectedResponse = errors.New("unexpected remote database response")
+)
+
+// checkResponse classifies the error and response status code of a remote database request
+func checkResponse(response *resty.Response, err error) error {
+	if err != nil {
+		// A response was received but could not be parsed
+		if response != nil && response.RawResponse != nil {
+			return utils.Mark(err, ErrUnexpectedResponse)
+		}
+		return utils.Mark(err, ErrUnavailable)
+	}
+
+	if response == nil {
+		return utils.Mark(fmt.Errorf("no response returned"), ErrUnavailable)
+	}
+
+	statusCode := response.StatusCode()
+	switch {
+	case statusCode == http.StatusOK:
+		return nil
+	case utils.IsTemporaryStatus(statusCode):
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrUnavailable)
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrUnauthorized)
+	case statusCode == http.StatusNotFound:
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrNotFound)
+	default:
+		return utils.Mark(fmt.Errorf("response status code: %d", statusCode), ErrUnexpectedResponse)
+	}
+}
@@ -1,37 +1,35 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
 )
 
 // GetWorkItem retrieves a work item from the remote database by UUID.
-func GetWorkItem(r *resty.Client, itemUUID uuid.UUID) (*WorkItem, error) {
+func GetWorkItem(ctx context.Context, r *resty.Client, itemUUID uuid.UUID) (_ *WorkItem, err error) {
+	ctx, span := tracing.Start(ctx, "store.GetWorkItem", tracing.WorkItem(itemUUID.String()))
+	defer func() { tracing.End(span, err) }()
+
 	req := r.R().
+		SetContext(ctx).
 		SetPathParam("uuid", itemUUID.String()).
 		SetResult(&WorkItem{})
 	response, err := req.Get("neighborhoods/{neighborhood}/migrations/{uuid}")
-	if err != nil {
+	if err := checkResponse(response, err); err != nil {
 		return nil, errors.WithMessage(err, ErrorGettingWorkItem)
 	}
 
-	if response == nil {
-		return nil, fmt.Errorf("response is nil")
-	}
-
-	statusCode := response.StatusCode()
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("response status code: %d", statusCode)
-	}
-
 	item := response.Result().(*WorkItem)
 	if item == nil || (item != nil && item.IsNil()) {
-		return nil, fmt.Errorf("error unmarshalling work item")
+		return nil, utils.Mark(fmt.Errorf("error unmarshalling work item"), ErrUnexpectedResponse)
 	}
 	slog.Debug("work item", "item", item)
 	return item, nil
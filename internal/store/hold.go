@@ -0,0 +1,45 @@
+package store
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotEscalated is returned when resolving a work item which is not held and has no transaction broadcast
+var ErrNotEscalated = errors.New("work item not escalated")
+
+// ErrTxBroadcast is returned when releasing a held work item whose migration transaction was broadcast.
+// The transaction must be checked on chain instead, see ClearBroadcast.
+var ErrTxBroadcast = errors.New("work item transaction broadcast")
+
+// HoldWorkItem puts the work item on hold and saves the state locally.
+// The remote work item is left untouched, i.e., it stays claimed by this migrator.
+func HoldWorkItem(item WorkItem, reason string) error {
+	slog.Warn("holding work item", "uuid", item.UUID, "reason", reason)
+	item.Hold = &Hold{Reason: reason, Date: time.Now().UTC()}
+	return SaveState(&item)
+}
+
+// ReleaseWorkItem releases the hold of the local work item, e.g., after an operator reviewed a denylist match.
+// It refuses to release a work item whose migration transaction was broadcast, the tokens may have been sent.
+func ReleaseWorkItem(neighborhood uint64, uuid string) (*WorkItem, error) {
+	item, err := LoadState(neighborhood, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if item.Hold == nil {
+		return nil, errors.WithMessagef(ErrNotEscalated, "not held: %s", uuid)
+	}
+	if item.UnknownOutcome != nil || item.Broadcast != nil {
+		return nil, errors.WithMessagef(ErrTxBroadcast, "check the transaction on chain: %s", uuid)
+	}
+
+	slog.Warn("releasing work item", "neighborhood", neighborhood, "uuid", uuid)
+	item.Hold = nil
+	if err := SaveState(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SetUnknownOutcome records that the migration transaction of the work item was broadcast but its outcome is unknown,
+// and saves the state locally. The remote work item is left untouched, i.e., it stays in the migrating state.
+func SetUnknownOutcome(neighborhood uint64, uuid string, txHash string, reason string) error {
+	item, err := LoadState(neighborhood, uuid)
+	if err != nil {
+		return err
+	}
+
+	slog.Error("transaction outcome unknown", "neighborhood", neighborhood, "uuid", item.UUID, "hash", txHash, "reason", reason)
+	item.UnknownOutcome = &UnknownOutcome{TxHash: txHash, Reason: reason, Date: time.Now().UTC()}
+	return SaveState(item)
+}
+
+// SetBroadcast records the hash of the migration transaction of the work item, the destination it was broadcast to and
+// the bank account sequence it was signed with, as soon as it is broadcast, and saves the state locally. The remote work
+// item is left untouched, i.e., it stays in the migrating state.
+func SetBroadcast(item *WorkItem, destination string, txHash string, sequence uint64) error {
+	slog.Info("transaction broadcast", "neighborhood", item.Neighborhood, "uuid", item.UUID, "destination", destination, "hash", txHash, "sequence", sequence)
+	item.Broadcast = &Broadcast{TxHash: txHash, Destination: destination, Sequence: &sequence, Date: time.Now().UTC()}
+	return SaveState(item)
+}
+
+// ClearBroadcast clears the unknown outcome and the broadcast transaction of the local work item, once an operator
+// verified the transaction did not land. The work item can be migrated again.
+func ClearBroadcast(neighborhood uint64, uuid string) (*WorkItem, error) {
+	item, err := LoadState(neighborhood, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if item.UnknownOutcome == nil && item.Broadcast == nil {
+		return nil, errors.WithMessagef(ErrNotEscalated, "no transaction broadcast: %s", uuid)
+	}
+
+	slog.Warn("clearing broadcast transaction", "neighborhood", neighborhood, "uuid", uuid)
+	item.UnknownOutcome = nil
+	item.Broadcast = nil
+	if err := SaveState(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QuarantineDir is the directory holding the quarantined work items.
+// The work items are laid out by neighborhood as in the state directory.
+var QuarantineDir = "quarantine"
+
+// ErrNotQuarantined is returned when the work item is not in quarantine
+var ErrNotQuarantined = errors.New("work item not quarantined")
+
+// ErrStateExists is returned when restoring a work item whose local state file exists
+var ErrStateExists = errors.New("local state file exists")
+
+// QuarantinePath returns the path of the quarantined state file of the work item of the given neighborhood
+func QuarantinePath(neighborhood uint64, uuid string) string {
+	return filepath.Join(QuarantineDir, StatePath(neighborhood, uuid))
+}
+
+// QuarantineWorkItem moves the local state of the failed work item to the quarantine directory, recording why and
+// when it was quarantined. The remote work item is left untouched.
+func QuarantineWorkItem(item WorkItem, reason string) error {
+	slog.Warn("quarantining work item", "neighborhood", item.Neighborhood, "uuid", item.UUID, "reason", reason)
+	item.Quarantine = &Quarantine{Reason: reason, Date: time.Now().UTC()}
+	if err := saveStateFile(QuarantinePath(item.Neighborhood, item.UUID.String()), &item); err != nil {
+		return errors.WithMessage(err, "unable to quarantine work item")
+	}
+
+	if err := os.Remove(StatePath(item.Neighborhood, item.UUID.String())); err != nil && !os.IsNotExist(err) {
+		return errors.WithMessage(err, "unable to remove local state file")
+	}
+
+	return nil
+}
+
+// LoadQuarantined loads the quarantined work item of the given neighborhood
+func LoadQuarantined(neighborhood uint64, uuid string) (*WorkItem, error) {
+	path := QuarantinePath(neighborhood, uuid)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, errors.WithMessagef(ErrNotQuarantined, "neighborhood %d, uuid %s", neighborhood, uuid)
+	}
+	return loadStateFile(path, neighborhood, uuid)
+}
+
+// ListQuarantined loads every quarantined work item, oldest quarantine first
+func ListQuarantined() ([]WorkItem, error) {
+	var items []WorkItem
+	err := filepath.WalkDir(QuarantineDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == QuarantineDir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		neighborhood, err := quarantinedNeighborhood(path)
+		if err != nil {
+			slog.Warn("skipping unexpected file in quarantine", "path", path, "warning", err)
+			return nil
+		}
+		item, err := loadStateFile(path, neighborhood, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		items = append(items, *item)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to list quarantined work items")
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return quarantineDate(items[i]).Before(quarantineDate(items[j]))
+	})
+	return items, nil
+}
+
+// RestoreWorkItem moves the quarantined work item back to the state directory and clears its quarantine.
+// The status and the other fields of the work item are kept, e.g., a FAILED work item is not migrated until it is
+// claimed again. It refuses to overwrite an existing local state file, e.g., after the work item was claimed again.
+func RestoreWorkItem(neighborhood uint64, uuid string) (*WorkItem, error) {
+	item, err := LoadQuarantined(neighborhood, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(StatePath(neighborhood, uuid)); err == nil {
+		return nil, errors.WithMessagef(ErrStateExists, "neighborhood %d, uuid %s", neighborhood, uuid)
+	}
+
+	slog.Info("restoring work item", "neighborhood", neighborhood, "uuid", uuid)
+	item.Quarantine = nil
+	if err := SaveState(item); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(QuarantinePath(neighborhood, uuid)); err != nil {
+		return nil, errors.WithMessage(err, "unable to remove quarantined state file")
+	}
+
+	return item, nil
+}
+
+// PurgeQuarantined deletes the quarantined work item of the given neighborhood
+func PurgeQuarantined(neighborhood uint64, uuid string) error {
+	slog.Info("purging work item", "neighborhood", neighborhood, "uuid", uuid)
+	if err := os.Remove(QuarantinePath(neighborhood, uuid)); err != nil {
+		if os.IsNotExist(err) {
+			return errors.WithMessagef(ErrNotQuarantined, "neighborhood %d, uuid %s", neighborhood, uuid)
+		}
+		return errors.WithMessage(err, "unable to purge work item")
+	}
+	return nil
+}
+
+// quarantinedNeighborhood returns the neighborhood of a quarantined state file from its directory
+func quarantinedNeighborhood(path string) (uint64, error) {
+	dir, err := filepath.Rel(QuarantineDir, filepath.Dir(path))
+	if err != nil {
+		return 0, err
+	}
+	if dir == "." {
+		return 0, nil
+	}
+
+	id, ok := strings.CutPrefix(dir, "neighborhood-")
+	if !ok {
+		return 0, fmt.Errorf("unexpected directory: %s", dir)
+	}
+	return strconv.ParseUint(id, 10, 64)
+}
+
+// quarantineDate returns the date the work item was quarantined, the zero time if unknown
+func quarantineDate(item WorkItem) time.Time {
+	if item.Quarantine == nil {
+		return time.Time{}
+	}
+	return item.Quarantine.Date
+}
@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/store"
+)
+
+func TestQuarantineWorkItem(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	errStr := "amount must be greater than 0"
+	item := store.WorkItem{Status: store.FAILED, UUID: uuid.New(), Error: &errStr, Neighborhood: 2}
+	require.NoError(t, store.SaveState(&item))
+
+	require.NoError(t, store.QuarantineWorkItem(item, errStr))
+	require.NoFileExists(t, store.StatePath(2, item.UUID.String()))
+	require.FileExists(t, filepath.Join("quarantine", "neighborhood-2", item.UUID.String()+".json"))
+
+	quarantined, err := store.LoadQuarantined(2, item.UUID.String())
+	require.NoError(t, err)
+	require.True(t, item.Equal(*quarantined))
+	require.Equal(t, uint64(2), quarantined.Neighborhood)
+	require.NotNil(t, quarantined.Quarantine)
+	require.Equal(t, errStr, quarantined.Quarantine.Reason)
+	require.False(t, quarantined.Quarantine.Date.IsZero())
+
+	_, err = store.LoadQuarantined(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrNotQuarantined)
+}
+
+func TestListQuarantined(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// No quarantine directory
+	items, err := store.ListQuarantined()
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	first := store.WorkItem{Status: store.FAILED, UUID: uuid.New(), Neighborhood: 3}
+	second := store.WorkItem{Status: store.FAILED, UUID: uuid.New()}
+	require.NoError(t, store.QuarantineWorkItem(first, "first"))
+	require.NoError(t, store.QuarantineWorkItem(second, "second"))
+	require.NoError(t, os.WriteFile(filepath.Join("quarantine", "notes.txt"), []byte("ignored"), 0600))
+
+	items, err = store.ListQuarantined()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, first.UUID, items[0].UUID)
+	require.Equal(t, uint64(3), items[0].Neighborhood)
+	require.Equal(t, second.UUID, items[1].UUID)
+	require.Equal(t, uint64(0), items[1].Neighborhood)
+}
+
+func TestRestoreWorkItem(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := store.WorkItem{Status: store.FAILED, UUID: uuid.New()}
+	require.NoError(t, store.QuarantineWorkItem(item, "failed"))
+
+	// The work item was claimed again in the meantime
+	require.NoError(t, store.SaveState(&store.WorkItem{Status: store.CLAIMED, UUID: item.UUID}))
+	_, err := store.RestoreWorkItem(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrStateExists)
+	require.NoError(t, os.Remove(store.StatePath(0, item.UUID.String())))
+
+	restored, err := store.RestoreWorkItem(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Nil(t, restored.Quarantine)
+	require.NoFileExists(t, store.QuarantinePath(0, item.UUID.String()))
+
+	state, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.FAILED, state.Status)
+	require.Nil(t, state.Quarantine)
+
+	_, err = store.RestoreWorkItem(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrNotQuarantined)
+}
+
+func TestPurgeQuarantined(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := store.WorkItem{Status: store.FAILED, UUID: uuid.New()}
+	require.NoError(t, store.QuarantineWorkItem(item, "failed"))
+
+	require.NoError(t, store.PurgeQuarantined(0, item.UUID.String()))
+	require.NoFileExists(t, store.QuarantinePath(0, item.UUID.String()))
+	require.ErrorIs(t, store.PurgeQuarantined(0, item.UUID.String()), store.ErrNotQuarantined)
+}
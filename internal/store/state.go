@@ -1,15 +1,107 @@
 package store
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
+// StateVersion is the version of the local state file schema written by this binary.
+// Bump it and register an upgrade function in stateUpgrades whenever the persisted work item changes.
+const StateVersion uint = 2
+
+// ErrUnsupportedStateVersion is returned when a state file was written by a newer binary and strict mode is enabled
+var ErrUnsupportedStateVersion = errors.New("unsupported state file version")
+
+// StrictStateVersion refuses to load state files written with a version newer than StateVersion.
+// When disabled, such files are loaded on a best-effort basis and the fields unknown to this binary are dropped on the
+// next save.
+var StrictStateVersion = true
+
+// stateEnvelope is the versioned envelope of a local state file
+type stateEnvelope struct {
+	Version    uint            `json:"version"`
+	Item       json.RawMessage `json:"item,omitempty"`
+	KeyID      string          `json:"keyId,omitempty"`      // ID of the key encrypting the item, if any
+	Nonce      []byte          `json:"nonce,omitempty"`      // AES-GCM nonce
+	Ciphertext []byte          `json:"ciphertext,omitempty"` // Encrypted and authenticated item
+}
+
+// stateUpgrade upgrades a persisted work item from one version to the next
+type stateUpgrade func(item json.RawMessage) (json.RawMessage, error)
+
+// stateUpgrades maps a state file version to the function upgrading it to the next version
+var stateUpgrades = map[uint]stateUpgrade{
+	0: upgradeStateV0,
+	1: upgradeStateV1,
+}
+
+// upgradeStateV0 upgrades a legacy state file, i.e., a bare work item without envelope.
+// The work item schema is unchanged, only the envelope is added.
+func upgradeStateV0(item json.RawMessage) (json.RawMessage, error) {
+	return item, nil
+}
+
+// upgradeStateV1 upgrades a version 1 state file.
+// Version 2 adds the broadcast and quarantine records, which are absent from a version 1 work item, so the work item is
+// unchanged. The hold and unknown outcome records were already written by version 1.
+func upgradeStateV1(item json.RawMessage) (json.RawMessage, error) {
+	return item, nil
+}
+
+// StatePath returns the path of the local state file of the work item of the given neighborhood.
+// The work items of the default neighborhood, 0, are stored in the current directory, as before multi-neighborhood
+// processing; the work items of the other neighborhoods are stored in a `neighborhood-<ID>` directory so work items
+// sharing a UUID in different neighborhoods never share a state file.
+func StatePath(neighborhood uint64, uuid string) string {
+	if neighborhood == 0 {
+		return fmt.Sprintf("%s.json", uuid)
+	}
+	return filepath.Join(fmt.Sprintf("neighborhood-%d", neighborhood), fmt.Sprintf("%s.json", uuid))
+}
+
+// ListStates returns the UUIDs of the work items of the neighborhood having a local state file.
+// The files which are not named after a UUID, e.g., the configuration file, are ignored.
+func ListStates(neighborhood uint64) ([]string, error) {
+	dir := filepath.Dir(StatePath(neighborhood, uuid.Nil.String()))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state directory: %w", err)
+	}
+
+	var uuids []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		if _, err := uuid.Parse(name); err != nil {
+			continue
+		}
+		uuids = append(uuids, name)
+	}
+
+	return uuids, nil
+}
+
 func SaveState(item *WorkItem) error {
 	slog.Debug("saving state", "item", item)
+	return saveStateFile(StatePath(item.Neighborhood, item.UUID.String()), item)
+}
+
+// saveStateFile writes the work item to the state file at the given path
+func saveStateFile(path string, item *WorkItem) error {
 
 	// Convert the WorkItem to JSON
 	data, err := json.Marshal(item)
@@ -17,27 +109,57 @@ func SaveState(item *WorkItem) error {
 		return fmt.Errorf("failed to marshal work item: %w", err)
 	}
 
-	// Create a new file with the UUID of the WorkItem as the filename
-	file, err := os.Create(fmt.Sprintf("%s.json", item.UUID))
+	// Wrap the WorkItem in a versioned envelope, encrypted if a key is configured
+	envelope := stateEnvelope{Version: StateVersion, Item: data}
+	if StateEncryption != nil {
+		if err := StateEncryption.seal(&envelope, item.Neighborhood, item.UUID.String()); err != nil {
+			return err
+		}
+	}
+	data, err = json.Marshal(envelope)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return fmt.Errorf("failed to marshal state envelope: %w", err)
 	}
-	defer file.Close()
 
-	// Write the JSON data to the file
-	_, err = file.Write(data)
+	// Write the state file atomically, through a temporary file in the same directory, so a crash never leaves a
+	// truncated state file behind
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
 		return fmt.Errorf("failed to write to file: %w", err)
 	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
 
 	return nil
 }
 
-func LoadState(uuid string) (*WorkItem, error) {
-	slog.Debug("loading state", "uuid", uuid)
+func LoadState(neighborhood uint64, uuid string) (*WorkItem, error) {
+	slog.Debug("loading state", "neighborhood", neighborhood, "uuid", uuid)
+	return loadStateFile(StatePath(neighborhood, uuid), neighborhood, uuid)
+}
 
-	// Open the file with the UUID as the filename
-	file, err := os.Open(fmt.Sprintf("%s.json", uuid))
+// loadStateFile reads the work item of the neighborhood from the state file at the given path
+func loadStateFile(path string, neighborhood uint64, uuid string) (*WorkItem, error) {
+	// Open the file with the UUID as the filename, in the directory of the neighborhood
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -49,12 +171,73 @@ func LoadState(uuid string) (*WorkItem, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// Upgrade the persisted WorkItem to the current version
+	data, reseal, err := upgradeState(data, neighborhood, uuid)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert the JSON data to a WorkItem
 	var item WorkItem
 	err = json.Unmarshal(data, &item)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal work item: %w", err)
 	}
+	item.Neighborhood = neighborhood
+
+	// Seal the file with the current key and additional data, so it is accepted unsealed only once
+	if reseal {
+		if err := saveStateFile(path, &item); err != nil {
+			slog.Warn("Unable to seal state file", "path", path, "warning", err)
+		}
+	}
 
 	return &item, nil
 }
+
+// upgradeState unwraps and decrypts the state file envelope and upgrades the work item to StateVersion.
+// A file without envelope is a legacy, version 0, state file.
+// It returns true if the file must be sealed again with the current key, e.g., it is stored in plaintext.
+func upgradeState(data []byte, neighborhood uint64, uuid string) (json.RawMessage, bool, error) {
+	var envelope stateEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal state file: %w", err)
+	}
+	if envelope.Ciphertext == nil && (envelope.Item == nil || bytes.Equal(envelope.Item, []byte("null"))) {
+		envelope = stateEnvelope{Version: 0, Item: data}
+	}
+
+	var reseal bool
+	if StateEncryption != nil {
+		var err error
+		if reseal, err = StateEncryption.open(&envelope, neighborhood, uuid); err != nil {
+			return nil, false, err
+		}
+	} else if envelope.Ciphertext != nil {
+		return nil, false, errors.WithMessage(ErrStateIntegrity, "state file is encrypted but no state key is configured")
+	}
+
+	if envelope.Version > StateVersion {
+		if StrictStateVersion {
+			return nil, false, errors.WithMessagef(ErrUnsupportedStateVersion, "version %d is newer than %d, upgrade the migrator", envelope.Version, StateVersion)
+		}
+		slog.Warn("Loading state file written by a newer migrator", "version", envelope.Version, "supportedVersion", StateVersion)
+		return envelope.Item, reseal, nil
+	}
+
+	item := envelope.Item
+	for version := envelope.Version; version < StateVersion; version++ {
+		upgrade, ok := stateUpgrades[version]
+		if !ok {
+			return nil, false, errors.WithMessagef(ErrUnsupportedStateVersion, "no upgrade from version %d", version)
+		}
+
+		var err error
+		if item, err = upgrade(item); err != nil {
+			return nil, false, fmt.Errorf("failed to upgrade state file from version %d: %w", version, err)
+		}
+		slog.Debug("upgraded state file", "from", version, "to", version+1)
+	}
+
+	return item, reseal, nil
+}
@@ -1,7 +1,10 @@
 package store_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/uuid"
@@ -26,7 +29,224 @@ func TestSaveLoadState(t *testing.T) {
 	err := store.SaveState(item)
 	require.NoError(t, err)
 
-	otherItem, err := store.LoadState(someUUID.String())
+	otherItem, err := store.LoadState(0, someUUID.String())
 	require.NoError(t, err)
 	require.Equal(t, item, otherItem)
 }
+
+func TestSaveLoadState_Neighborhoods(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Work items of different neighborhoods sharing a UUID do not share a state file
+	someUUID := uuid.New()
+	require.NoError(t, store.SaveState(&store.WorkItem{Status: store.CLAIMED, UUID: someUUID}))
+	require.NoError(t, store.SaveState(&store.WorkItem{Status: store.MIGRATING, UUID: someUUID, Neighborhood: 2}))
+	require.FileExists(t, someUUID.String()+".json")
+	require.FileExists(t, filepath.Join("neighborhood-2", someUUID.String()+".json"))
+
+	item, err := store.LoadState(0, someUUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.CLAIMED, item.Status)
+	require.Equal(t, uint64(0), item.Neighborhood)
+
+	item, err = store.LoadState(2, someUUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.MIGRATING, item.Status)
+	require.Equal(t, uint64(2), item.Neighborhood)
+
+	_, err = store.LoadState(3, someUUID.String())
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSetUnknownOutcome(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &store.WorkItem{Status: store.MIGRATING, UUID: uuid.New()}
+	require.NoError(t, store.SaveState(item))
+
+	err := store.SetUnknownOutcome(0, item.UUID.String(), "hash", "timed out")
+	require.NoError(t, err)
+
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.MIGRATING, otherItem.Status)
+	require.NotNil(t, otherItem.UnknownOutcome)
+	require.Equal(t, "hash", otherItem.UnknownOutcome.TxHash)
+	require.Equal(t, "timed out", otherItem.UnknownOutcome.Reason)
+	require.True(t, item.Equal(*otherItem))
+}
+
+func TestSaveState_Envelope(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New()}
+	require.NoError(t, store.SaveState(item))
+
+	data, err := os.ReadFile(item.UUID.String() + ".json")
+	require.NoError(t, err)
+
+	var envelope struct {
+		Version uint           `json:"version"`
+		Item    store.WorkItem `json:"item"`
+	}
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	require.Equal(t, store.StateVersion, envelope.Version)
+	require.True(t, item.Equal(envelope.Item))
+}
+
+func TestLoadState_Legacy(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	someUUID := uuid.New()
+	legacy := fmt.Sprintf(`{"id":1,"status":2,"createdDate":"2024-03-01T16:54:02.651Z","uuid":%q,"manyHash":"hash","manyFrom":"from","manyTo":"to","amount":"1","symbol":"sym"}`, someUUID)
+	require.NoError(t, os.WriteFile(someUUID.String()+".json", []byte(legacy), 0600))
+
+	item, err := store.LoadState(0, someUUID.String())
+	require.NoError(t, err)
+	require.Equal(t, someUUID, item.UUID)
+	require.Equal(t, store.CLAIMED, item.Status)
+	require.Equal(t, "hash", item.ManyHash)
+
+	// The upgraded item is saved with the current version
+	require.NoError(t, store.SaveState(item))
+	otherItem, err := store.LoadState(0, someUUID.String())
+	require.NoError(t, err)
+	require.True(t, item.Equal(*otherItem))
+}
+
+func TestLoadState_V1(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	someUUID := uuid.New()
+	v1 := fmt.Sprintf(`{"version":1,"item":{"status":2,"uuid":%q,"manyHash":"hash"}}`, someUUID)
+	require.NoError(t, os.WriteFile(someUUID.String()+".json", []byte(v1), 0600))
+
+	item, err := store.LoadState(0, someUUID.String())
+	require.NoError(t, err)
+	require.Equal(t, someUUID, item.UUID)
+	require.Equal(t, store.CLAIMED, item.Status)
+	require.Equal(t, "hash", item.ManyHash)
+}
+
+func TestSaveState_Atomic(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New()}
+	require.NoError(t, store.SaveState(item))
+	item.Status = store.MIGRATING
+	require.NoError(t, store.SaveState(item))
+
+	// Only the state file is left behind, without temporary file
+	entries, err := os.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, item.UUID.String()+".json", entries[0].Name())
+
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.MIGRATING, otherItem.Status)
+}
+
+func TestLoadState_FutureVersion(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	someUUID := uuid.New()
+	future := fmt.Sprintf(`{"version":%d,"item":{"status":2,"uuid":%q,"newField":"value"}}`, store.StateVersion+1, someUUID)
+	require.NoError(t, os.WriteFile(someUUID.String()+".json", []byte(future), 0600))
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := store.LoadState(0, someUUID.String())
+		require.ErrorIs(t, err, store.ErrUnsupportedStateVersion)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		store.StrictStateVersion = false
+		t.Cleanup(func() { store.StrictStateVersion = true })
+
+		item, err := store.LoadState(0, someUUID.String())
+		require.NoError(t, err)
+		require.Equal(t, someUUID, item.UUID)
+		require.Equal(t, store.CLAIMED, item.Status)
+	})
+}
+
+func TestSetBroadcast(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &store.WorkItem{Status: store.MIGRATING, UUID: uuid.New()}
+	require.NoError(t, store.SaveState(item))
+
+	require.NoError(t, store.SetBroadcast(item, "osmosis", "hash", 7))
+	require.NotNil(t, item.Broadcast)
+
+	otherItem, err := store.LoadState(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Equal(t, store.MIGRATING, otherItem.Status)
+	require.NotNil(t, otherItem.Broadcast)
+	require.Equal(t, "hash", otherItem.Broadcast.TxHash)
+	require.Equal(t, "osmosis", otherItem.Broadcast.Destination)
+	require.Equal(t, uint64(7), *otherItem.Broadcast.Sequence)
+	require.True(t, item.Equal(*otherItem))
+
+	// A held work item with a broadcast transaction is not released
+	require.NoError(t, store.HoldWorkItem(*otherItem, "not recorded"))
+	_, err = store.ReleaseWorkItem(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrTxBroadcast)
+
+	otherItem, err = store.ClearBroadcast(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Nil(t, otherItem.Broadcast)
+	_, err = store.ClearBroadcast(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrNotEscalated)
+
+	otherItem, err = store.ReleaseWorkItem(0, item.UUID.String())
+	require.NoError(t, err)
+	require.Nil(t, otherItem.Hold)
+	_, err = store.ReleaseWorkItem(0, item.UUID.String())
+	require.ErrorIs(t, err, store.ErrNotEscalated)
+}
+
+func TestListStates(t *testing.T) {
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// No neighborhood directory
+	uuids, err := store.ListStates(2)
+	require.NoError(t, err)
+	require.Empty(t, uuids)
+
+	item := &store.WorkItem{Status: store.CLAIMED, UUID: uuid.New()}
+	require.NoError(t, store.SaveState(item))
+	require.NoError(t, store.SaveState(&store.WorkItem{Status: store.CLAIMED, UUID: uuid.New(), Neighborhood: 2}))
+	require.NoError(t, os.WriteFile("config.json", []byte("{}"), 0600))
+	require.NoError(t, store.QuarantineWorkItem(store.WorkItem{Status: store.FAILED, UUID: uuid.New()}, "failed"))
+
+	uuids, err = store.ListStates(0)
+	require.NoError(t, err)
+	require.Equal(t, []string{item.UUID.String()}, uuids)
+
+	uuids, err = store.ListStates(2)
+	require.NoError(t, err)
+	require.Len(t, uuids, 1)
+}
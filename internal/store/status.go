@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type WorkItemStatus int
+
+const (
+	CREATED WorkItemStatus = iota + 1
+	CLAIMED
+	MIGRATING
+	COMPLETED
+	FAILED
+)
+
+var statusNames = [...]string{"created", "claimed", "migrating", "completed", "failed"}
+
+// transitions are the legal work item status transitions
+//
+//	CREATED -> CLAIMED -> MIGRATING -> COMPLETED
+//	              |           |
+//	              +---------> FAILED -> CLAIMED (forced re-claim)
+var transitions = map[WorkItemStatus][]WorkItemStatus{
+	CREATED:   {CLAIMED},
+	CLAIMED:   {MIGRATING, FAILED},
+	MIGRATING: {COMPLETED, FAILED},
+	COMPLETED: {},
+	FAILED:    {CLAIMED},
+}
+
+// ErrInvalidStatus is returned when a work item status is out of range
+var ErrInvalidStatus = errors.New("invalid work item status")
+
+// ErrInvalidTransition is returned when a work item status transition is not allowed
+var ErrInvalidTransition = errors.New("invalid work item status transition")
+
+// IsValid returns true if the status is a known work item status
+func (s WorkItemStatus) IsValid() bool {
+	return s >= CREATED && s <= FAILED
+}
+
+func (s WorkItemStatus) String() string {
+	if !s.IsValid() {
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+	return statusNames[s-1]
+}
+
+// EnumIndex returns the enum index of a LocalWorkItemStatus.
+func (s WorkItemStatus) EnumIndex() int64 {
+	return int64(s)
+}
+
+// CanTransitionTo returns true if the transition from `s` to `next` is legal
+func (s WorkItemStatus) CanTransitionTo(next WorkItemStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes the status as its enum index, as expected by the remote database
+func (s WorkItemStatus) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, errors.WithMessagef(ErrInvalidStatus, "%d", int(s))
+	}
+	return json.Marshal(int(s))
+}
+
+// UnmarshalJSON decodes the status from its enum index or its name
+func (s *WorkItemStatus) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	status, err := ParseWorkItemStatus(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+// ParseWorkItemStatus parses a status name or enum index
+func ParseWorkItemStatus(s string) (WorkItemStatus, error) {
+	for i, name := range statusNames {
+		if name == s {
+			return WorkItemStatus(i + 1), nil
+		}
+	}
+
+	index, err := strconv.Atoi(s)
+	if err != nil || !WorkItemStatus(index).IsValid() {
+		return 0, errors.WithMessagef(ErrInvalidStatus, "%s", s)
+	}
+	return WorkItemStatus(index), nil
+}
+
+// Transition records a work item status change
+type Transition struct {
+	From WorkItemStatus `json:"from"`
+	To   WorkItemStatus `json:"to"`
+	Date time.Time      `json:"date"`
+}
+
+// Transition moves the work item to the `next` status and records the transition.
+// The work item is left untouched if the transition is not legal.
+func (wi *WorkItem) Transition(next WorkItemStatus) error {
+	if !wi.Status.CanTransitionTo(next) {
+		return errors.WithMessagef(ErrInvalidTransition, "%s -> %s", wi.Status, next)
+	}
+
+	wi.Transitions = append(wi.Transitions, Transition{From: wi.Status, To: next, Date: time.Now().UTC()})
+	wi.Status = next
+	return nil
+}
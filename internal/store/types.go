@@ -12,34 +12,55 @@ type Token struct {
 	AccessToken string `json:"access_token"`
 }
 
-type WorkItemStatus int
-
-const (
-	CREATED WorkItemStatus = iota + 1
-	CLAIMED
-	MIGRATING
-	COMPLETED
-	FAILED
-)
+type WorkItem struct {
+	Status           WorkItemStatus  `json:"status"`
+	CreatedDate      *time.Time      `json:"createdDate"`
+	UUID             uuid.UUID       `json:"uuid"`
+	ManyHash         string          `json:"manyHash"`
+	ManifestAddress  string          `json:"manifestAddress"`
+	ManifestHash     *string         `json:"manifestHash"`
+	ManifestDatetime *time.Time      `json:"manifestDatetime"`
+	Error            *string         `json:"error"`
+	Hold             *Hold           `json:"hold,omitempty"`           // Local state only
+	UnknownOutcome   *UnknownOutcome `json:"unknownOutcome,omitempty"` // Local state only
+	GasUsed          *uint64         `json:"gasUsed,omitempty"`        // Local state only
+	Fee              *string         `json:"fee,omitempty"`            // Local state only
+	Transitions      []Transition    `json:"transitions,omitempty"`    // Local state only
+	Neighborhood     uint64          `json:"neighborhood,omitempty"`   // Local state only
+	Quarantine       *Quarantine     `json:"quarantine,omitempty"`     // Local state only
+	Broadcast        *Broadcast      `json:"broadcast,omitempty"`      // Local state only
+}
 
-func (s WorkItemStatus) String() string {
-	return [...]string{"created", "claimed", "migrating", "completed", "failed"}[s-1]
+// Hold records why a work item is held, e.g., a denylist match.
+// A held work item is not migrated until the hold is released by an operator.
+type Hold struct {
+	Reason string    `json:"reason"`
+	Date   time.Time `json:"date"`
 }
 
-// EnumIndex returns the enum index of a LocalWorkItemStatus.
-func (s WorkItemStatus) EnumIndex() int64 {
-	return int64(s)
+// UnknownOutcome records a migration transaction that was broadcast but never confirmed, e.g., the node timed out.
+// The work item is not migrated again until an operator checks the transaction on chain.
+type UnknownOutcome struct {
+	TxHash string    `json:"txHash,omitempty"`
+	Reason string    `json:"reason"`
+	Date   time.Time `json:"date"`
 }
 
-type WorkItem struct {
-	Status           WorkItemStatus `json:"status"`
-	CreatedDate      *time.Time     `json:"createdDate"`
-	UUID             uuid.UUID      `json:"uuid"`
-	ManyHash         string         `json:"manyHash"`
-	ManifestAddress  string         `json:"manifestAddress"`
-	ManifestHash     *string        `json:"manifestHash"`
-	ManifestDatetime *time.Time     `json:"manifestDatetime"`
-	Error            *string        `json:"error"`
+// Broadcast records the migration transaction as soon as it is broadcast, before it is confirmed.
+// The work item is not migrated again until an operator checks the transaction on chain, so a migration interrupted
+// after the broadcast, e.g., the migrator was killed, never sends the tokens twice.
+type Broadcast struct {
+	TxHash      string    `json:"txHash"`
+	Destination string    `json:"destination,omitempty"` // Named destination the transaction was broadcast to, if any
+	Sequence    *uint64   `json:"sequence,omitempty"`    // Bank account sequence the transaction was signed with, if known
+	Date        time.Time `json:"date"`
+}
+
+// Quarantine records why and when a failed work item was quarantined.
+// A quarantined work item is moved out of the state directory until an operator restores or purges it.
+type Quarantine struct {
+	Reason string    `json:"reason"`
+	Date   time.Time `json:"date"`
 }
 
 // Equal returns true if the WorkItem is equal to the other WorkItem
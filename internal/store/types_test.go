@@ -1,6 +1,8 @@
 package store_test
 
 import (
+	"encoding/json"
+	"strconv"
 	"testing"
 	"time"
 
@@ -43,3 +45,72 @@ func TestTypes_WorkItem(t *testing.T) {
 	require.True(t, wi.Equal(wi))
 	require.False(t, wi.Equal(store.WorkItem{}))
 }
+
+func TestTypes_WorkItemStatusString(t *testing.T) {
+	require.Equal(t, "unknown(0)", store.WorkItemStatus(0).String())
+	require.Equal(t, "unknown(6)", store.WorkItemStatus(6).String())
+}
+
+func TestTypes_WorkItemStatusJSON(t *testing.T) {
+	for _, s := range []store.WorkItemStatus{store.CREATED, store.CLAIMED, store.MIGRATING, store.COMPLETED, store.FAILED} {
+		data, err := json.Marshal(s)
+		require.NoError(t, err)
+		require.Equal(t, strconv.FormatInt(s.EnumIndex(), 10), string(data))
+
+		var other store.WorkItemStatus
+		require.NoError(t, json.Unmarshal(data, &other))
+		require.Equal(t, s, other)
+
+		require.NoError(t, json.Unmarshal([]byte(`"`+s.String()+`"`), &other))
+		require.Equal(t, s, other)
+	}
+
+	_, err := json.Marshal(store.WorkItemStatus(0))
+	require.ErrorIs(t, err, store.ErrInvalidStatus)
+
+	var s store.WorkItemStatus
+	for _, data := range []string{`0`, `6`, `"foo"`, `{}`} {
+		require.ErrorIs(t, json.Unmarshal([]byte(data), &s), store.ErrInvalidStatus, data)
+	}
+}
+
+func TestTypes_WorkItemTransition(t *testing.T) {
+	tests := []struct {
+		from  store.WorkItemStatus
+		to    store.WorkItemStatus
+		legal bool
+	}{
+		{store.CREATED, store.CLAIMED, true},
+		{store.CLAIMED, store.MIGRATING, true},
+		{store.CLAIMED, store.FAILED, true},
+		{store.MIGRATING, store.COMPLETED, true},
+		{store.MIGRATING, store.FAILED, true},
+		{store.FAILED, store.CLAIMED, true},
+		{store.CREATED, store.MIGRATING, false},
+		{store.CLAIMED, store.COMPLETED, false},
+		{store.COMPLETED, store.MIGRATING, false},
+		{store.COMPLETED, store.FAILED, false},
+		{store.FAILED, store.COMPLETED, false},
+		{store.WorkItemStatus(0), store.CLAIMED, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+"_"+tt.to.String(), func(t *testing.T) {
+			wi := store.WorkItem{Status: tt.from}
+			err := wi.Transition(tt.to)
+			if !tt.legal {
+				require.ErrorIs(t, err, store.ErrInvalidTransition)
+				require.Equal(t, tt.from, wi.Status)
+				require.Empty(t, wi.Transitions)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.to, wi.Status)
+			require.Len(t, wi.Transitions, 1)
+			require.Equal(t, tt.from, wi.Transitions[0].From)
+			require.Equal(t, tt.to, wi.Transitions[0].To)
+			require.False(t, wi.Transitions[0].Date.IsZero())
+		})
+	}
+}
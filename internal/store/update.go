@@ -1,19 +1,27 @@
 package store
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
 	"github.com/liftedinit/mfx-migrator/internal/utils"
 )
 
 // UpdateWorkItemAndSaveState updates a work item in the remote database and saves the state locally.
-func UpdateWorkItemAndSaveState(r *resty.Client, item WorkItem) error {
+func UpdateWorkItemAndSaveState(ctx context.Context, r *resty.Client, item WorkItem) (err error) {
+	ctx, span := tracing.Start(ctx, "store.UpdateWorkItemAndSaveState",
+		tracing.WorkItem(item.UUID.String()),
+		attribute.String("migrator.work_item.status", item.Status.String()),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	// 1. Update the work item
-	if err := updateWorkItem(r, item); err != nil {
+	if err := updateWorkItem(ctx, r, item); err != nil {
 		return errors.WithMessage(err, "error updating remote work item")
 	}
 
@@ -26,7 +34,7 @@ func UpdateWorkItemAndSaveState(r *resty.Client, item WorkItem) error {
 }
 
 // updateWorkItem updates a work item in the remote database.
-func updateWorkItem(r *resty.Client, item WorkItem) error {
+func updateWorkItem(ctx context.Context, r *resty.Client, item WorkItem) error {
 	// 1. Create an update request
 	updateRequest := WorkItemUpdateRequest{
 		Status:           item.Status,
@@ -37,27 +45,19 @@ func updateWorkItem(r *resty.Client, item WorkItem) error {
 
 	// 2. Send the update request
 	req := r.R().
+		SetContext(ctx).
 		SetPathParam("uuid", item.UUID.String()).
 		SetBody(&updateRequest).
 		SetResult(&WorkItemUpdateResponse{})
 	response, err := req.Put("neighborhoods/{neighborhood}/migrations/{uuid}")
-	if err != nil {
-		return errors.WithMessage(err, "error updating work item")
-	}
-
-	if response == nil {
-		return fmt.Errorf("no response returned when claiming work item: %s", item.UUID)
-	}
-
-	statusCode := response.StatusCode()
-	if statusCode != http.StatusOK {
-		return fmt.Errorf("response status code: %d", statusCode)
+	if err := checkResponse(response, err); err != nil {
+		return errors.WithMessagef(err, "error updating work item: %s", item.UUID)
 	}
 
 	// 3. Unmarshal the update response
 	updateResponse := response.Result().(*WorkItemUpdateResponse)
 	if updateResponse == nil {
-		return fmt.Errorf("error unmarshalling update response")
+		return utils.Mark(fmt.Errorf("error unmarshalling update response"), ErrUnexpectedResponse)
 	}
 
 	// 4. Validate the work item was updated
@@ -65,7 +65,7 @@ func updateWorkItem(r *resty.Client, item WorkItem) error {
 		utils.EqualTimePtr(updateResponse.ManifestDatetime, item.ManifestDatetime) &&
 		utils.EqualStringPtr(updateResponse.ManifestHash, item.ManifestHash) &&
 		utils.EqualStringPtr(updateResponse.Error, item.Error)) {
-		return fmt.Errorf("work item not updated: %v", item)
+		return utils.Mark(fmt.Errorf("work item not updated: %v", item), ErrUnexpectedResponse)
 	}
 
 	return nil
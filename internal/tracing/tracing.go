@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+)
+
+const (
+	ServiceName = "mfx-migrator"
+	tracerName  = "github.com/liftedinit/mfx-migrator"
+)
+
+// WorkItemUUIDKey is the span attribute holding the UUID of the work item being processed
+const WorkItemUUIDKey = attribute.Key("migrator.work_item.uuid")
+
+// WorkItem returns the work item UUID span attribute
+func WorkItem(uuid string) attribute.KeyValue {
+	return WorkItemUUIDKey.String(uuid)
+}
+
+// NeighborhoodKey is the span attribute holding the ID of the neighborhood of the work item being processed
+const NeighborhoodKey = attribute.Key("migrator.neighborhood")
+
+// Neighborhood returns the neighborhood ID span attribute
+func Neighborhood(id uint64) attribute.KeyValue {
+	return NeighborhoodKey.Int64(int64(id))
+}
+
+// Start starts a span with the given name and attributes, child of the span in the context, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Setup installs the global tracer provider exporting spans to the configured exporter.
+// It returns the function flushing the pending spans and shutting down the provider.
+// Nothing is installed when the exporter is `none`.
+func Setup(ctx context.Context, c config.TracingConfig, version string) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("invalid trace exporter: %s", c.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", c.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(ServiceName), semconv.ServiceVersion(version)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
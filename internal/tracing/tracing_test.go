@@ -0,0 +1,58 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/tracing"
+)
+
+func TestStartEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.WorkItem("some-uuid"))
+	_, child := tracing.Start(ctx, "child")
+	tracing.End(child, errors.New("boom"))
+	tracing.End(parent, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Equal(t, "boom", spans[0].Status().Description)
+	require.Len(t, spans[0].Events(), 1) // The recorded error
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	require.Equal(t, "parent", spans[1].Name())
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+	require.Contains(t, spans[1].Attributes(), tracing.WorkItemUUIDKey.String("some-uuid"))
+}
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	ctx := context.Background()
+
+	shutdown, err := tracing.Setup(ctx, config.TracingConfig{Exporter: "none"}, "dev")
+	require.NoError(t, err)
+	require.NoError(t, shutdown(ctx))
+
+	shutdown, err = tracing.Setup(ctx, config.TracingConfig{Exporter: "otlp", Endpoint: "http://localhost:4318", Insecure: true}, "dev")
+	require.NoError(t, err)
+	_, isSdk := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	require.True(t, isSdk)
+	require.NoError(t, shutdown(ctx)) // No span to flush
+
+	_, err = tracing.Setup(ctx, config.TracingConfig{Exporter: "foo"}, "dev")
+	require.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "net/http"
+
+// markedError is an error matching a sentinel error while keeping its original message
+type markedError struct {
+	err      error
+	sentinel error
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+// Mark returns an error with the message of `err` that also matches `sentinel` using `errors.Is`
+func Mark(err error, sentinel error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{err: err, sentinel: sentinel}
+}
+
+// IsTemporaryStatus returns true if the HTTP status code denotes a temporary failure that can be retried
+func IsTemporaryStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,32 @@
+package utils_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+func TestMark(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	cause := errors.New("cause")
+
+	err := utils.Mark(fmt.Errorf("some error: %w", cause), sentinel)
+	require.EqualError(t, err, "some error: cause")
+	require.ErrorIs(t, err, sentinel)
+	require.ErrorIs(t, err, cause)
+	require.NoError(t, utils.Mark(nil, sentinel))
+}
+
+func TestIsTemporaryStatus(t *testing.T) {
+	for _, code := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		require.True(t, utils.IsTemporaryStatus(code), code)
+	}
+	for _, code := range []int{http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		require.False(t, utils.IsTemporaryStatus(code), code)
+	}
+}
@@ -2,5 +2,8 @@ package utils
 
 // TokenInfo represents the destination token information for the migration
 type TokenInfo struct {
-	Denom string
+	Denom       string
+	GasLimit    uint64 `mapstructure:"gas-limit"`   // Fixed gas limit used when the transaction simulation fails (optional)
+	LowBalance  uint64 `mapstructure:"low-balance"` // Bank balance below which a notification is sent (optional)
+	Destination string `mapstructure:"destination"` // Name of the destination the token is sent from, top-level if empty (optional)
 }
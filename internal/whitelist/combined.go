@@ -0,0 +1,28 @@
+package whitelist
+
+import "context"
+
+// CombinedProvider requires every provider to allow the address.
+// A combined provider without providers allows no address.
+type CombinedProvider struct {
+	providers []Provider
+}
+
+func NewCombinedProvider(providers ...Provider) *CombinedProvider {
+	return &CombinedProvider{providers: providers}
+}
+
+func (p *CombinedProvider) IsAllowed(ctx context.Context, address string) (bool, error) {
+	if len(p.providers) == 0 {
+		return false, nil
+	}
+
+	for _, provider := range p.providers {
+		allowed, err := provider.IsAllowed(ctx, address)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
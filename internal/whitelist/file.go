@@ -0,0 +1,74 @@
+package whitelist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AllowList is the content of a signed local allowlist file.
+//
+// The signature is the base64 encoded ed25519 signature of the addresses, joined by a newline.
+type AllowList struct {
+	Addresses []string `json:"addresses"`
+	Signature string   `json:"signature"`
+}
+
+// FileProvider verifies the address using a signed local allowlist file
+type FileProvider struct {
+	allowed map[string]struct{}
+}
+
+// NewFileProvider loads the allowlist file and verifies its signature using the hex encoded ed25519 public key
+func NewFileProvider(path string, publicKey string) (*FileProvider, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid allowlist public key: %s", publicKey)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowlist file: %w", err)
+	}
+
+	var list AllowList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal allowlist file: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(list.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowlist signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(key, allowListMessage(list.Addresses), signature) {
+		return nil, fmt.Errorf("invalid allowlist signature: %s", path)
+	}
+
+	allowed := make(map[string]struct{}, len(list.Addresses))
+	for _, address := range list.Addresses {
+		allowed[address] = struct{}{}
+	}
+
+	return &FileProvider{allowed: allowed}, nil
+}
+
+func (p *FileProvider) IsAllowed(_ context.Context, address string) (bool, error) {
+	_, ok := p.allowed[address]
+	return ok, nil
+}
+
+// NewSignedAllowList creates an allowlist signed with the given ed25519 private key
+func NewSignedAllowList(addresses []string, privateKey ed25519.PrivateKey) AllowList {
+	signature := ed25519.Sign(privateKey, allowListMessage(addresses))
+	return AllowList{Addresses: addresses, Signature: base64.StdEncoding.EncodeToString(signature)}
+}
+
+func allowListMessage(addresses []string) []byte {
+	return []byte(strings.Join(addresses, "\n"))
+}
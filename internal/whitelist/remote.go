@@ -0,0 +1,170 @@
+package whitelist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+type cacheEntry struct {
+	Allowed bool      `json:"allowed"`
+	Expires time.Time `json:"expires"`
+}
+
+// RemoteProvider verifies the address using the remote database whitelist endpoint.
+// Results are cached for `ttl`. The cache is persisted to `cacheFile`, if set, so it survives across runs.
+// The cache file must only be accessible by its owner, and the entries loaded from it expire at most `ttl` after loading.
+type RemoteProvider struct {
+	r         *resty.Client
+	ttl       time.Duration
+	cacheFile string
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewRemoteProvider(r *resty.Client, ttl time.Duration, cacheFile string) *RemoteProvider {
+	p := &RemoteProvider{r: r, ttl: ttl, cacheFile: cacheFile, cache: map[string]cacheEntry{}}
+	if err := p.loadCache(); err != nil {
+		slog.Warn("unable to load whitelist cache, continuing", "warning", err)
+	}
+	return p
+}
+
+func (p *RemoteProvider) IsAllowed(ctx context.Context, address string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.cache[address]; ok && time.Now().Before(entry.Expires) {
+		slog.Debug("whitelist cache hit", "address", address, "allowed", entry.Allowed)
+		return entry.Allowed, nil
+	}
+
+	allowed, err := p.getWhitelisted(ctx, address)
+	if err != nil {
+		return false, err
+	}
+
+	if p.ttl > 0 {
+		p.cache[address] = cacheEntry{Allowed: allowed, Expires: time.Now().Add(p.ttl)}
+		if err := p.saveCache(); err != nil {
+			slog.Warn("unable to save whitelist cache, continuing", "warning", err)
+		}
+	}
+
+	return allowed, nil
+}
+
+// getWhitelisted queries the remote database whitelist endpoint
+func (p *RemoteProvider) getWhitelisted(ctx context.Context, address string) (bool, error) {
+	resp, err := p.r.R().
+		SetContext(ctx).
+		SetPathParam("address", address).
+		Get("migrations-whitelist/{address}")
+	if err != nil {
+		return false, errors.WithMessage(err, "error getting migration whitelisted addresses")
+	}
+
+	if resp == nil {
+		return false, fmt.Errorf("no response returned when getting migration whitelisted addresses")
+	}
+
+	statusCode := resp.StatusCode()
+	if statusCode != http.StatusOK {
+		return false, fmt.Errorf("response status code: %d", statusCode)
+	}
+
+	var isAllowed bool
+	if err := json.Unmarshal(resp.Body(), &isAllowed); err != nil {
+		return false, errors.WithMessage(err, "error unmarshalling response")
+	}
+
+	return isAllowed, nil
+}
+
+func (p *RemoteProvider) loadCache() error {
+	if p.cacheFile == "" || p.ttl <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(p.cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read whitelist cache: %w", err)
+	}
+	// The cache is not authenticated, refuse a cache other users could have written
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("whitelist cache accessible by other users, expected 0600 permissions: %s, %s", p.cacheFile, perm)
+	}
+
+	data, err := os.ReadFile(p.cacheFile)
+	if err != nil {
+		return fmt.Errorf("failed to read whitelist cache: %w", err)
+	}
+
+	var cache map[string]cacheEntry
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("failed to unmarshal whitelist cache: %w", err)
+	}
+
+	// Never trust an entry for longer than the TTL
+	maxExpires := time.Now().Add(p.ttl)
+	for address, entry := range cache {
+		if entry.Expires.After(maxExpires) {
+			entry.Expires = maxExpires
+		}
+		p.cache[address] = entry
+	}
+
+	return nil
+}
+
+func (p *RemoteProvider) saveCache() error {
+	if p.cacheFile == "" {
+		return nil
+	}
+
+	// Drop expired entries
+	now := time.Now()
+	for address, entry := range p.cache {
+		if now.After(entry.Expires) {
+			delete(p.cache, address)
+		}
+	}
+
+	data, err := json.Marshal(p.cache)
+	if err != nil {
+		return fmt.Errorf("failed to marshal whitelist cache: %w", err)
+	}
+
+	// Write a new file, so the cache keeps 0600 permissions and is never left truncated
+	tmp, err := os.CreateTemp(filepath.Dir(p.cacheFile), filepath.Base(p.cacheFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write whitelist cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write whitelist cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write whitelist cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.cacheFile); err != nil {
+		return fmt.Errorf("failed to write whitelist cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package whitelist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/liftedinit/mfx-migrator/internal/config"
+	"github.com/liftedinit/mfx-migrator/internal/utils"
+)
+
+const (
+	ModeRemote   = "remote"   // Verify the address using the Talib whitelist endpoint
+	ModeFile     = "file"     // Verify the address using a signed local allowlist file (offline mode)
+	ModeCombined = "combined" // Verify the address using both the Talib whitelist endpoint and a signed local allowlist file
+)
+
+// Provider verifies a MANY address is allowed to migrate tokens.
+//
+// A non-nil error means the lookup itself failed, e.g., the remote database is unavailable.
+// Such errors are transient and the verification can be retried.
+type Provider interface {
+	IsAllowed(ctx context.Context, address string) (bool, error)
+}
+
+// ErrUnavailable is returned when the whitelist lookup failed, e.g., the remote database is unavailable.
+// The verification can be retried.
+var ErrUnavailable = errors.New("whitelist unavailable")
+
+// NotAllowedError is returned when a MANY address is not allowed to migrate tokens
+type NotAllowedError struct {
+	Address string
+}
+
+func (e *NotAllowedError) Error() string {
+	return fmt.Sprintf("address %s not allowed to migrate", e.Address)
+}
+
+// NewProvider creates the whitelist provider described by the config.
+// The resty client is used to query the remote database.
+func NewProvider(c config.WhitelistConfig, r *resty.Client) (Provider, error) {
+	ttl := time.Duration(c.CacheTTL) * time.Second
+	switch c.Mode {
+	case ModeRemote:
+		return NewRemoteProvider(r, ttl, c.CacheFile), nil
+	case ModeFile:
+		return NewFileProvider(c.File, c.PublicKey)
+	case ModeCombined:
+		fileProvider, err := NewFileProvider(c.File, c.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewCombinedProvider(NewRemoteProvider(r, ttl, c.CacheFile), fileProvider), nil
+	default:
+		return nil, fmt.Errorf("invalid whitelist mode: %s", c.Mode)
+	}
+}
+
+// Verify verifies the MANY address is allowed to migrate tokens.
+// It returns a NotAllowedError if the address is not allowed.
+func Verify(ctx context.Context, p Provider, address string) error {
+	allowed, err := p.IsAllowed(ctx, address)
+	if err != nil {
+		return utils.Mark(err, ErrUnavailable)
+	}
+
+	if !allowed {
+		return &NotAllowedError{Address: address}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package whitelist_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftedinit/mfx-migrator/internal/whitelist"
+	"github.com/liftedinit/mfx-migrator/testutils"
+)
+
+func TestRemoteProvider(t *testing.T) {
+	rClient := resty.New().SetBaseURL(testutils.RootUrl)
+	httpmock.ActivateNonDefault(rClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	cacheFile := filepath.Join(t.TempDir(), "whitelist-cache.json")
+
+	t.Run("cached", func(t *testing.T) {
+		httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.WhiteListResponder)
+		p := whitelist.NewRemoteProvider(rClient, time.Minute, cacheFile)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom))
+		}
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+		// The cache is persisted across providers
+		p = whitelist.NewRemoteProvider(rClient, time.Minute, cacheFile)
+		require.NoError(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+		httpmock.Reset()
+	})
+
+	t.Run("untrusted_cache", func(t *testing.T) {
+		httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.InvalidWhiteListResponder)
+		forever := map[string]any{testutils.ManyFrom: map[string]any{"allowed": true, "expires": time.Now().AddDate(100, 0, 0)}}
+		data, err := json.Marshal(forever)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(cacheFile, data, 0600))
+
+		// The entries expire at most TTL after loading
+		p := whitelist.NewRemoteProvider(rClient, time.Nanosecond, cacheFile)
+		time.Sleep(time.Millisecond)
+		require.ErrorContains(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom), "not allowed to migrate")
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+		// A cache other users can write is ignored
+		require.NoError(t, os.WriteFile(cacheFile, data, 0600))
+		require.NoError(t, os.Chmod(cacheFile, 0666))
+		p = whitelist.NewRemoteProvider(rClient, time.Minute, cacheFile)
+		require.ErrorContains(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom), "not allowed to migrate")
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+
+		// The cache is rewritten with 0600 permissions
+		info, err := os.Stat(cacheFile)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		httpmock.Reset()
+	})
+
+	t.Run("not_allowed", func(t *testing.T) {
+		httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.InvalidWhiteListResponder)
+		p := whitelist.NewRemoteProvider(rClient, 0, "")
+		var notAllowedErr *whitelist.NotAllowedError
+		require.ErrorAs(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom), &notAllowedErr)
+		httpmock.Reset()
+	})
+
+	t.Run("lookup_error", func(t *testing.T) {
+		httpmock.RegisterResponder("GET", "=~^"+testutils.WhiteListUrl, testutils.NotFoundResponder)
+		p := whitelist.NewRemoteProvider(rClient, 0, "")
+		err := whitelist.Verify(context.Background(), p, testutils.ManyFrom)
+		require.ErrorContains(t, err, "response status code: 404")
+		require.ErrorIs(t, err, whitelist.ErrUnavailable)
+		var notAllowedErr *whitelist.NotAllowedError
+		require.False(t, errors.As(err, &notAllowedErr))
+		httpmock.Reset()
+	})
+}
+
+func TestFileProvider(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	data, err := json.Marshal(whitelist.NewSignedAllowList([]string{testutils.ManyFrom}, priv))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	p, err := whitelist.NewFileProvider(path, hex.EncodeToString(pub))
+	require.NoError(t, err)
+	require.NoError(t, whitelist.Verify(context.Background(), p, testutils.ManyFrom))
+	require.ErrorContains(t, whitelist.Verify(context.Background(), p, "maa"), "not allowed to migrate")
+
+	_, err = whitelist.NewFileProvider(path, hex.EncodeToString(otherPub))
+	require.ErrorContains(t, err, "invalid allowlist signature")
+
+	_, err = whitelist.NewFileProvider(path, "foobar")
+	require.ErrorContains(t, err, "invalid allowlist public key")
+}
+
+func TestCombinedProvider(t *testing.T) {
+	// A combined provider without providers allows no address
+	require.ErrorContains(t, whitelist.Verify(context.Background(), whitelist.NewCombinedProvider(), testutils.ManyFrom), "not allowed to migrate")
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	newFileProvider := func(addresses []string) whitelist.Provider {
+		path := filepath.Join(t.TempDir(), "allowlist.json")
+		data, err := json.Marshal(whitelist.NewSignedAllowList(addresses, priv))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		p, err := whitelist.NewFileProvider(path, hex.EncodeToString(priv.Public().(ed25519.PublicKey)))
+		require.NoError(t, err)
+		return p
+	}
+	allow := newFileProvider([]string{testutils.ManyFrom})
+	deny := newFileProvider([]string{})
+
+	require.NoError(t, whitelist.Verify(context.Background(), whitelist.NewCombinedProvider(allow), testutils.ManyFrom))
+	require.ErrorContains(t, whitelist.Verify(context.Background(), whitelist.NewCombinedProvider(allow, deny), testutils.ManyFrom), "not allowed to migrate")
+}
@@ -0,0 +1,162 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	FakeTxHash        = "9B3B1F4C6E2A0D5F7A8B9C0D1E2F3A4B5C6D7E8F9A0B1C2D3E4F5A6B7C8D9E0F"
+	FakeBlockTime     = "2024-03-01T16:54:02.651Z"
+	FakeBlockHeight   = "42"
+	FakeBankAddress   = "manifest1hj5fveer5cjtn4wd6wstzugjfdxzl0xp8ws9ct"
+	FakeAccountNumber = "7"
+	FakeSequence      = "5"
+)
+
+// FakeChain describes the behavior of a fake chain binary.
+// An empty output makes the corresponding command fail.
+// When a command is invoked more times than it has outputs, the last output is repeated.
+type FakeChain struct {
+	GasEstimate    string   // Gas estimate printed by the simulation
+	WaitDelay      string   // Number of seconds `q event-query-tx-for` hangs before answering
+	SendOutputs    []string // JSON outputs of successive `tx bank send`
+	WaitOutputs    []string // JSON outputs of successive `q event-query-tx-for`
+	TxOutputs      []string // JSON outputs of successive `q tx`, a failure reports the transaction as not found
+	BlockOutputs   []string // JSON outputs of successive `q block`
+	AccountOutputs []string // JSON outputs of successive `q auth account`
+	KeyOutputs     []string // Outputs of successive `keys show`
+	BalanceOutputs []string // JSON outputs of successive `q bank balances`
+	Passphrase     string   // Keyring passphrase the key commands read from the standard input (optional)
+}
+
+// FakeBalanceOutput returns the JSON output of a bank balance query
+func FakeBalanceOutput(amount, denom string) string {
+	return fmt.Sprintf(`{"denom":"%s","amount":"%s"}`, denom, amount)
+}
+
+// FakeTxOutput returns the JSON output of a transaction with the given code and raw log
+func FakeTxOutput(code int, rawLog string) string {
+	return fmt.Sprintf(`{"txhash":"%s","codespace":"sdk","code":%d,"raw_log":%q}`, FakeTxHash, code, rawLog)
+}
+
+// DefaultFakeChain is a fake chain where every command succeeds
+var DefaultFakeChain = FakeChain{
+	GasEstimate:    "100000",
+	SendOutputs:    []string{FakeTxOutput(0, "")},
+	WaitOutputs:    []string{fmt.Sprintf(`{"txhash":"%s","code":0,"raw_log":"","height":"%s","gas_wanted":"100000","gas_used":"81234"}`, FakeTxHash, FakeBlockHeight)},
+	BlockOutputs:   []string{fmt.Sprintf(`{"header":{"time":"%s"}}`, FakeBlockTime)},
+	AccountOutputs: []string{fmt.Sprintf(`{"account":{"type":"/cosmos.auth.v1beta1.BaseAccount","value":{"address":"%s","account_number":"%s","sequence":"%s"}}}`, FakeBankAddress, FakeAccountNumber, FakeSequence)},
+	KeyOutputs:     []string{FakeBankAddress},
+	BalanceOutputs: []string{FakeBalanceOutput("1000000000000", "umfx")},
+}
+
+// NewFakeChainBinary writes a fake chain binary to a temporary directory and returns its path.
+// Every invocation of the binary is recorded, see FakeChainCalls.
+func NewFakeChainBinary(t *testing.T, chain FakeChain) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	outputs := map[string][]string{
+		"send":    chain.SendOutputs,
+		"wait":    chain.WaitOutputs,
+		"tx":      chain.TxOutputs,
+		"block":   chain.BlockOutputs,
+		"account": chain.AccountOutputs,
+		"key":     chain.KeyOutputs,
+		"balance": chain.BalanceOutputs,
+	}
+	for name, values := range outputs {
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		for i, output := range values {
+			writeFile(t, filepath.Join(dir, fmt.Sprintf("%s.%d", name, i)), output)
+		}
+		writeFile(t, filepath.Join(dir, name+".last"), strconv.Itoa(len(values)-1))
+	}
+
+	script := fmt.Sprintf(`#!/bin/sh
+DIR=%q
+echo "$*" >> "$DIR/calls"
+
+output() {
+	n=$(cat "$DIR/$1.count" 2>/dev/null || echo 0)
+	echo $((n + 1)) > "$DIR/$1.count"
+	[ -e "$DIR/$1.$n" ] || n=$(cat "$DIR/$1.last")
+	if [ ! -s "$DIR/$1.$n" ]; then
+		echo "$1 failed: not found" >&2
+		exit 1
+	fi
+	cat "$DIR/$1.$n"
+}
+
+if [ -n %q ]; then
+	case "$*" in
+		"tx bank send"*|"keys show"*)
+			read -r passphrase || passphrase=""
+			if [ "$passphrase" != %q ]; then
+				echo "Error: aes.KeyUnwrap(): integrity check failed" >&2
+				exit 1
+			fi
+			;;
+	esac
+fi
+
+case "$*" in
+	*--dry-run*)
+		if [ -z %q ]; then
+			echo "simulation failed" >&2
+			exit 1
+		fi
+		echo "gas estimate: %s" >&2
+		;;
+	"tx bank send"*) output send ;;
+	"q event-query-tx-for"*)
+		[ -z %q ] || sleep %q
+		output wait
+		;;
+	"q tx "*) output tx ;;
+	"q block"*) output block ;;
+	"q auth account"*) output account ;;
+	"keys show"*) output key ;;
+	"q bank balances"*) output balance ;;
+	*)
+		echo "unknown command: $*" >&2
+		exit 1
+		;;
+esac
+`, dir, chain.Passphrase, chain.Passphrase, chain.GasEstimate, chain.GasEstimate, chain.WaitDelay, chain.WaitDelay)
+
+	path := filepath.Join(dir, "manifestd")
+	if err := os.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// FakeChainCalls returns the arguments of every invocation of the fake chain binary
+func FakeChainCalls(t *testing.T, binary string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(binary), "calls"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,115 @@
+package testutils
+
+import (
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/liftedinit/mfx-migrator/internal/many"
+)
+
+const ManySymbolAddress = "mqbh742x4s356ddaryrxaowt4wxtlocekzpufodvowrirfrqaaaaa3l"
+
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// NewManyNodeServer starts a local stub MANY node answering `blockchain.request` queries.
+// The stub knows a single `ledger.send` transaction, `args`, identified by `hash`.
+func NewManyNodeServer(t *testing.T, hash string, args many.Arguments) *httptest.Server {
+	t.Helper()
+
+	request := mustEncodeEnvelope(t, many.RequestMessageTag, map[int]interface{}{
+		0: 1,
+		1: mustParseIdentity(t, args.From),
+		3: many.LedgerSendMethod,
+		4: mustMarshal(t, map[int]interface{}{
+			1: mustParseIdentity(t, args.To),
+			2: mustParseAmount(t, args.Amount),
+			3: mustParseIdentity(t, args.Symbol),
+			4: args.Memo,
+		}),
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var envelope coseSign1
+		var msg cbor.RawTag
+		var reqMsg struct {
+			Method string `cbor:"3,keyasint"`
+			Data   []byte `cbor:"4,keyasint"`
+		}
+		var reqArgs struct {
+			Query map[int][]byte `cbor:"0,keyasint"`
+		}
+		if cbor.Unmarshal(body, &envelope) != nil ||
+			cbor.Unmarshal(envelope.Payload, &msg) != nil ||
+			cbor.Unmarshal(msg.Content, &reqMsg) != nil ||
+			cbor.Unmarshal(reqMsg.Data, &reqArgs) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var data interface{}
+		if reqMsg.Method != many.BlockchainRequestMethod {
+			data = map[int]interface{}{0: 1, 1: "unknown endpoint"}
+		} else if hex.EncodeToString(reqArgs.Query[0]) != hash {
+			data = map[int]interface{}{0: 2, 1: "transaction not found"}
+		} else {
+			data = mustMarshal(t, map[int]interface{}{0: request})
+		}
+
+		w.Header().Set("Content-Type", many.CborContentType)
+		_, _ = w.Write(mustEncodeEnvelope(t, many.ResponseMessageTag, map[int]interface{}{0: 1, 4: data}))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func mustEncodeEnvelope(t *testing.T, tag uint64, msg map[int]interface{}) []byte {
+	return mustMarshal(t, cbor.Tag{Number: many.CoseSign1Tag, Content: coseSign1{
+		Protected:   mustMarshal(t, map[int]interface{}{}),
+		Unprotected: map[int]interface{}{},
+		Payload:     mustMarshal(t, cbor.Tag{Number: tag, Content: msg}),
+		Signature:   []byte{},
+	}})
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func mustParseIdentity(t *testing.T, s string) many.Identity {
+	identity, err := many.ParseIdentity(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func mustParseAmount(t *testing.T, s string) *big.Int {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("invalid amount: %s", s)
+	}
+	return amount
+}